@@ -38,6 +38,12 @@ func main() {
 			"environment": "production",
 			"region":      "cn-east",
 		},
+		// 节点标签，引擎可通过标签选择器（如 "region=cn-east,env!=prod"）分组下发命令
+		Labels: map[string]string{
+			"env":    "production",
+			"region": "cn-east",
+			"role":   "worker",
+		},
 	}
 
 	if err := nodepkg.RegisterWithConfig(appName, config); err != nil {
@@ -34,8 +34,8 @@ func main() {
 	// 创建命令接收器并注册自定义处理器
 	receiver := sync.NewCommandReceiver(appName, "tcp://127.0.0.1:1883")
 
-	// 注册配置更新命令处理器
-	receiver.RegisterHandler("config_update", &ConfigUpdateHandler{})
+	// 注册配置更新命令处理器，参数自动解码并校验
+	sync.RegisterTyped(receiver, "config_update", handleConfigUpdate)
 
 	// 注册数据查询命令处理器
 	receiver.RegisterHandler("data_query", &DataQueryHandler{})
@@ -69,29 +69,28 @@ func main() {
 	log.Printf("应用已退出")
 }
 
-// ConfigUpdateHandler 配置更新命令处理器
-type ConfigUpdateHandler struct{}
+// ConfigUpdateParams 配置更新命令参数
+type ConfigUpdateParams struct {
+	Config  map[string]interface{} `json:"config" validate:"required,min=1" desc:"新配置"`
+	Format  string                 `json:"format" validate:"oneof=json yaml" desc:"配置格式"`
+	Restart bool                   `json:"restart" desc:"更新后是否重启"`
+}
+
+// ConfigUpdateResult 配置更新命令结果
+type ConfigUpdateResult struct {
+	Applied int `json:"applied"`
+}
 
-func (h *ConfigUpdateHandler) Handle(ctx context.Context, cmd *sync.Command) (*sync.CommandResult, error) {
+// handleConfigUpdate 配置更新命令处理函数
+func handleConfigUpdate(ctx context.Context, params ConfigUpdateParams) (ConfigUpdateResult, error) {
 	log.Printf("收到配置更新命令")
 
-	// 解析配置参数
-	if configData, ok := cmd.Parameters["config"]; ok {
-		configJSON, _ := json.Marshal(configData)
-		log.Printf("新配置: %s", string(configJSON))
+	configJSON, _ := json.Marshal(params.Config)
+	log.Printf("新配置: %s", string(configJSON))
 
-		// TODO: 应用新配置
-	}
+	// TODO: 应用新配置
 
-	return &sync.CommandResult{
-		Success:   true,
-		Message:   "Configuration updated successfully",
-		RequestID: cmd.RequestID,
-	}, nil
-}
-
-func (h *ConfigUpdateHandler) GetCommandName() string {
-	return "config_update"
+	return ConfigUpdateResult{Applied: len(params.Config)}, nil
 }
 
 // DataQueryHandler 数据查询命令处理器
@@ -103,21 +102,21 @@ func (h *DataQueryHandler) Handle(ctx context.Context, cmd *sync.Command) (*sync
 	// 获取查询参数
 	queryType, _ := cmd.Parameters["type"].(string)
 
-	var result string
 	switch queryType {
 	case "metrics":
-		result = `{"cpu": 25.5, "memory": 512, "goroutines": 42}`
+		return sync.NewSuccessResult(cmd.RequestID, "ok", map[string]interface{}{
+			"cpu":        25.5,
+			"memory":     512,
+			"goroutines": 42,
+		}), nil
 	case "status":
-		result = `{"status": "healthy", "uptime": 3600}`
+		return sync.NewSuccessResult(cmd.RequestID, "ok", map[string]interface{}{
+			"status": "healthy",
+			"uptime": 3600,
+		}), nil
 	default:
-		result = `{"error": "unknown query type"}`
+		return sync.NewErrorResult(cmd.RequestID, sync.ErrCodeInvalidParams, "unknown query type: "+queryType), nil
 	}
-
-	return &sync.CommandResult{
-		Success:   true,
-		Message:   result,
-		RequestID: cmd.RequestID,
-	}, nil
 }
 
 func (h *DataQueryHandler) GetCommandName() string {
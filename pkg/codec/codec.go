@@ -0,0 +1,130 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/codec/codec.go
+ * 消息编解码 - 可替换的消息序列化格式
+ *
+ * JSON 为默认格式。消息不加任何前缀，接收方按消息的首字节识别格式：
+ * 各格式的顶层结构（JSON 对象、CBOR map、MessagePack map、Protobuf 信封）首字节互不相同，
+ * 无法识别的消息按 JSON 解析，与旧版本完全兼容。
+ * 消息信封的 content_type 字段声明发送方使用的格式，不同格式的节点和引擎可以共存。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package codec
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// 内容类型
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeCBOR     = "application/cbor"
+	ContentTypeMsgPack  = "application/msgpack"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// Codec 消息编解码器
+type Codec interface {
+	// ContentType 返回内容类型，如 application/cbor
+	ContentType() string
+	// Marshal 序列化消息
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal 反序列化消息
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Detector 可按消息内容识别格式的编解码器
+// 非 JSON 编解码器需要实现该接口，Decode 才能识别其消息
+type Detector interface {
+	// Detect 判断消息是否为该格式，只应检查首字节等结构特征
+	Detect(data []byte) bool
+}
+
+var (
+	codecs   = make(map[string]Codec)
+	codecsMu sync.RWMutex
+)
+
+func init() {
+	Register(JSON)
+	Register(CBOR)
+	Register(MsgPack)
+}
+
+// Register 注册编解码器，相同内容类型的编解码器会被替换
+func Register(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[c.ContentType()] = c
+}
+
+// Get 按内容类型查找编解码器
+func Get(contentType string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[contentType]
+	return c, ok
+}
+
+// Parse 按名称（json、cbor、msgpack、protobuf）或内容类型查找编解码器
+// Protobuf 编解码器由 pkg/protocol 按消息协议的 schema 注册
+func Parse(name string) (Codec, error) {
+	switch strings.ToLower(name) {
+	case "", "json":
+		return JSON, nil
+	case "cbor":
+		return CBOR, nil
+	case "msgpack", "messagepack":
+		return MsgPack, nil
+	case "protobuf", "proto":
+		name = ContentTypeProtobuf
+	}
+	if c, ok := Get(name); ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("unknown codec %q", name)
+}
+
+// Default 返回默认编解码器（JSON）
+func Default() Codec {
+	return JSON
+}
+
+// Encode 使用指定编解码器序列化消息，c 为nil时使用默认编解码器
+func Encode(c Codec, v interface{}) ([]byte, error) {
+	if c == nil {
+		c = Default()
+	}
+	return c.Marshal(v)
+}
+
+// Decode 识别消息格式并反序列化，返回消息使用的编解码器
+func Decode(data []byte, v interface{}) (Codec, error) {
+	c := Detect(data)
+	if err := c.Unmarshal(data, v); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// Detect 识别消息使用的编解码器，没有编解码器识别时为 JSON
+func Detect(data []byte) Codec {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	for _, c := range codecs {
+		if d, ok := c.(Detector); ok && c.ContentType() != ContentTypeJSON && d.Detect(data) {
+			return c
+		}
+	}
+	return JSON
+}
+
+// ContentTypeOf 返回消息的内容类型
+func ContentTypeOf(data []byte) string {
+	return Detect(data).ContentType()
+}
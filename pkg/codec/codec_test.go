@@ -0,0 +1,127 @@
+package codec
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type testCommand struct {
+	Command    string                 `json:"command"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	Retry      int                    `json:"retry"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// normalize 经过 JSON 转换后比较，不同格式解码得到的数值类型不同
+func normalize(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal %T: %v", v, err)
+	}
+	var out interface{}
+	if err := JSON.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = json.Marshal(out)
+	return string(data)
+}
+
+func TestRoundTripNestedParameters(t *testing.T) {
+	in := &testCommand{
+		Command: "config.update",
+		Retry:   0, // 零值字段也要保留
+		Parameters: map[string]interface{}{
+			"key":     "mode",
+			"enabled": true,
+			"limits":  map[string]interface{}{"cpu": 1.5, "mem": 512, "tags": []interface{}{"a", "b"}},
+			"items":   []interface{}{map[string]interface{}{"id": 1}, map[string]interface{}{"id": 2}},
+			"empty":   nil,
+		},
+	}
+
+	for _, c := range []Codec{JSON, CBOR, MsgPack} {
+		data, err := Encode(c, in)
+		if err != nil {
+			t.Fatalf("%s encode: %v", c.ContentType(), err)
+		}
+
+		var out testCommand
+		got, err := Decode(data, &out)
+		if err != nil {
+			t.Fatalf("%s decode: %v", c.ContentType(), err)
+		}
+		if got != c {
+			t.Errorf("%s payload decoded as %s", c.ContentType(), got.ContentType())
+		}
+		if normalize(t, &out) != normalize(t, in) {
+			t.Errorf("%s round trip = %s, want %s", c.ContentType(), normalize(t, &out), normalize(t, in))
+		}
+
+		// 解码到 map 时嵌套结构为 map[string]interface{}
+		var raw map[string]interface{}
+		if _, err := Decode(data, &raw); err != nil {
+			t.Fatalf("%s decode map: %v", c.ContentType(), err)
+		}
+		params, _ := raw["parameters"].(map[string]interface{})
+		if _, ok := params["limits"].(map[string]interface{}); !ok {
+			t.Errorf("%s nested parameters decoded as %T", c.ContentType(), params["limits"])
+		}
+		if _, ok := raw["retry"]; !ok {
+			t.Errorf("%s dropped zero-valued field", c.ContentType())
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	encode := func(c Codec) []byte {
+		data, err := c.Marshal(map[string]interface{}{"command": "stop"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want Codec
+	}{
+		{"json object", encode(JSON), JSON},
+		{"json with whitespace", []byte(" \n{\"a\":1}"), JSON},
+		{"cbor map", encode(CBOR), CBOR},
+		{"cbor indefinite map", []byte{0xbf, 0xff}, CBOR},
+		{"msgpack fixmap", encode(MsgPack), MsgPack},
+		{"msgpack map16", []byte{0xde, 0x00, 0x00}, MsgPack},
+		{"msgpack map32", []byte{0xdf, 0x00, 0x00, 0x00, 0x00}, MsgPack},
+		{"empty", nil, JSON},
+		{"unknown", []byte{0x01, 0x02}, JSON},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.data); got != tt.want {
+			t.Errorf("%s: Detect = %s, want %s", tt.name, got.ContentType(), tt.want.ContentType())
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		want Codec
+	}{
+		{"", JSON},
+		{"json", JSON},
+		{"CBOR", CBOR},
+		{"msgpack", MsgPack},
+		{"messagepack", MsgPack},
+		{ContentTypeCBOR, CBOR},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.name)
+		if err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %v, %v; want %s", tt.name, got, err, tt.want.ContentType())
+		}
+	}
+	if _, err := Parse("yaml"); err == nil {
+		t.Error("Parse(yaml) succeeded")
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/codec/formats.go
+ * 内置编解码器 - JSON、CBOR、MessagePack
+ *
+ * 所有格式均使用 json 标签作为字段名，同一结构体在不同格式下字段名一致。
+ * 消息的顶层为 map（结构体），CBOR 和 MessagePack 按 map 的首字节识别。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// 内置编解码器
+var (
+	JSON    Codec = jsonCodec{}
+	CBOR    Codec = newCBORCodec()
+	MsgPack Codec = msgpackCodec{}
+)
+
+// jsonCodec JSON编解码器
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string { return ContentTypeJSON }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal 解码到 interface{} 的数值保留为 json.Number，
+// 超过 2^53 的整数转换为 float64 会丢失精度，导致重新序列化的结果与原文不一致
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level value")
+	}
+	return nil
+}
+
+// cborCodec CBOR编解码器
+type cborCodec struct {
+	enc cbor.EncMode
+	dec cbor.DecMode
+}
+
+func newCBORCodec() cborCodec {
+	enc, err := cbor.CoreDetEncOptions().EncMode()
+	if err != nil {
+		panic(err)
+	}
+	// 与 encoding/json 一致，map 解码为 map[string]interface{}
+	dec, err := cbor.DecOptions{
+		DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return cborCodec{enc: enc, dec: dec}
+}
+
+func (cborCodec) ContentType() string { return ContentTypeCBOR }
+
+func (c cborCodec) Marshal(v interface{}) ([]byte, error) {
+	return c.enc.Marshal(v)
+}
+
+func (c cborCodec) Unmarshal(data []byte, v interface{}) error {
+	return c.dec.Unmarshal(data, v)
+}
+
+// Detect CBOR map 的首字节为 0xa0-0xbb（定长）或 0xbf（不定长）
+func (cborCodec) Detect(data []byte) bool {
+	return len(data) > 0 && (data[0] >= 0xa0 && data[0] <= 0xbb || data[0] == 0xbf)
+}
+
+// msgpackCodec MessagePack编解码器
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string { return ContentTypeMsgPack }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	// 嵌套 map 解码为 map[string]interface{}，与 JSON、CBOR 一致
+	dec.SetMapDecoder(func(d *msgpack.Decoder) (interface{}, error) {
+		return d.DecodeMap()
+	})
+	return dec.Decode(v)
+}
+
+// Detect MessagePack map 的首字节为 0x80-0x8f（fixmap）、0xde（map16）或 0xdf（map32）
+func (msgpackCodec) Detect(data []byte) bool {
+	return len(data) > 0 && (data[0]&0xf0 == 0x80 || data[0] == 0xde || data[0] == 0xdf)
+}
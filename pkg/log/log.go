@@ -20,6 +20,9 @@ import (
 // Level 日志级别类型
 type Level = zapcore.Level
 
+// Field 结构化日志字段类型
+type Field = zap.Field
+
 // 日志级别常量
 const (
 	DebugLevel = zapcore.DebugLevel
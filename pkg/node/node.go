@@ -18,9 +18,14 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"strconv"
+	"strings"
 	gosync "sync"
 	"time"
 
+	"github.com/HY-805/SubNodeSync/pkg/codec"
+	"github.com/HY-805/SubNodeSync/pkg/protocol"
+	"github.com/HY-805/SubNodeSync/pkg/sparkplug"
 	nodesync "github.com/HY-805/SubNodeSync/pkg/sync"
 	"github.com/HY-805/SubNodeSync/pkg/transport"
 	"github.com/HY-805/SubNodeSync/pkg/util"
@@ -44,15 +49,19 @@ var Endpoint string
 // Instance 节点实例信息
 type Instance struct {
 	// 基本信息
+	Namespace  string // 租户命名空间
 	NodeName   string // 节点名称
-	InstanceID string // 实例唯一标识 (nodeName-hostname-pid)
+	InstanceID string // 实例唯一标识 ([namespace-]nodeName-hostname-pid)
 	Hostname   string // 主机名
 	PID        int    // 进程ID
 
 	// 内部组件
+	conn            *transport.ConnectionManager // 共享的 broker 连接，MQTTClient 和命令接收器通过各自的会话使用
 	mqttClient      *transport.MQTTClient
 	receiver        *nodesync.CommandReceiver
+	edgeNode        *sparkplug.EdgeNode
 	connected       bool
+	stopped         bool // Stop 已调用，后台启动的组件不再发布到实例上
 	mu              gosync.RWMutex
 	ctx             context.Context
 	cancel          context.CancelFunc
@@ -63,6 +72,9 @@ type Instance struct {
 
 	// 配置
 	config *Config
+
+	// 实际使用的主题规划，租户段为命名空间
+	topics *protocol.TopicScheme
 }
 
 // Config 节点配置
@@ -71,6 +83,16 @@ type Config struct {
 	MQTTBroker   string
 	MQTTUsername string
 	MQTTPassword string
+	// MQTT TLS配置，MQTTBroker 使用 ssl:// 等加密协议时生效，为nil时不使用TLS
+	// 证书文件更新后在下次（重新）连接时自动加载，无需重启
+	MQTTTLS *transport.TLSConfig
+	// MQTT协议版本，transport.ProtocolV5 时使用 MQTT v5 的应答主题、关联数据等特性，
+	// broker 不支持时自动改用 v3.1.1；为0时使用 v3.1.1
+	MQTTProtocolVersion byte
+	// MQTT v5 会话过期时间，为0时使用 transport.DefaultSessionExpiry
+	MQTTSessionExpiry time.Duration
+	// MQTT v5 发布消息的过期时间，为0时不过期
+	MQTTMessageExpiry time.Duration
 
 	// 引擎配置
 	EngineEndpoint string
@@ -85,14 +107,63 @@ type Config struct {
 
 	// 自定义元数据
 	Metadata map[string]string
+
+	// 节点标签（如 env、region、role），用于按标签选择器分组下发命令
+	Labels map[string]string
+
+	// 命令幂等记录的持久化文件路径
+	// 设置后已执行命令的 RequestID 在进程重启后仍然有效，避免重复投递导致二次执行；
+	// 设置了 CommandVerifier 时，签名 nonce 记录持久化到同目录的 {路径}.nonces
+	IdempotencyStorePath string
+
+	// 判断命令过期时允许的时钟偏差，为0时使用默认值
+	ClockSkewTolerance time.Duration
+
+	// 执行命令的工作池配置，为nil时使用默认值
+	CommandWorkerPool *nodesync.WorkerPoolConfig
+
+	// 命令签名校验器，设置后只执行签名有效的命令
+	CommandVerifier *nodesync.CommandVerifier
+
+	// 命令授权策略文件路径，设置后按调用方角色限制可执行的命令
+	// 文件变化时自动重新加载；调用方来自签名，必须同时设置 CommandVerifier
+	AuthorizationPolicyPath string
+
+	// 主题规划（前缀、租户段），为nil时使用默认规划
+	// MQTTClient 和命令接收器使用同一套主题
+	Topics *protocol.TopicScheme
+
+	// 租户命名空间，多个租户共用同一个 Broker 时用于隔离同名节点
+	// 设置后作为主题的租户段，并加入实例ID、注册消息和HTTP注册，
+	// 命令接收器只执行命名空间相同的命令
+	Namespace string
+
+	// 消息编解码器，为nil时使用 JSON
+	// 带宽受限的场景可使用 codec.CBOR 或 codec.MsgPack 减小心跳等消息的体积
+	Codec codec.Codec
+
+	// 传输层工厂，参数为客户端ID，为nil时使用 paho MQTT 客户端连接 MQTTBroker
+	// 节点只创建一个共享连接（客户端ID为实例ID），启用 Sparkplug 时再为边缘节点创建一个
+	// 传入基于 transport.MemoryBroker 的实现可在单进程内运行节点或编写测试
+	TransportFactory func(clientID string) transport.Transport
+
+	// Sparkplug B 模式配置，为nil时不启用
+	// 启用后节点同时作为 Sparkplug 边缘节点发布 NBIRTH/NDEATH/NDATA，并执行 NCMD 写入的命令
+	Sparkplug *SparkplugConfig
 }
 
 // DefaultConfig 返回默认配置
 func DefaultConfig() *Config {
 	return &Config{
-		MQTTBroker:        getMQTTBroker(),
-		HeartbeatInterval: HeartbeatInterval,
-		Metadata:          make(map[string]string),
+		MQTTBroker:          getMQTTBroker(),
+		HeartbeatInterval:   HeartbeatInterval,
+		Metadata:            make(map[string]string),
+		Labels:              make(map[string]string),
+		Codec:               getCodec(),
+		Namespace:           os.Getenv("NODE_NAMESPACE"),
+		MQTTTLS:             getTLSConfig(),
+		MQTTProtocolVersion: getProtocolVersion(),
+		Sparkplug:           getSparkplugConfig(),
 	}
 }
 
@@ -128,6 +199,37 @@ func GetInstanceID(nodeName string) string {
 	return fmt.Sprintf("%s-%s-%d", nodeName, hostname, pid)
 }
 
+// GetNamespacedInstanceID 生成带租户命名空间的实例标识
+// 格式: namespace-nodeName-hostname-pid，命名空间为空时与 GetInstanceID 相同
+func GetNamespacedInstanceID(namespace, nodeName string) string {
+	if namespace == "" {
+		return GetInstanceID(nodeName)
+	}
+	return namespace + "-" + GetInstanceID(nodeName)
+}
+
+// resolveTopicScheme 根据配置生成实际使用的主题规划
+func resolveTopicScheme(config *Config) (*protocol.TopicScheme, error) {
+	scheme := protocol.DefaultTopicScheme()
+	if config.Topics != nil {
+		copied := *config.Topics
+		scheme = &copied
+	}
+	if config.Namespace != "" {
+		if err := protocol.ValidateSegment(config.Namespace); err != nil {
+			return nil, fmt.Errorf("invalid namespace: %w", err)
+		}
+		if scheme.Tenant != "" && scheme.Tenant != config.Namespace {
+			return nil, fmt.Errorf("topic tenant %q conflicts with namespace %q", scheme.Tenant, config.Namespace)
+		}
+		scheme.Tenant = config.Namespace
+	}
+	if err := scheme.Validate(); err != nil {
+		return nil, err
+	}
+	return scheme, nil
+}
+
 // GetCurrentInstance 获取当前节点实例
 func GetCurrentInstance() *Instance {
 	instanceMu.Lock()
@@ -151,18 +253,50 @@ func RegisterWithConfig(nodeName string, config *Config) error {
 	if nodeName == "" {
 		return fmt.Errorf("nodeName is required")
 	}
+	// 节点名是主题中的一段，不能包含 /、+、#
+	if err := protocol.ValidateSegment(nodeName); err != nil {
+		return fmt.Errorf("invalid nodeName: %w", err)
+	}
+
+	topics, err := resolveTopicScheme(config)
+	if err != nil {
+		return err
+	}
+	if config.AuthorizationPolicyPath != "" && config.CommandVerifier == nil {
+		// 未签名命令中的调用方可被任意伪造，授权策略必须配合签名校验使用
+		return fmt.Errorf("AuthorizationPolicyPath requires CommandVerifier")
+	}
+	if config.MQTTTLS != nil {
+		if err := config.MQTTTLS.Validate(); err != nil {
+			return err
+		}
+	}
+	if config.Sparkplug != nil {
+		groupID, edgeNodeID := config.Sparkplug.resolve(config.Namespace, nodeName, GetNamespacedInstanceID(config.Namespace, nodeName))
+		if err := sparkplug.ValidateID(groupID); err != nil {
+			return err
+		}
+		if err := sparkplug.ValidateID(edgeNodeID); err != nil {
+			return err
+		}
+	}
 
 	instanceMu.Lock()
 	defer instanceMu.Unlock()
 
 	// 如果启用了文件锁，尝试获取锁
+	// 不同租户的同名节点可以在同一主机上运行
 	var fileLock *util.FileLock
 	if config.EnableFileLock {
-		fileLock = util.AcquireLock(nodeName)
+		lockName := nodeName
+		if config.Namespace != "" {
+			lockName = config.Namespace + "-" + nodeName
+		}
+		fileLock = util.AcquireLock(lockName)
 		if fileLock == nil {
-			return fmt.Errorf("另一个 %s 实例已在运行中，无法获取文件锁", nodeName)
+			return fmt.Errorf("另一个 %s 实例已在运行中，无法获取文件锁", lockName)
 		}
-		log.Printf("[SubNodeSync] 文件锁已获取: %s", util.GetLockFilePath(nodeName))
+		log.Printf("[SubNodeSync] 文件锁已获取: %s", util.GetLockFilePath(lockName))
 	}
 
 	// 创建节点实例
@@ -170,8 +304,9 @@ func RegisterWithConfig(nodeName string, config *Config) error {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	instance := &Instance{
+		Namespace:  config.Namespace,
 		NodeName:   nodeName,
-		InstanceID: GetInstanceID(nodeName),
+		InstanceID: GetNamespacedInstanceID(config.Namespace, nodeName),
 		Hostname:   hostname,
 		PID:        os.Getpid(),
 		connected:  false,
@@ -179,6 +314,7 @@ func RegisterWithConfig(nodeName string, config *Config) error {
 		cancel:     cancel,
 		fileLock:   fileLock,
 		config:     config,
+		topics:     topics,
 	}
 	currentInstance = instance
 
@@ -202,26 +338,47 @@ func RegisterWithConfig(nodeName string, config *Config) error {
 	return nil
 }
 
-// connectMQTT 连接MQTT broker
+// connectMQTT 建立共享的MQTT连接
+// MQTTClient 和命令接收器通过各自的会话复用同一个连接，连接建立后由传输层自动重连并恢复订阅
+//
+// 命令接收器是实例在线状态的唯一发布者：它先加入连接，注册带命名空间的遗嘱，
+// MQTTClient 不再发布在线状态；授权策略无法加载、接收器未创建时才由 MQTTClient 发布
 func (inst *Instance) connectMQTT() error {
-	brokerURL := inst.config.MQTTBroker
-	if brokerURL == "" {
-		brokerURL = getMQTTBroker()
-	}
-
-	// 使用实例ID作为客户端ID，确保唯一性
-	mqttClient, err := transport.NewMQTTClientWithID(
-		inst.NodeName,
-		inst.InstanceID,
-		brokerURL,
-		inst.config.MQTTUsername,
-		inst.config.MQTTPassword,
-	)
-	if err != nil {
-		return err
+	brokerURL := inst.brokerURL()
+
+	inst.mu.Lock()
+	if inst.conn == nil {
+		inst.conn = transport.NewConnectionManager(inst.newTransport(brokerURL))
+	}
+	conn := inst.conn
+	receiver := inst.receiver
+	inst.mu.Unlock()
+
+	if receiver == nil {
+		receiver = inst.newCommandReceiver()
+		inst.mu.Lock()
+		inst.receiver = receiver
+		inst.mu.Unlock()
+	}
+
+	// 启动命令接收器（包含心跳发送），失败时由重连任务再次启动
+	if receiver != nil && receiver.GetStatus() != nodesync.ReceiverStatusRunning {
+		if err := receiver.Start(inst.ctx); err != nil {
+			log.Printf("[%s] 启动命令接收器失败: %v", inst.InstanceID, err)
+			return err
+		}
+		log.Printf("[%s] 命令接收器已启动, broker=%s", inst.InstanceID, brokerURL)
 	}
 
+	mqttClient := transport.NewMQTTClientWithTransport(inst.NodeName, inst.InstanceID, conn.NewSession())
+	mqttClient.SetTopicScheme(inst.topics)
+	mqttClient.SetCodec(inst.config.Codec)
+	mqttClient.SetNamespace(inst.Namespace)
+	mqttClient.SetStatePublishing(receiver == nil)
+
 	if err := mqttClient.Connect(); err != nil {
+		// 连接失败的会话不会再使用，移除其订阅
+		mqttClient.Unsubscribe(mqttClient.GetControlTopic())
 		return err
 	}
 
@@ -230,30 +387,43 @@ func (inst *Instance) connectMQTT() error {
 	inst.connected = true
 	inst.mu.Unlock()
 
-	// 设置控制消息处理
-	mqttClient.SetControlHandler(inst.handleControl)
-
-	// 启动命令接收和心跳机制
-	go inst.startCommandReceiver(brokerURL)
+	if receiver != nil && inst.config.Sparkplug != nil {
+		go inst.startSparkplug(brokerURL, receiver)
+	}
 
 	return nil
 }
 
-// handleControl 处理控制消息
-func (inst *Instance) handleControl(action string) {
-	log.Printf("[SubNodeSync] 收到控制命令: %s", action)
-	switch action {
-	case "stop":
-		log.Printf("[SubNodeSync] 收到停止命令，准备退出...")
-		os.Exit(0)
-	case "restart":
-		log.Printf("[SubNodeSync] 收到重启命令...")
-		inst.Stop()
-		os.Exit(0)
+// newTransport 创建共享连接的传输层
+// 使用实例ID作为客户端ID，持久会话保证离线期间下发的命令在重连后送达
+func (inst *Instance) newTransport(brokerURL string) transport.Transport {
+	if inst.config.TransportFactory != nil {
+		return inst.config.TransportFactory(inst.InstanceID)
 	}
+	return transport.NewTransport(&transport.MQTTConfig{
+		BrokerURL:         brokerURL,
+		ClientID:          inst.InstanceID,
+		Username:          inst.config.MQTTUsername,
+		Password:          inst.config.MQTTPassword,
+		KeepAlive:         60 * time.Second,
+		PersistentSession: true,
+		TLS:               inst.config.MQTTTLS,
+		ProtocolVersion:   inst.config.MQTTProtocolVersion,
+		SessionExpiry:     inst.config.MQTTSessionExpiry,
+		MessageExpiry:     inst.config.MQTTMessageExpiry,
+	})
 }
 
-// startReconnectLoop 启动后台重连循环
+// brokerURL 返回MQTT broker地址
+func (inst *Instance) brokerURL() string {
+	if inst.config.MQTTBroker != "" {
+		return inst.config.MQTTBroker
+	}
+	return getMQTTBroker()
+}
+
+// startReconnectLoop 启动后台重连循环，直到首次连接成功
+// 连接建立后由传输层自动重连，共享连接上的订阅随之恢复
 func (inst *Instance) startReconnectLoop() {
 	inst.reconnectTicker = time.NewTicker(ReconnectInterval)
 	defer inst.reconnectTicker.Stop()
@@ -266,63 +436,106 @@ func (inst *Instance) startReconnectLoop() {
 			log.Printf("[SubNodeSync] MQTT 重连任务已停止")
 			return
 		case <-inst.reconnectTicker.C:
-			inst.mu.RLock()
-			connected := inst.connected
-			inst.mu.RUnlock()
-
-			if connected {
-				if inst.mqttClient != nil && inst.mqttClient.IsConnected() {
-					continue
-				}
-				inst.mu.Lock()
-				inst.connected = false
-				inst.mu.Unlock()
-			}
-
 			log.Printf("[SubNodeSync] 尝试重新连接 MQTT...")
 			if err := inst.connectMQTT(); err != nil {
 				log.Printf("[SubNodeSync] MQTT 重连失败: %v，将在 %v 后重试", err, ReconnectInterval)
-			} else {
-				log.Printf("[SubNodeSync] MQTT 重连成功: %s", inst.InstanceID)
+				continue
 			}
+			log.Printf("[SubNodeSync] MQTT 重连成功: %s", inst.InstanceID)
+			return
 		}
 	}
 }
 
-// startCommandReceiver 启动命令接收器
-func (inst *Instance) startCommandReceiver(brokerURL string) {
-	// 创建命令接收器
-	receiver := nodesync.NewCommandReceiverWithInstanceID(inst.NodeName, inst.InstanceID, brokerURL)
+// newCommandReceiver 创建复用共享连接的命令接收器并注册默认命令处理器
+// 授权策略无法加载时返回nil
+func (inst *Instance) newCommandReceiver() *nodesync.CommandReceiver {
+	// 创建命令接收器，复用共享连接
+	receiver := nodesync.NewCommandReceiverWithTransport(inst.NodeName, inst.InstanceID, inst.conn.NewSession())
+	receiver.SetLabels(inst.config.Labels)
+	receiver.SetCodec(inst.config.Codec)
+	receiver.SetTopicScheme(inst.topics)
+	receiver.SetNamespace(inst.Namespace)
+	if inst.config.Sparkplug != nil {
+		receiver.AddCapabilities(nodesync.CapabilitySparkplug)
+	}
+	if inst.config.ClockSkewTolerance > 0 {
+		receiver.SetClockSkewTolerance(inst.config.ClockSkewTolerance)
+	}
+	if inst.config.CommandWorkerPool != nil {
+		receiver.SetWorkerPool(inst.config.CommandWorkerPool)
+	}
+	if inst.config.CommandVerifier != nil {
+		// nonce 记录与幂等记录一起持久化，截获的签名命令在重启后不能重放
+		if inst.config.IdempotencyStorePath != "" {
+			if err := inst.config.CommandVerifier.SetNonceStore(inst.config.IdempotencyStorePath + ".nonces"); err != nil {
+				log.Printf("[%s] 加载命令 nonce 记录失败: %v，仅使用内存记录", inst.InstanceID, err)
+			}
+		}
+		receiver.SetVerifier(inst.config.CommandVerifier)
+	}
+	if inst.config.AuthorizationPolicyPath != "" {
+		authorizer, err := nodesync.NewPolicyAuthorizer(inst.config.AuthorizationPolicyPath)
+		if err != nil {
+			// 策略无法加载时拒绝启动命令接收器，避免在无授权检查的情况下执行命令
+			log.Printf("[%s] 加载授权策略失败: %v，命令接收器未启动", inst.InstanceID, err)
+			return nil
+		}
+		receiver.SetAuthorizer(authorizer)
+		go authorizer.Watch(inst.ctx, nodesync.DefaultPolicyWatchInterval)
+	}
+	if inst.config.IdempotencyStorePath != "" {
+		idempotencyConfig := nodesync.DefaultIdempotencyConfig()
+		idempotencyConfig.PersistPath = inst.config.IdempotencyStorePath
+		if err := receiver.SetIdempotency(idempotencyConfig); err != nil {
+			log.Printf("[%s] 加载命令幂等记录失败: %v，仅使用内存记录", inst.InstanceID, err)
+		}
+	}
 
-	inst.mu.Lock()
-	inst.receiver = receiver
-	inst.mu.Unlock()
+	// 处理器panic不应导致进程退出
+	receiver.Use(nodesync.RecoveryMiddleware())
 
 	// 注册默认命令处理器
-	receiver.RegisterHandler("stop", nodesync.NewStopHandler(func() {
-		log.Printf("[%s] 收到停止命令，准备退出...", inst.InstanceID)
-		os.Exit(0)
-	}))
-	receiver.RegisterHandler("status", nodesync.NewStatusHandler())
-	receiver.RegisterHandler("query", nodesync.NewQueryHandler())
+	// stop 和 restart 在应答发布后才停止实例并退出，见 exitAfterReply
+	stop := nodesync.NewStopHandler(nil)
+	receiver.RegisterHandler("stop", nodesync.NewCustomHandler("stop", func(ctx context.Context, cmd *nodesync.Command) (*nodesync.CommandResult, error) {
+		log.Printf("[%s] 收到停止命令，发送应答后退出...", inst.InstanceID)
+		inst.exitAfterReply(ctx)
+		return stop.Handle(ctx, cmd)
+	}), nodesync.WithDescription("停止节点"))
+	receiver.RegisterHandler("restart", nodesync.NewCustomHandler("restart", func(ctx context.Context, cmd *nodesync.Command) (*nodesync.CommandResult, error) {
+		log.Printf("[%s] 收到重启命令，发送应答后退出，由进程管理器重新启动...", inst.InstanceID)
+		inst.exitAfterReply(ctx)
+		return nodesync.NewSuccessResult(cmd.RequestID, "Restart scheduled", nil), nil
+	}), nodesync.WithDescription("重启节点"))
+	receiver.RegisterHandler("status", nodesync.NewStatusHandler(), nodesync.WithDescription("查询节点状态"))
+	receiver.RegisterHandler("query", nodesync.NewQueryHandler(), nodesync.WithDescription("查询节点信息"))
+
+	return receiver
+}
 
-	// 启动命令接收器（包含心跳发送）
-	if err := receiver.Start(inst.ctx); err != nil {
-		log.Printf("[%s] 启动命令接收器失败: %v", inst.InstanceID, err)
-		return
+// exitAfterReply 在命令应答发布后停止实例并退出进程
+// 退出前停止实例，写入幂等记录并发布离线状态，重复投递的 stop 命令在重启后不会再次执行
+func (inst *Instance) exitAfterReply(ctx context.Context) {
+	exit := func() {
+		inst.Stop()
+		os.Exit(0)
+	}
+	if !nodesync.AfterReply(ctx, exit) {
+		go exit()
 	}
-
-	log.Printf("[%s] 命令接收器已启动, broker=%s", inst.InstanceID, brokerURL)
 }
 
 // registerViaHTTP 通过HTTP注册节点
 func (inst *Instance) registerViaHTTP() error {
 	payload := map[string]interface{}{
+		"namespace":   inst.Namespace,
 		"node_name":   inst.NodeName,
 		"instance_id": inst.InstanceID,
 		"hostname":    inst.Hostname,
 		"pid":         inst.PID,
 		"metadata":    inst.config.Metadata,
+		"labels":      inst.config.Labels,
 	}
 	if v := os.Getenv("APP_BUILD_ID"); v != "" {
 		payload["build_id"] = v
@@ -352,18 +565,28 @@ func (inst *Instance) registerViaHTTP() error {
 //
 // 释放所有资源，包括：
 // - 取消上下文
-// - 断开MQTT连接
-// - 停止命令接收器
+// - 发布 Sparkplug NDEATH（如果启用）
+// - 发布 offline/shutdown 在线状态，MQTTClient 和命令接收器离开共享连接后断开
 // - 释放文件锁（如果启用）
 func (inst *Instance) Stop() {
 	if inst.cancel != nil {
 		inst.cancel()
 	}
-	if inst.mqttClient != nil {
-		inst.mqttClient.Disconnect()
+
+	inst.mu.Lock()
+	inst.stopped = true
+	edgeNode, mqttClient, receiver := inst.edgeNode, inst.mqttClient, inst.receiver
+	inst.edgeNode = nil
+	inst.mu.Unlock()
+
+	if edgeNode != nil {
+		edgeNode.Stop()
 	}
-	if inst.receiver != nil {
-		inst.receiver.Stop()
+	if mqttClient != nil {
+		mqttClient.Disconnect()
+	}
+	if receiver != nil {
+		receiver.Stop()
 	}
 	// 释放文件锁
 	if inst.fileLock != nil {
@@ -386,6 +609,14 @@ func (inst *Instance) GetMQTTClient() *transport.MQTTClient {
 	return inst.mqttClient
 }
 
+// GetConnectionManager 获取共享连接管理器
+// 自定义组件可通过 NewSession 复用节点的MQTT连接，订阅在重连后自动恢复
+func (inst *Instance) GetConnectionManager() *transport.ConnectionManager {
+	inst.mu.RLock()
+	defer inst.mu.RUnlock()
+	return inst.conn
+}
+
 // getMQTTBroker 获取MQTT broker地址
 func getMQTTBroker() string {
 	// 根据操作系统选择默认broker
@@ -398,6 +629,61 @@ func getMQTTBroker() string {
 	return getEnvOrDefault("MQTT_BROKER_URL", DefaultMQTTBroker)
 }
 
+// getCodec 根据环境变量选择消息编解码器
+func getCodec() codec.Codec {
+	c, err := codec.Parse(os.Getenv("MQTT_CODEC"))
+	if err != nil {
+		log.Printf("[SubNodeSync] %v，使用 JSON", err)
+		return codec.Default()
+	}
+	return c
+}
+
+// getProtocolVersion 根据环境变量 MQTT_PROTOCOL_VERSION（"5" 或 "3.1.1"）选择MQTT协议版本
+func getProtocolVersion() byte {
+	switch v := os.Getenv("MQTT_PROTOCOL_VERSION"); v {
+	case "":
+		return 0
+	case "5", "5.0":
+		return transport.ProtocolV5
+	case "3.1.1", "4":
+		return transport.ProtocolV311
+	default:
+		log.Printf("[SubNodeSync] 未知的MQTT协议版本 %q，使用 3.1.1", v)
+		return transport.ProtocolV311
+	}
+}
+
+// getTLSConfig 根据环境变量生成MQTT TLS配置，未设置任何TLS变量时返回nil
+func getTLSConfig() *transport.TLSConfig {
+	config := &transport.TLSConfig{
+		CAFile:     os.Getenv("MQTT_TLS_CA_FILE"),
+		CertFile:   os.Getenv("MQTT_TLS_CERT_FILE"),
+		KeyFile:    os.Getenv("MQTT_TLS_KEY_FILE"),
+		ServerName: os.Getenv("MQTT_TLS_SERVER_NAME"),
+	}
+	config.InsecureSkipVerify, _ = strconv.ParseBool(os.Getenv("MQTT_TLS_INSECURE_SKIP_VERIFY"))
+
+	minVersion, err := transport.ParseTLSVersion(os.Getenv("MQTT_TLS_MIN_VERSION"))
+	if err != nil {
+		log.Printf("[SubNodeSync] %v，使用 TLS 1.2", err)
+	}
+	config.MinVersion = minVersion
+
+	if suites := os.Getenv("MQTT_TLS_CIPHER_SUITES"); suites != "" {
+		config.CipherSuites, err = transport.ParseCipherSuites(strings.Split(suites, ","))
+		if err != nil {
+			log.Printf("[SubNodeSync] %v，使用默认加密套件", err)
+		}
+	}
+
+	if config.CAFile == "" && config.CertFile == "" && config.KeyFile == "" && config.ServerName == "" &&
+		!config.InsecureSkipVerify && config.MinVersion == 0 && len(config.CipherSuites) == 0 {
+		return nil
+	}
+	return config
+}
+
 // getEnvOrDefault 获取环境变量或返回默认值
 func getEnvOrDefault(key, def string) string {
 	v := os.Getenv(key)
@@ -0,0 +1,286 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/node/sparkplug.go
+ * Sparkplug B 模式 - 将节点作为 Sparkplug 边缘节点接入 SCADA 系统
+ *
+ * 节点名称映射为组ID（设置了命名空间时为 {namespace}-{node_name}），实例ID映射为边缘节点ID，
+ * 使用独立的 MQTT 连接（遗嘱为 NDEATH）：
+ *
+ *	spBv1.0/{group_id}/NBIRTH/{instance_id}  节点信息、进程指标和可执行的命令
+ *	spBv1.0/{group_id}/NDATA/{instance_id}   按心跳间隔发布状态和进程指标，以及命令应答
+ *	spBv1.0/{group_id}/NCMD/{instance_id}    写入 Commands/{command} 执行命令
+ *	spBv1.0/{group_id}/NDEATH/{instance_id}  正常停止或连接异常断开
+ *
+ * Sparkplug 主题没有租户段，不同租户的同名节点通过组ID区分。
+ *
+ * 信任模型：Sparkplug B 没有命令签名，能向 NCMD 主题发布消息的客户端都可以写入指标。
+ * 因此 NCMD 执行需要显式开启，只执行 SparkplugConfig.Commands 中列出的命令；
+ * 这些命令跳过签名校验，NCMD 主题的发布权限由 broker ACL 控制，
+ * 命名空间、授权（调用方为 SparkplugCaller）、过期和幂等检查照常进行。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HY-805/SubNodeSync/pkg/protocol"
+	"github.com/HY-805/SubNodeSync/pkg/sparkplug"
+	nodesync "github.com/HY-805/SubNodeSync/pkg/sync"
+	"github.com/HY-805/SubNodeSync/pkg/transport"
+)
+
+// SparkplugCaller Sparkplug 命令的调用方身份，可在授权策略中为其绑定角色
+const SparkplugCaller = "sparkplug"
+
+// Sparkplug 指标名称
+const (
+	sparkplugCommandPrefix = "Commands/"
+	sparkplugReplyMetric   = "Commands/Reply"
+)
+
+// SparkplugConfig Sparkplug B 模式配置
+type SparkplugConfig struct {
+	// GroupID 组ID，为空时使用节点名称，设置了命名空间时为 {namespace}-{节点名称}
+	GroupID string
+	// EdgeNodeID 边缘节点ID，为空时使用实例ID
+	EdgeNodeID string
+	// Commands 允许通过 NCMD 执行的命令，为空时不执行任何 NCMD 命令
+	// NCMD 没有签名，这些命令不经过签名校验，但仍经过授权检查
+	Commands []string
+}
+
+// allows 判断命令是否允许通过 NCMD 执行
+func (c *SparkplugConfig) allows(command string) bool {
+	for _, name := range c.Commands {
+		if name == command {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve 返回实际使用的组ID和边缘节点ID
+// 默认组ID包含命名空间，避免不同租户的同名节点共用一个组
+func (c *SparkplugConfig) resolve(namespace, nodeName, instanceID string) (groupID, edgeNodeID string) {
+	groupID, edgeNodeID = c.GroupID, c.EdgeNodeID
+	if groupID == "" {
+		groupID = nodeName
+		if namespace != "" {
+			groupID = namespace + "-" + nodeName
+		}
+	}
+	if edgeNodeID == "" {
+		edgeNodeID = instanceID
+	}
+	return groupID, edgeNodeID
+}
+
+// startSparkplug 创建并启动 Sparkplug 边缘节点，NCMD 命令交给 receiver 执行
+// 一个连接只能有一个遗嘱，边缘节点的遗嘱必须是 NDEATH，因此不复用节点的共享连接
+func (inst *Instance) startSparkplug(brokerURL string, receiver *nodesync.CommandReceiver) {
+	var t transport.Transport
+	if inst.config.TransportFactory != nil {
+		t = inst.config.TransportFactory(inst.InstanceID + "-sparkplug")
+	} else {
+		// Sparkplug 要求边缘节点使用非持久会话
+		t = transport.NewTransport(&transport.MQTTConfig{
+			BrokerURL:       brokerURL,
+			ClientID:        inst.InstanceID + "-sparkplug",
+			Username:        inst.config.MQTTUsername,
+			Password:        inst.config.MQTTPassword,
+			KeepAlive:       60 * time.Second,
+			TLS:             inst.config.MQTTTLS,
+			ProtocolVersion: inst.config.MQTTProtocolVersion,
+		})
+	}
+
+	groupID, edgeNodeID := inst.config.Sparkplug.resolve(inst.Namespace, inst.NodeName, inst.InstanceID)
+	edge, err := sparkplug.NewEdgeNode(groupID, edgeNodeID, t)
+	if err != nil {
+		log.Printf("[%s] 创建 Sparkplug 边缘节点失败: %v", inst.InstanceID, err)
+		return
+	}
+	edge.SetBirthMetrics(func() []sparkplug.Metric {
+		return inst.sparkplugBirthMetrics(receiver)
+	})
+	edge.OnCommand(func(metrics []sparkplug.Metric) {
+		inst.handleSparkplugCommand(edge, receiver, metrics)
+	})
+	if err := edge.Start(); err != nil {
+		log.Printf("[%s] 启动 Sparkplug 边缘节点失败: %v", inst.InstanceID, err)
+		return
+	}
+
+	// 启动期间实例可能已经停止，此时 Stop 看不到边缘节点，由这里发布 NDEATH 并断开
+	inst.mu.Lock()
+	if inst.stopped {
+		inst.mu.Unlock()
+		edge.Stop()
+		return
+	}
+	inst.edgeNode = edge
+	inst.mu.Unlock()
+
+	log.Printf("[%s] Sparkplug 边缘节点已启动: %s/%s/%s", inst.InstanceID, sparkplug.Namespace, groupID, edgeNodeID)
+	go inst.sparkplugDataLoop(edge, receiver)
+}
+
+// sparkplugDataLoop 按心跳间隔发布 NDATA
+func (inst *Instance) sparkplugDataLoop(edge *sparkplug.EdgeNode, receiver *nodesync.CommandReceiver) {
+	interval := inst.config.HeartbeatInterval
+	if interval <= 0 {
+		interval = HeartbeatInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-inst.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := edge.PublishData(sparkplugDataMetrics(receiver)...); err != nil {
+				log.Printf("[%s] 发布 Sparkplug NDATA 失败: %v", inst.InstanceID, err)
+			}
+		}
+	}
+}
+
+// sparkplugBirthMetrics 生成 NBIRTH 指标：节点信息、标签、状态、进程指标和可执行的命令
+func (inst *Instance) sparkplugBirthMetrics(receiver *nodesync.CommandReceiver) []sparkplug.Metric {
+	metrics := []sparkplug.Metric{
+		sparkplug.NewMetric("Node Info/App Name", inst.NodeName),
+		sparkplug.NewMetric("Node Info/Instance ID", inst.InstanceID),
+		sparkplug.NewMetric("Node Info/Hostname", inst.Hostname),
+		sparkplug.NewMetric("Node Info/PID", int64(inst.PID)),
+	}
+	if inst.Namespace != "" {
+		metrics = append(metrics, sparkplug.NewMetric("Node Info/Namespace", inst.Namespace))
+	}
+	metrics = append(metrics, sortedStringMetrics("Labels/", inst.config.Labels)...)
+	metrics = append(metrics, sortedStringMetrics("Metadata/", inst.config.Metadata)...)
+	metrics = append(metrics, sparkplugDataMetrics(receiver)...)
+
+	// 允许通过 NCMD 执行的命令作为可写的字符串指标公布，运行中注册的命令在下次 NBIRTH 时公布
+	for _, info := range receiver.Handlers() {
+		if inst.config.Sparkplug.allows(info.Name) {
+			metrics = append(metrics, sparkplug.NewMetric(sparkplugCommandPrefix+info.Name, ""))
+		}
+	}
+	return append(metrics, sparkplug.NewMetric(sparkplugReplyMetric, ""))
+}
+
+// sparkplugDataMetrics 生成 NDATA 中周期发布的状态和进程指标
+func sparkplugDataMetrics(receiver *nodesync.CommandReceiver) []sparkplug.Metric {
+	m := protocol.CollectProcessMetrics()
+	return []sparkplug.Metric{
+		sparkplug.NewMetric("Status", string(receiver.GetStatus())),
+		sparkplug.NewMetric("Process/CPU Usage Percent", m.CPUUsagePercent),
+		sparkplug.NewMetric("Process/Memory Usage MB", int64(m.MemoryUsageMB)),
+		sparkplug.NewMetric("Process/Goroutine Count", int64(m.GoroutineCount)),
+	}
+}
+
+// sortedStringMetrics 按键排序生成字符串指标
+func sortedStringMetrics(prefix string, values map[string]string) []sparkplug.Metric {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	metrics := make([]sparkplug.Metric, 0, len(keys))
+	for _, k := range keys {
+		metrics = append(metrics, sparkplug.NewMetric(prefix+k, values[k]))
+	}
+	return metrics
+}
+
+// handleSparkplugCommand 将 NCMD 中的 Commands/{command} 指标转换为命令交给接收器执行
+// 只执行 SparkplugConfig.Commands 中的命令，应答以 JSON 字符串发布到 NDATA 的 Commands/Reply 指标
+func (inst *Instance) handleSparkplugCommand(edge *sparkplug.EdgeNode, receiver *nodesync.CommandReceiver, metrics []sparkplug.Metric) {
+	source := sparkplug.Topic(edge.GroupID(), sparkplug.NCMD, edge.EdgeNodeID())
+	for _, m := range metrics {
+		name, ok := strings.CutPrefix(m.Name, sparkplugCommandPrefix)
+		if !ok || name == "" || m.Name == sparkplugReplyMetric {
+			log.Printf("[%s] 忽略 Sparkplug 指标写入: %s", inst.InstanceID, m.Name)
+			continue
+		}
+		if !inst.config.Sparkplug.allows(name) {
+			log.Printf("[%s] 忽略未允许通过 NCMD 执行的命令: %s", inst.InstanceID, name)
+			continue
+		}
+
+		now := time.Now()
+		cmd := &nodesync.Command{
+			Command:    name,
+			Timestamp:  now.Format(time.RFC3339),
+			RequestID:  fmt.Sprintf("ncmd-%s-%d", name, now.UnixNano()),
+			Parameters: sparkplugParameters(m.Value),
+			Scope:      nodesync.ScopeInstance,
+			Target:     inst.InstanceID,
+			Caller:     SparkplugCaller,
+			Namespace:  inst.Namespace,
+		}
+		receiver.SubmitUnsigned(source, cmd, func(reply *nodesync.CommandReply) {
+			data, err := json.Marshal(reply)
+			if err == nil {
+				err = edge.PublishData(sparkplug.NewMetric(sparkplugReplyMetric, string(data)))
+			}
+			if err != nil {
+				log.Printf("[%s] 发布 Sparkplug 命令应答失败: %v", inst.InstanceID, err)
+			}
+		})
+	}
+}
+
+// sparkplugParameters 将写入的指标值转换为命令参数
+// JSON 对象字符串解析为参数，其他非空值作为 value 参数
+func sparkplugParameters(value interface{}) map[string]interface{} {
+	if s, ok := value.(string); ok {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return nil
+		}
+		var params map[string]interface{}
+		if strings.HasPrefix(s, "{") && json.Unmarshal([]byte(s), &params) == nil {
+			return params
+		}
+		return map[string]interface{}{"value": s}
+	}
+	if value == nil {
+		return nil
+	}
+	return map[string]interface{}{"value": value}
+}
+
+// getSparkplugConfig 根据环境变量生成 Sparkplug 配置
+// SPARKPLUG_ENABLED 为 true 或设置了 SPARKPLUG_GROUP_ID 时启用，否则返回nil
+// SPARKPLUG_COMMANDS 为逗号分隔的允许通过 NCMD 执行的命令
+func getSparkplugConfig() *SparkplugConfig {
+	enabled, _ := strconv.ParseBool(os.Getenv("SPARKPLUG_ENABLED"))
+	config := &SparkplugConfig{
+		GroupID:    os.Getenv("SPARKPLUG_GROUP_ID"),
+		EdgeNodeID: os.Getenv("SPARKPLUG_EDGE_NODE_ID"),
+	}
+	if !enabled && config.GroupID == "" {
+		return nil
+	}
+	for _, name := range strings.Split(os.Getenv("SPARKPLUG_COMMANDS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			config.Commands = append(config.Commands, name)
+		}
+	}
+	return config
+}
@@ -0,0 +1,310 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/HY-805/SubNodeSync/pkg/codec"
+	"github.com/HY-805/SubNodeSync/pkg/sparkplug"
+	nodesync "github.com/HY-805/SubNodeSync/pkg/sync"
+	"github.com/HY-805/SubNodeSync/pkg/transport"
+)
+
+const testTimeout = 5 * time.Second
+
+// newTestInstance 创建使用进程内 broker 的节点实例，与 RegisterWithConfig 相同但不设置全局实例、不进行 HTTP 注册
+// factory 为nil时所有连接都使用 MemoryTransport
+func newTestInstance(t *testing.T, broker *transport.MemoryBroker, config *Config, factory func(clientID string) transport.Transport) *Instance {
+	t.Helper()
+	if factory == nil {
+		factory = func(string) transport.Transport { return transport.NewMemoryTransport(broker) }
+	}
+	config.TransportFactory = factory
+	config.Codec = codec.Default()
+	config.HeartbeatInterval = time.Hour // 不发布周期 NDATA，测试只关心命令应答
+
+	topics, err := resolveTopicScheme(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	inst := &Instance{
+		Namespace:  config.Namespace,
+		NodeName:   "app",
+		InstanceID: GetNamespacedInstanceID(config.Namespace, "app"),
+		ctx:        ctx,
+		cancel:     cancel,
+		config:     config,
+		topics:     topics,
+	}
+	inst.conn = transport.NewConnectionManager(inst.newTransport(""))
+	inst.receiver = inst.newCommandReceiver()
+	t.Cleanup(inst.Stop)
+	return inst
+}
+
+// sparkplugMessage 边缘节点发布的 Sparkplug 消息
+type sparkplugMessage struct {
+	msgType sparkplug.MessageType
+	payload *sparkplug.Payload
+}
+
+// sparkplugWatcher 模拟 SCADA 主机，订阅边缘节点的 NBIRTH、NDATA 和 NDEATH，返回的函数用于写入 NCMD
+func sparkplugWatcher(t *testing.T, broker *transport.MemoryBroker, groupID, edgeNodeID string) (<-chan sparkplugMessage, func(metrics ...sparkplug.Metric)) {
+	t.Helper()
+	host := transport.NewMemoryTransport(broker)
+	if err := host.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(host.Disconnect)
+
+	msgs := make(chan sparkplugMessage, 16)
+	for _, msgType := range []sparkplug.MessageType{sparkplug.NBIRTH, sparkplug.NDATA, sparkplug.NDEATH} {
+		msgType := msgType
+		host.Subscribe(sparkplug.Topic(groupID, msgType, edgeNodeID), 1, func(msg *transport.Message) {
+			payload, err := sparkplug.Unmarshal(msg.Payload)
+			if err != nil {
+				t.Errorf("unmarshal %s: %v", msgType, err)
+				return
+			}
+			msgs <- sparkplugMessage{msgType, payload}
+		})
+	}
+
+	command := func(metrics ...sparkplug.Metric) {
+		t.Helper()
+		payload, err := (&sparkplug.Payload{Timestamp: uint64(time.Now().UnixMilli()), Metrics: metrics}).Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := host.Publish(sparkplug.Topic(groupID, sparkplug.NCMD, edgeNodeID), 0, false, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return msgs, command
+}
+
+// expectSparkplug 等待下一条 Sparkplug 消息并检查类型
+func expectSparkplug(t *testing.T, msgs <-chan sparkplugMessage, msgType sparkplug.MessageType) *sparkplug.Payload {
+	t.Helper()
+	select {
+	case msg := <-msgs:
+		if msg.msgType != msgType {
+			t.Fatalf("got %s, want %s", msg.msgType, msgType)
+		}
+		return msg.payload
+	case <-time.After(testTimeout):
+		t.Fatalf("no %s published", msgType)
+		return nil
+	}
+}
+
+// expectNoSparkplug 确认短时间内没有发布 Sparkplug 消息
+func expectNoSparkplug(t *testing.T, msgs <-chan sparkplugMessage) {
+	t.Helper()
+	select {
+	case msg := <-msgs:
+		t.Fatalf("unexpected %s: %+v", msg.msgType, msg.payload.Metrics)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// metricNames 返回负载中的指标名称
+func metricNames(payload *sparkplug.Payload) map[string]bool {
+	names := make(map[string]bool, len(payload.Metrics))
+	for _, m := range payload.Metrics {
+		names[m.Name] = true
+	}
+	return names
+}
+
+// echoHandler 将命令参数作为结果数据返回并统计调用次数
+func echoHandler(name string, calls *atomic.Int32) nodesync.CommandHandler {
+	return nodesync.NewCustomHandler(name, func(ctx context.Context, cmd *nodesync.Command) (*nodesync.CommandResult, error) {
+		calls.Add(1)
+		return nodesync.NewSuccessResult(cmd.RequestID, "ok", cmd.Parameters), nil
+	})
+}
+
+func TestSparkplugConfigResolve(t *testing.T) {
+	tests := []struct {
+		config                *SparkplugConfig
+		namespace             string
+		wantGroup, wantEdgeID string
+	}{
+		{&SparkplugConfig{}, "", "app", "app-1"},
+		// 不同租户的同名节点使用不同的组
+		{&SparkplugConfig{}, "tenant", "tenant-app", "app-1"},
+		{&SparkplugConfig{GroupID: "line1", EdgeNodeID: "edge"}, "tenant", "line1", "edge"},
+	}
+	for _, tt := range tests {
+		group, edge := tt.config.resolve(tt.namespace, "app", "app-1")
+		if group != tt.wantGroup || edge != tt.wantEdgeID {
+			t.Errorf("resolve(%q) = %s/%s, want %s/%s", tt.namespace, group, edge, tt.wantGroup, tt.wantEdgeID)
+		}
+	}
+}
+
+func TestSparkplugParameters(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  map[string]interface{}
+	}{
+		{nil, nil},
+		{"", nil},
+		{"  ", nil},
+		{`{"level": "debug", "n": 2}`, map[string]interface{}{"level": "debug", "n": float64(2)}},
+		{"debug", map[string]interface{}{"value": "debug"}},
+		{"{not json", map[string]interface{}{"value": "{not json"}},
+		{int64(5), map[string]interface{}{"value": int64(5)}},
+		{true, map[string]interface{}{"value": true}},
+	}
+	for _, tt := range tests {
+		if got := sparkplugParameters(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sparkplugParameters(%#v) = %#v, want %#v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSparkplugCommands(t *testing.T) {
+	broker := transport.NewMemoryBroker()
+	inst := newTestInstance(t, broker, &Config{
+		Namespace: "tenant",
+		Sparkplug: &SparkplugConfig{Commands: []string{"echo"}},
+	}, nil)
+	var echoCalls, purgeCalls atomic.Int32
+	inst.receiver.RegisterHandler("echo", echoHandler("echo", &echoCalls))
+	inst.receiver.RegisterHandler("purge", echoHandler("purge", &purgeCalls))
+
+	// 默认组ID包含命名空间
+	msgs, command := sparkplugWatcher(t, broker, "tenant-app", inst.InstanceID)
+	if err := inst.connectMQTT(); err != nil {
+		t.Fatal(err)
+	}
+
+	// NBIRTH 只公布允许通过 NCMD 执行的命令
+	birth := metricNames(expectSparkplug(t, msgs, sparkplug.NBIRTH))
+	if !birth["Commands/echo"] || !birth[sparkplugReplyMetric] || !birth["Node Info/Namespace"] {
+		t.Fatalf("NBIRTH metrics = %v", birth)
+	}
+	for _, name := range []string{"Commands/purge", "Commands/stop", "Commands/restart"} {
+		if birth[name] {
+			t.Errorf("NBIRTH advertises %s, which is not allowed over NCMD", name)
+		}
+	}
+
+	// 未允许的命令、应答指标和非命令指标被忽略，只执行 echo
+	command(
+		sparkplug.NewMetric("Commands/purge", ""),
+		sparkplug.NewMetric(sparkplugReplyMetric, "forged"),
+		sparkplug.NewMetric("Node Info/PID", int64(1)),
+		sparkplug.NewMetric("Commands/echo", `{"msg": "hi"}`),
+	)
+	data := expectSparkplug(t, msgs, sparkplug.NDATA)
+	if len(data.Metrics) != 1 || data.Metrics[0].Name != sparkplugReplyMetric {
+		t.Fatalf("NDATA metrics = %+v, want a single %s", data.Metrics, sparkplugReplyMetric)
+	}
+	var reply nodesync.CommandReply
+	if err := json.Unmarshal([]byte(data.Metrics[0].Value.(string)), &reply); err != nil {
+		t.Fatalf("decode reply: %v", err)
+	}
+	if !reply.Success || reply.Command != "echo" || !strings.HasPrefix(reply.RequestID, "ncmd-echo-") ||
+		!reflect.DeepEqual(reply.Data, map[string]interface{}{"msg": "hi"}) {
+		t.Fatalf("reply = %+v", reply)
+	}
+	expectNoSparkplug(t, msgs)
+	if echoCalls.Load() != 1 || purgeCalls.Load() != 0 {
+		t.Fatalf("echo called %d times, purge %d times; want 1 and 0", echoCalls.Load(), purgeCalls.Load())
+	}
+
+	// 停止时发布 NDEATH
+	inst.Stop()
+	expectSparkplug(t, msgs, sparkplug.NDEATH)
+}
+
+func TestSparkplugCommandsDisabledByDefault(t *testing.T) {
+	broker := transport.NewMemoryBroker()
+	inst := newTestInstance(t, broker, &Config{Sparkplug: &SparkplugConfig{}}, nil)
+	var calls atomic.Int32
+	inst.receiver.RegisterHandler("echo", echoHandler("echo", &calls))
+
+	msgs, command := sparkplugWatcher(t, broker, "app", inst.InstanceID)
+	if err := inst.connectMQTT(); err != nil {
+		t.Fatal(err)
+	}
+	birth := metricNames(expectSparkplug(t, msgs, sparkplug.NBIRTH))
+	for name := range birth {
+		if strings.HasPrefix(name, sparkplugCommandPrefix) && name != sparkplugReplyMetric {
+			t.Errorf("NBIRTH advertises %s with an empty allow-list", name)
+		}
+	}
+
+	command(sparkplug.NewMetric("Commands/echo", ""), sparkplug.NewMetric("Commands/stop", ""))
+	expectNoSparkplug(t, msgs)
+	if calls.Load() != 0 {
+		t.Fatalf("echo called %d times", calls.Load())
+	}
+}
+
+// gatedTransport Connect 阻塞到 release 关闭，开始连接时关闭 connecting
+type gatedTransport struct {
+	*transport.MemoryTransport
+	connecting chan struct{}
+	release    chan struct{}
+}
+
+func (t *gatedTransport) Connect() error {
+	close(t.connecting)
+	<-t.release
+	return t.MemoryTransport.Connect()
+}
+
+func TestStopDuringSparkplugStart(t *testing.T) {
+	broker := transport.NewMemoryBroker()
+	edgeTransport := &gatedTransport{
+		MemoryTransport: transport.NewMemoryTransport(broker),
+		connecting:      make(chan struct{}),
+		release:         make(chan struct{}),
+	}
+	inst := newTestInstance(t, broker, &Config{Sparkplug: &SparkplugConfig{}}, func(clientID string) transport.Transport {
+		if strings.HasSuffix(clientID, "-sparkplug") {
+			return edgeTransport
+		}
+		return transport.NewMemoryTransport(broker)
+	})
+
+	msgs, _ := sparkplugWatcher(t, broker, "app", inst.InstanceID)
+	if err := inst.connectMQTT(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-edgeTransport.connecting:
+	case <-time.After(testTimeout):
+		t.Fatal("sparkplug edge node did not start connecting")
+	}
+
+	// 边缘节点连接期间实例停止，Stop 看不到边缘节点
+	inst.Stop()
+	close(edgeTransport.release)
+
+	// 启动完成后由 startSparkplug 发布 NDEATH 并断开，不会留下在线的边缘节点
+	expectSparkplug(t, msgs, sparkplug.NBIRTH)
+	expectSparkplug(t, msgs, sparkplug.NDEATH)
+	deadline := time.Now().Add(testTimeout)
+	for edgeTransport.IsConnected() {
+		if time.Now().After(deadline) {
+			t.Fatal("edge node still connected after Stop")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	inst.mu.RLock()
+	defer inst.mu.RUnlock()
+	if inst.edgeNode != nil {
+		t.Fatal("edge node attached to a stopped instance")
+	}
+}
@@ -0,0 +1,178 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/protocol/envelope.go
+ * 消息信封 - 所有消息统一的版本化外层结构
+ *
+ *	{
+ *	  "v": 1,
+ *	  "type": "heartbeat",
+ *	  "source": "my-app-hostname-12345",
+ *	  "timestamp": "2024-01-01T12:00:00Z",
+ *	  "correlation_id": "req-123",
+ *	  "content_type": "application/json",
+ *	  "body": { ... }
+ *	}
+ *
+ * 早期版本的消息没有信封，消息体直接作为负载发送。
+ * Decode 将这类消息识别为 LegacyVersion，DecodeBody 会把旧字段转换为当前结构。
+ *
+ * content_type 声明消息使用的编解码器，缺省为 JSON；与识别出的格式不一致的消息会被拒绝。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/HY-805/SubNodeSync/pkg/codec"
+)
+
+// 协议版本
+const (
+	LegacyVersion  = 0 // 无信封的旧版本消息
+	CurrentVersion = 1 // 当前版本
+)
+
+// MessageType 消息类型
+type MessageType string
+
+const (
+	TypeRegister  MessageType = "register"
+	TypeHeartbeat MessageType = "heartbeat"
+	TypeStatus    MessageType = "status"
+	TypeLog       MessageType = "log"
+	TypeCommand   MessageType = "command"
+	TypeReply     MessageType = "reply"
+	TypeState     MessageType = "state"
+)
+
+// Envelope 消息信封
+type Envelope struct {
+	Version       int         `json:"v"`
+	Type          MessageType `json:"type"`
+	Source        string      `json:"source"`
+	Timestamp     string      `json:"timestamp"`
+	CorrelationID string      `json:"correlation_id,omitempty"`
+	ContentType   string      `json:"content_type,omitempty"`
+	Body          interface{} `json:"body"`
+}
+
+// NewEnvelope 创建当前版本的消息信封
+func NewEnvelope(msgType MessageType, source string, body interface{}) *Envelope {
+	return &Envelope{
+		Version:   CurrentVersion,
+		Type:      msgType,
+		Source:    source,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Body:      body,
+	}
+}
+
+// WithCorrelationID 设置关联ID，如命令应答对应的 RequestID
+func (e *Envelope) WithCorrelationID(id string) *Envelope {
+	e.CorrelationID = id
+	return e
+}
+
+// Encode 使用指定编解码器序列化消息信封，并在信封中声明内容类型
+func Encode(c codec.Codec, env *Envelope) ([]byte, error) {
+	if c == nil {
+		c = codec.Default()
+	}
+	env.ContentType = c.ContentType()
+	return codec.Encode(c, env)
+}
+
+// Decode 解析消息，兼容无信封的旧版本消息
+// 返回消息使用的编解码器，应答应使用相同的格式
+func Decode(data []byte) (*Envelope, codec.Codec, error) {
+	var raw map[string]interface{}
+	c, err := codec.Decode(data, &raw)
+	if err != nil {
+		return nil, c, err
+	}
+
+	if !isEnvelope(raw) {
+		return &Envelope{Version: LegacyVersion, Body: raw}, c, nil
+	}
+
+	env := &Envelope{Body: raw["body"]}
+	env.Version = toInt(raw["v"])
+	if s, ok := raw["type"].(string); ok {
+		env.Type = MessageType(s)
+	}
+	env.Source, _ = raw["source"].(string)
+	env.Timestamp, _ = raw["timestamp"].(string)
+	env.CorrelationID, _ = raw["correlation_id"].(string)
+	env.ContentType, _ = raw["content_type"].(string)
+	if env.ContentType != "" && env.ContentType != c.ContentType() {
+		return nil, c, fmt.Errorf("content type %s does not match %s payload", env.ContentType, c.ContentType())
+	}
+	return env, c, nil
+}
+
+// DecodeBody 将消息体解码到 v，旧版本消息会先转换为当前结构
+// 新版本消息中的未知字段会被忽略
+func (e *Envelope) DecodeBody(v interface{}) error {
+	body := e.Body
+	if e.Version == LegacyVersion {
+		if m, ok := body.(map[string]interface{}); ok {
+			body = upgradeLegacy(m, v)
+		}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal %s body: %w", e.Type, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode %s body (v%d): %w", e.Type, e.Version, err)
+	}
+	return nil
+}
+
+// BodyJSON 返回消息体的JSON序列化结果，数值与收到的消息一致（不经过 float64 转换）
+func (e *Envelope) BodyJSON() ([]byte, error) {
+	data, err := json.Marshal(e.Body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s body: %w", e.Type, err)
+	}
+	return data, nil
+}
+
+// isEnvelope 判断消息是否带有信封
+func isEnvelope(raw map[string]interface{}) bool {
+	_, hasVersion := raw["v"]
+	_, hasType := raw["type"]
+	_, hasBody := raw["body"]
+	return hasVersion && hasType && hasBody
+}
+
+// toInt 将解码得到的数值转换为int，不同编解码器的数值类型不同
+func toInt(v interface{}) int {
+	switch n := v.(type) {
+	case json.Number:
+		i, _ := n.Int64()
+		return int(i)
+	case float64:
+		return int(n)
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case uint64:
+		return int(n)
+	case int:
+		return n
+	case int8:
+		return int(n)
+	case uint8:
+		return int(n)
+	}
+	return 0
+}
@@ -0,0 +1,114 @@
+package protocol
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/HY-805/SubNodeSync/pkg/codec"
+)
+
+type testCommandBody struct {
+	Command    string                 `json:"command"`
+	RequestID  string                 `json:"request_id"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+var allCodecs = []codec.Codec{codec.JSON, codec.CBOR, codec.MsgPack, Protobuf}
+
+func nestedCommand() *testCommandBody {
+	return &testCommandBody{
+		Command:   "config.update",
+		RequestID: "req-1",
+		Parameters: map[string]interface{}{
+			"key":     "mode",
+			"enabled": true,
+			"limits":  map[string]interface{}{"cpu": 1.5, "mem": 512, "tags": []interface{}{"a", "b"}},
+			"items":   []interface{}{map[string]interface{}{"id": 1}, map[string]interface{}{"id": 2}},
+		},
+	}
+}
+
+// canonicalJSON 经过 JSON 转换后比较，不同格式解码得到的数值类型不同
+func canonicalJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal %T: %v", v, err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = json.Marshal(out)
+	return string(data)
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	for _, c := range allCodecs {
+		t.Run(c.ContentType(), func(t *testing.T) {
+			in := nestedCommand()
+			env := NewEnvelope(TypeCommand, "ops", in).WithCorrelationID("corr-1")
+			data, err := Encode(c, env)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+
+			got, detected, err := Decode(data)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if detected != c {
+				t.Fatalf("detected %s", detected.ContentType())
+			}
+			if got.Version != CurrentVersion || got.Type != TypeCommand || got.Source != "ops" ||
+				got.Timestamp != env.Timestamp || got.CorrelationID != "corr-1" || got.ContentType != c.ContentType() {
+				t.Fatalf("envelope = %+v", got)
+			}
+
+			var body testCommandBody
+			if err := got.DecodeBody(&body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if canonicalJSON(t, &body) != canonicalJSON(t, in) {
+				t.Fatalf("body = %s, want %s", canonicalJSON(t, &body), canonicalJSON(t, in))
+			}
+		})
+	}
+}
+
+func TestDecodeRejectsContentTypeMismatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		c           codec.Codec
+		contentType string
+	}{
+		{"unknown codec", codec.JSON, "application/x-yaml"},
+		{"json declared as cbor", codec.JSON, codec.ContentTypeCBOR},
+		{"msgpack declared as json", codec.MsgPack, codec.ContentTypeJSON},
+		{"protobuf with unknown codec", Protobuf, "application/x-yaml"},
+	}
+	for _, tt := range tests {
+		env := NewEnvelope(TypeStatus, "node", map[string]interface{}{"status": "running"})
+		env.ContentType = tt.contentType
+		data, err := codec.Encode(tt.c, env)
+		if err != nil {
+			t.Fatalf("%s: encode: %v", tt.name, err)
+		}
+		if _, _, err := Decode(data); err == nil || !strings.Contains(err.Error(), "does not match") {
+			t.Errorf("%s: Decode error = %v, want content type mismatch", tt.name, err)
+		}
+	}
+}
+
+func TestDecodeWithoutContentType(t *testing.T) {
+	// 未声明 content_type 的信封按识别出的格式解码
+	data := []byte(`{"v":1,"type":"status","source":"node","timestamp":"","body":{"status":"running"}}`)
+	env, c, err := Decode(data)
+	if err != nil || c != codec.JSON {
+		t.Fatalf("Decode = %v, %v", c, err)
+	}
+	if env.Version != CurrentVersion || env.Type != TypeStatus {
+		t.Fatalf("envelope = %+v", env)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/protocol/messages.go
+ * 消息体定义 - 注册、心跳、状态、在线状态、日志消息
+ *
+ * 命令和命令应答的消息体为 pkg/sync 中的 Command 和 CommandReply。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package protocol
+
+import (
+	"strconv"
+)
+
+// BuildInfo 构建版本信息
+type BuildInfo struct {
+	GitVersion   string `json:"git_version"`
+	GitCommit    string `json:"git_commit"`
+	GitTreeState string `json:"git_tree_state"`
+	BuildDate    string `json:"build_date"`
+	GoVersion    string `json:"go_version"`
+	Compiler     string `json:"compiler"`
+	Platform     string `json:"platform"`
+}
+
+// RegisterBody 注册消息
+type RegisterBody struct {
+	Namespace    string            `json:"namespace,omitempty"`
+	AppName      string            `json:"app_name"`
+	InstanceID   string            `json:"instance_id"`
+	Version      string            `json:"version"`
+	PID          int               `json:"pid"`
+	StartTime    string            `json:"start_time"`
+	Capabilities []string          `json:"capabilities"`
+	Commands     interface{}       `json:"commands,omitempty"` // []sync.CommandInfo
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	AppVersion   *BuildInfo        `json:"app_version,omitempty"`
+}
+
+// ProcessMetrics 进程监控指标
+type ProcessMetrics struct {
+	CPUUsagePercent float64 `json:"process_cpu_usage_percent"`
+	MemoryUsageMB   int     `json:"process_memory_usage_mb"`
+	GoroutineCount  int     `json:"process_goroutine_count"`
+}
+
+// HeartbeatBody 心跳消息
+type HeartbeatBody struct {
+	Namespace  string            `json:"namespace,omitempty"`
+	AppName    string            `json:"app_name"`
+	InstanceID string            `json:"instance_id"`
+	Status     string            `json:"status"`
+	PID        int               `json:"pid"`
+	Uptime     int64             `json:"uptime"`
+	Version    string            `json:"version,omitempty"`
+	Hostname   string            `json:"hostname"`
+	Metrics    ProcessMetrics    `json:"metrics"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	AppVersion *BuildInfo        `json:"app_version,omitempty"`
+}
+
+// StatusBody 状态消息
+type StatusBody struct {
+	Status  string            `json:"status"`
+	PID     int               `json:"pid,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// 实例在线状态
+const (
+	StateOnline  = "online"
+	StateOffline = "offline"
+)
+
+// 离线原因
+const (
+	OfflineReasonConnectionLost = "connection_lost" // 连接异常断开，由 broker 发布的遗嘱消息
+	OfflineReasonShutdown       = "shutdown"        // 实例正常停止
+)
+
+// StateBody 实例在线状态消息，以保留消息发布到实例的 state 主题
+// 连接成功时发布 online，正常停止时发布 offline/shutdown，
+// 连接异常断开时由 broker 发布预先注册的遗嘱 offline/connection_lost
+type StateBody struct {
+	Namespace  string `json:"namespace,omitempty"`
+	AppName    string `json:"app_name"`
+	InstanceID string `json:"instance_id"`
+	State      string `json:"state"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// LogBody 日志消息
+type LogBody struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+	Source  string `json:"source,omitempty"`
+}
+
+// upgradeLegacy 将旧版本消息体转换为当前结构
+func upgradeLegacy(body map[string]interface{}, target interface{}) map[string]interface{} {
+	if _, ok := target.(*HeartbeatBody); !ok {
+		return body
+	}
+
+	upgraded := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		upgraded[k] = v
+	}
+
+	metrics, _ := upgraded["metrics"].(map[string]interface{})
+	if metrics == nil {
+		// pkg/transport 的旧心跳：顶层 cpu、memory 字段
+		metrics = map[string]interface{}{
+			"process_cpu_usage_percent": upgraded["cpu"],
+			"process_memory_usage_mb":   upgraded["memory"],
+		}
+		delete(upgraded, "cpu")
+		delete(upgraded, "memory")
+	} else {
+		copied := make(map[string]interface{}, len(metrics))
+		for k, v := range metrics {
+			copied[k] = v
+		}
+		metrics = copied
+	}
+
+	// 旧心跳的CPU使用率为字符串，如 "2.50"
+	if s, ok := metrics["process_cpu_usage_percent"].(string); ok {
+		cpu, _ := strconv.ParseFloat(s, 64)
+		metrics["process_cpu_usage_percent"] = cpu
+	}
+	for k, v := range metrics {
+		if v == nil {
+			delete(metrics, k)
+		}
+	}
+	upgraded["metrics"] = metrics
+	return upgraded
+}
@@ -0,0 +1,90 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/HY-805/SubNodeSync/pkg/codec"
+)
+
+func TestDecodeLegacyHeartbeat(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    ProcessMetrics
+	}{
+		{
+			"top-level cpu and memory",
+			`{"app_name":"app","instance_id":"app-1","status":"running","pid":7,"cpu":"2.50","memory":128}`,
+			ProcessMetrics{CPUUsagePercent: 2.5, MemoryUsageMB: 128},
+		},
+		{
+			"metrics with string cpu",
+			`{"app_name":"app","instance_id":"app-1","status":"running","pid":7,"metrics":{"process_cpu_usage_percent":"0.75","process_memory_usage_mb":64,"process_goroutine_count":9}}`,
+			ProcessMetrics{CPUUsagePercent: 0.75, MemoryUsageMB: 64, GoroutineCount: 9},
+		},
+		{
+			"missing metrics",
+			`{"app_name":"app","instance_id":"app-1","status":"running","pid":7}`,
+			ProcessMetrics{},
+		},
+	}
+	for _, tt := range tests {
+		env, c, err := Decode([]byte(tt.payload))
+		if err != nil || c != codec.JSON {
+			t.Fatalf("%s: Decode = %v, %v", tt.name, c, err)
+		}
+		if env.Version != LegacyVersion {
+			t.Fatalf("%s: version = %d, want legacy", tt.name, env.Version)
+		}
+
+		before := canonicalJSON(t, env.Body)
+		var hb HeartbeatBody
+		if err := env.DecodeBody(&hb); err != nil {
+			t.Fatalf("%s: DecodeBody: %v", tt.name, err)
+		}
+		if hb.AppName != "app" || hb.InstanceID != "app-1" || hb.PID != 7 || hb.Metrics != tt.want {
+			t.Errorf("%s: heartbeat = %+v, want metrics %+v", tt.name, hb, tt.want)
+		}
+		// 转换不修改原消息体
+		if after := canonicalJSON(t, env.Body); after != before {
+			t.Errorf("%s: legacy body modified to %s", tt.name, after)
+		}
+	}
+}
+
+func TestDecodeLegacyOtherBodies(t *testing.T) {
+	// 只有心跳需要转换，其他消息体按原样解码
+	env, _, err := Decode([]byte(`{"status":"stopped","pid":3,"cpu":"1.0"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status StatusBody
+	if err := env.DecodeBody(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != "stopped" || status.PID != 3 {
+		t.Fatalf("status = %+v", status)
+	}
+}
+
+func TestDecodeVersionedHeartbeatIsNotUpgraded(t *testing.T) {
+	data, err := Encode(codec.JSON, NewEnvelope(TypeHeartbeat, "app-1", map[string]interface{}{
+		"app_name": "app",
+		"cpu":      "2.50",
+		"metrics":  map[string]interface{}{"process_cpu_usage_percent": 1.25},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, _, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hb HeartbeatBody
+	if err := env.DecodeBody(&hb); err != nil {
+		t.Fatal(err)
+	}
+	if env.Version != CurrentVersion || hb.Metrics.CPUUsagePercent != 1.25 {
+		t.Fatalf("heartbeat v%d = %+v", env.Version, hb)
+	}
+}
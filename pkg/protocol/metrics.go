@@ -0,0 +1,46 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/protocol/metrics.go
+ * 进程指标采集 - 心跳消息统一使用的监控指标
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package protocol
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// cpuSampleInterval CPU使用率采样时长
+const cpuSampleInterval = 500 * time.Millisecond
+
+// CollectProcessMetrics 采集当前进程的监控指标
+// CPU使用率需要采样，调用会阻塞约500毫秒
+func CollectProcessMetrics() ProcessMetrics {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	metrics := ProcessMetrics{
+		MemoryUsageMB:  int(m.Alloc / 1024 / 1024),
+		GoroutineCount: runtime.NumGoroutine(),
+	}
+
+	p, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		return metrics
+	}
+	if memInfo, err := p.MemoryInfo(); err == nil {
+		metrics.MemoryUsageMB = int(memInfo.RSS / 1024 / 1024)
+	}
+	if cpuPercent, err := p.Percent(cpuSampleInterval); err == nil {
+		metrics.CPUUsagePercent = math.Round(cpuPercent*100) / 100
+	}
+	return metrics
+}
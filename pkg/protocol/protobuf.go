@@ -0,0 +1,354 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/protocol/protobuf.go
+ * Protobuf 编解码器 - 按 protocol.proto 定义编码消息信封
+ *
+ * 消息信封先转换为 JSON 结构，再按字段名写入 protocol.proto 生成的 pb.Envelope：
+ * 消息体放入 Envelope.body 中与消息类型同名的字段，没有定义的类型放入 other。
+ * 编码时遇到未定义的字段返回错误，避免字段被静默丢弃；解码时忽略未知字段。
+ *
+ * pb 包由 protoc-gen-go 根据 protocol.proto 生成，其他语言可直接使用同一文件生成代码。
+ * 编码后的信封以 v 字段（字段1，varint）开始，首字节为 0x08，codec.Decode 据此识别格式。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/HY-805/SubNodeSync/pkg/codec"
+	"github.com/HY-805/SubNodeSync/pkg/protocol/pb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=module=github.com/HY-805/SubNodeSync ../../pkg/protocol/protocol.proto
+
+// Protobuf 按 protocol.proto 编码消息信封的编解码器
+var Protobuf codec.Codec = protobufCodec{}
+
+func init() {
+	codec.Register(Protobuf)
+}
+
+// envelopeDescriptor 生成的信封消息的描述符
+var envelopeDescriptor = (&pb.Envelope{}).ProtoReflect().Descriptor()
+
+// protobufCodec Protobuf编解码器
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string { return codec.ContentTypeProtobuf }
+
+// Detect 信封的首个字段为 v（字段1，varint）
+func (protobufCodec) Detect(data []byte) bool {
+	return len(data) > 0 && data[0] == 0x08
+}
+
+// Marshal 编码消息信封，proto.Message 直接编码
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Marshal(m)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil || !isEnvelope(fields) {
+		return nil, errors.New("protobuf codec only encodes message envelopes")
+	}
+
+	msgType, _ := fields["type"].(string)
+	if body := fields["body"]; body != nil {
+		fields[bodyField(msgType)] = body
+	}
+	delete(fields, "body")
+
+	env := &pb.Envelope{}
+	if err := setMessage(env.ProtoReflect(), fields); err != nil {
+		return nil, fmt.Errorf("encode %s envelope: %w", msgType, err)
+	}
+	return proto.MarshalOptions{Deterministic: true}.Marshal(env)
+}
+
+// Unmarshal 解码消息信封，v 为 *map[string]interface{} 时直接返回 JSON 结构
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+
+	env := &pb.Envelope{}
+	if err := proto.Unmarshal(data, env); err != nil {
+		return err
+	}
+	m := env.ProtoReflect()
+	fields, err := messageFields(m)
+	if err != nil {
+		return err
+	}
+
+	// proto3 不编码零值，信封的标量字段补齐默认值
+	body := envelopeDescriptor.Oneofs().ByName("body")
+	for i := 0; i < envelopeDescriptor.Fields().Len(); i++ {
+		fd := envelopeDescriptor.Fields().Get(i)
+		if fd.ContainingOneof() == nil && !m.Has(fd) {
+			fields[string(fd.Name())] = m.Get(fd).Interface()
+		}
+	}
+	fields["body"] = nil
+	if fd := m.WhichOneof(body); fd != nil {
+		fields["body"] = fields[string(fd.Name())]
+		delete(fields, string(fd.Name()))
+	}
+
+	if raw, ok := v.(*map[string]interface{}); ok {
+		*raw = fields
+		return nil
+	}
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// bodyField 返回消息类型对应的消息体字段
+func bodyField(msgType string) string {
+	fd := envelopeDescriptor.Fields().ByName(protoreflect.Name(msgType))
+	if fd == nil || fd.ContainingOneof() == nil {
+		return "other"
+	}
+	return msgType
+}
+
+// setMessage 将 JSON 结构按字段名写入消息
+func setMessage(m protoreflect.Message, fields map[string]interface{}) error {
+	desc := m.Descriptor()
+	for name, value := range fields {
+		if value == nil {
+			continue
+		}
+		fd := desc.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			return fmt.Errorf("field %q is not defined in %s", name, desc.FullName())
+		}
+
+		switch {
+		case fd.IsMap():
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("field %q: expected object", name)
+			}
+			mp := m.Mutable(fd).Map()
+			for k, item := range obj {
+				if item == nil {
+					continue
+				}
+				key := protoreflect.ValueOfString(k).MapKey()
+				if fd.MapValue().Message() != nil {
+					if err := setValue(mp.Mutable(key).Message(), item); err != nil {
+						return fmt.Errorf("field %q: %w", name, err)
+					}
+					continue
+				}
+				pv, err := scalarValue(fd.MapValue(), item)
+				if err != nil {
+					return fmt.Errorf("field %q: %w", name, err)
+				}
+				mp.Set(key, pv)
+			}
+		case fd.IsList():
+			items, ok := value.([]interface{})
+			if !ok {
+				return fmt.Errorf("field %q: expected array", name)
+			}
+			list := m.Mutable(fd).List()
+			for _, item := range items {
+				if fd.Message() != nil {
+					elem := list.NewElement()
+					if err := setValue(elem.Message(), item); err != nil {
+						return fmt.Errorf("field %q: %w", name, err)
+					}
+					list.Append(elem)
+					continue
+				}
+				pv, err := scalarValue(fd, item)
+				if err != nil {
+					return fmt.Errorf("field %q: %w", name, err)
+				}
+				list.Append(pv)
+			}
+		case fd.Message() != nil:
+			if err := setValue(m.Mutable(fd).Message(), value); err != nil {
+				return fmt.Errorf("field %q: %w", name, err)
+			}
+		default:
+			pv, err := scalarValue(fd, value)
+			if err != nil {
+				return fmt.Errorf("field %q: %w", name, err)
+			}
+			m.Set(fd, pv)
+		}
+	}
+	return nil
+}
+
+// setValue 写入消息类型的值，google.protobuf.Value 等自由结构按 structpb 转换
+func setValue(m protoreflect.Message, value interface{}) error {
+	var wkt proto.Message
+	var err error
+	switch m.Descriptor().FullName() {
+	case "google.protobuf.Value":
+		wkt, err = structpb.NewValue(plainNumbers(value))
+	case "google.protobuf.Struct":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return errors.New("expected object")
+		}
+		wkt, err = structpb.NewStruct(plainNumbers(obj).(map[string]interface{}))
+	case "google.protobuf.ListValue":
+		items, ok := value.([]interface{})
+		if !ok {
+			return errors.New("expected array")
+		}
+		wkt, err = structpb.NewList(plainNumbers(items).([]interface{}))
+	default:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return errors.New("expected object")
+		}
+		return setMessage(m, obj)
+	}
+	if err != nil {
+		return err
+	}
+	proto.Merge(m.Interface(), wkt)
+	return nil
+}
+
+// scalarValue 转换标量字段的值
+func scalarValue(fd protoreflect.FieldDescriptor, value interface{}) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if b, ok := value.(bool); ok {
+			return protoreflect.ValueOfBool(b), nil
+		}
+	case protoreflect.StringKind:
+		if s, ok := value.(string); ok {
+			return protoreflect.ValueOfString(s), nil
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if n, ok := value.(json.Number); ok {
+			i, err := strconv.ParseInt(string(n), 10, 32)
+			return protoreflect.ValueOfInt32(int32(i)), err
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if n, ok := value.(json.Number); ok {
+			i, err := strconv.ParseInt(string(n), 10, 64)
+			return protoreflect.ValueOfInt64(i), err
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if n, ok := value.(json.Number); ok {
+			i, err := strconv.ParseUint(string(n), 10, 32)
+			return protoreflect.ValueOfUint32(uint32(i)), err
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if n, ok := value.(json.Number); ok {
+			i, err := strconv.ParseUint(string(n), 10, 64)
+			return protoreflect.ValueOfUint64(i), err
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		if n, ok := value.(json.Number); ok {
+			f, err := n.Float64()
+			if fd.Kind() == protoreflect.FloatKind {
+				return protoreflect.ValueOfFloat32(float32(f)), err
+			}
+			return protoreflect.ValueOfFloat64(f), err
+		}
+	}
+	return protoreflect.Value{}, fmt.Errorf("cannot encode %T as %s", value, fd.Kind())
+}
+
+// plainNumbers 将 json.Number 转换为 float64，structpb 不接受 json.Number
+func plainNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = plainNumbers(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = plainNumbers(item)
+		}
+		return out
+	}
+	return value
+}
+
+// messageFields 将消息转换为 JSON 结构，只包含已设置的字段
+func messageFields(m protoreflect.Message) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		var value interface{}
+		switch {
+		case fd.IsMap():
+			obj := make(map[string]interface{}, v.Map().Len())
+			v.Map().Range(func(k protoreflect.MapKey, item protoreflect.Value) bool {
+				obj[k.String()], err = singularValue(fd.MapValue(), item)
+				return err == nil
+			})
+			value = obj
+		case fd.IsList():
+			items := make([]interface{}, v.List().Len())
+			for i := range items {
+				if items[i], err = singularValue(fd, v.List().Get(i)); err != nil {
+					break
+				}
+			}
+			value = items
+		default:
+			value, err = singularValue(fd, v)
+		}
+		fields[string(fd.Name())] = value
+		return err == nil
+	})
+	return fields, err
+}
+
+// singularValue 转换单个字段值，自由结构按 structpb 转换
+func singularValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) (interface{}, error) {
+	if fd.Message() == nil {
+		if fd.Kind() == protoreflect.EnumKind {
+			return int64(v.Enum()), nil
+		}
+		return v.Interface(), nil
+	}
+
+	switch m := v.Message().Interface().(type) {
+	case *structpb.Value:
+		return m.AsInterface(), nil
+	case *structpb.Struct:
+		return m.AsMap(), nil
+	case *structpb.ListValue:
+		return m.AsSlice(), nil
+	}
+	return messageFields(v.Message())
+}
@@ -0,0 +1,90 @@
+package protocol
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/HY-805/SubNodeSync/pkg/codec"
+)
+
+func TestProtobufDetect(t *testing.T) {
+	data, err := Encode(Protobuf, NewEnvelope(TypeLog, "node", map[string]interface{}{"level": "info", "message": "hi"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != 0x08 {
+		t.Fatalf("first byte = %#x, want 0x08", data[0])
+	}
+	if got := codec.Detect(data); got != Protobuf {
+		t.Fatalf("Detect = %s", got.ContentType())
+	}
+}
+
+func TestProtobufBodyFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		msgType MessageType
+		body    interface{}
+		wantErr bool
+	}{
+		{"typed body", TypeStatus, map[string]interface{}{"status": "running", "pid": 42, "details": map[string]interface{}{"k": "v"}}, false},
+		{"undefined field", TypeStatus, map[string]interface{}{"status": "running", "extra": 1}, true},
+		{"wrong scalar type", TypeStatus, map[string]interface{}{"pid": "42"}, true},
+		{"other type", MessageType("custom"), map[string]interface{}{"anything": []interface{}{1, "x"}}, false},
+		{"empty body", TypeStatus, nil, false},
+	}
+	for _, tt := range tests {
+		data, err := Encode(Protobuf, NewEnvelope(tt.msgType, "node", tt.body))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: encode succeeded", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: encode: %v", tt.name, err)
+		}
+		env, _, err := Decode(data)
+		if err != nil {
+			t.Fatalf("%s: decode: %v", tt.name, err)
+		}
+		if env.Type != tt.msgType {
+			t.Errorf("%s: type = %s", tt.name, env.Type)
+		}
+		if canonicalJSON(t, env.Body) != canonicalJSON(t, tt.body) {
+			t.Errorf("%s: body = %s, want %s", tt.name, canonicalJSON(t, env.Body), canonicalJSON(t, tt.body))
+		}
+	}
+}
+
+func TestProtobufValueLosesIntegerPrecision(t *testing.T) {
+	// google.protobuf.Value 的数值为 double，超过 2^53 的整数会丢失精度；JSON 保留原值
+	const big = "9007199254740993" // 2^53 + 1
+	body := map[string]interface{}{"command": "seek", "parameters": map[string]interface{}{"offset": json.Number(big)}}
+
+	offset := func(c codec.Codec) string {
+		t.Helper()
+		data, err := Encode(c, NewEnvelope(TypeCommand, "ops", body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		env, _, err := Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cmd struct {
+			Parameters map[string]json.RawMessage `json:"parameters"`
+		}
+		if err := env.DecodeBody(&cmd); err != nil {
+			t.Fatal(err)
+		}
+		return string(cmd.Parameters["offset"])
+	}
+
+	if got := offset(codec.JSON); got != big {
+		t.Errorf("json offset = %s, want %s", got, big)
+	}
+	if got := offset(Protobuf); got != "9007199254740992" {
+		t.Errorf("protobuf offset = %s, want 9007199254740992", got)
+	}
+}
@@ -0,0 +1,202 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/protocol/topics.go
+ * MQTT主题规划 - 统一生成所有消息的主题
+ *
+ * 主题树（默认前缀 v1/subapp，设置租户后插入 tenants/{tenant} 段）：
+ *
+ *	{prefix}[/tenants/{tenant}]/pcs/{node}/register
+ *	{prefix}[/tenants/{tenant}]/pcs/{node}/heartbeat
+ *	{prefix}[/tenants/{tenant}]/pcs/{node}/status
+ *	{prefix}[/tenants/{tenant}]/pcs/{node}/log
+ *	{prefix}[/tenants/{tenant}]/pcs/{node}/config
+ *	{prefix}[/tenants/{tenant}]/pcs/{node}/events
+ *	{prefix}[/tenants/{tenant}]/pcs/{node}/control
+ *	{prefix}[/tenants/{tenant}]/pcs/{node}/{instance}/control
+ *	{prefix}[/tenants/{tenant}]/pcs/{node}/{instance}/reply
+ *	{prefix}[/tenants/{tenant}]/pcs/{node}/{instance}/state
+ *	{prefix}[/tenants/{tenant}]/broadcast/control
+ *	{prefix}[/tenants/{tenant}]/group/control
+ *
+ * 租户段前固定插入 tenants，租户的主题树不会与无租户的主题树重叠（如租户名为 pcs），
+ * Broker ACL 可按 {prefix}/tenants/{tenant}/# 授权。Parse 将主题解析回节点、实例和末段，
+ * 供管理端按主题分发收到的消息。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultTopicPrefix 默认主题前缀
+const DefaultTopicPrefix = "v1/subapp"
+
+// tenantsSegment 租户段之前的固定主题段
+const tenantsSegment = "tenants"
+
+// TopicScheme 主题规划
+type TopicScheme struct {
+	// Prefix 主题前缀，为空时使用 DefaultTopicPrefix
+	Prefix string
+	// Tenant 租户段，为空时不插入，否则插入 tenants/{tenant}
+	Tenant string
+}
+
+// DefaultTopicScheme 返回默认主题规划
+func DefaultTopicScheme() *TopicScheme {
+	return &TopicScheme{Prefix: DefaultTopicPrefix}
+}
+
+// Root 返回主题树的根，即前缀加租户段
+func (s *TopicScheme) Root() string {
+	prefix := s.prefix()
+	if s.Tenant == "" {
+		return prefix
+	}
+	return prefix + "/" + tenantsSegment + "/" + s.Tenant
+}
+
+// prefix 返回去掉末尾 / 的前缀
+func (s *TopicScheme) prefix() string {
+	prefix := strings.TrimSuffix(s.Prefix, "/")
+	if prefix == "" {
+		prefix = DefaultTopicPrefix
+	}
+	return prefix
+}
+
+// Validate 检查前缀和租户段，前缀的每一段都要符合 ValidateSegment
+func (s *TopicScheme) Validate() error {
+	for _, level := range strings.Split(s.prefix(), "/") {
+		if err := ValidateSegment(level); err != nil {
+			return fmt.Errorf("invalid topic prefix %q: %w", s.Prefix, err)
+		}
+	}
+	if s.Tenant != "" {
+		if err := ValidateSegment(s.Tenant); err != nil {
+			return fmt.Errorf("invalid topic tenant: %w", err)
+		}
+	}
+	return nil
+}
+
+// ValidateSegment 检查字符串能否作为单个主题段（如租户、节点名）
+// 不能为空，不能包含 /、+、# 等 MQTT 保留字符
+func ValidateSegment(segment string) error {
+	if segment == "" {
+		return fmt.Errorf("topic segment is empty")
+	}
+	if strings.ContainsAny(segment, "/+#\x00") {
+		return fmt.Errorf("topic segment %q contains reserved characters", segment)
+	}
+	return nil
+}
+
+// node 返回节点主题
+func (s *TopicScheme) node(nodeName, leaf string) string {
+	return s.Root() + "/pcs/" + nodeName + "/" + leaf
+}
+
+// instance 返回实例主题
+func (s *TopicScheme) instance(nodeName, instanceID, leaf string) string {
+	return s.Root() + "/pcs/" + nodeName + "/" + instanceID + "/" + leaf
+}
+
+// Register 注册主题
+func (s *TopicScheme) Register(nodeName string) string { return s.node(nodeName, "register") }
+
+// Heartbeat 心跳主题
+func (s *TopicScheme) Heartbeat(nodeName string) string { return s.node(nodeName, "heartbeat") }
+
+// Status 状态主题
+func (s *TopicScheme) Status(nodeName string) string { return s.node(nodeName, "status") }
+
+// Log 日志主题
+func (s *TopicScheme) Log(nodeName string) string { return s.node(nodeName, "log") }
+
+// Config 配置主题
+func (s *TopicScheme) Config(nodeName string) string { return s.node(nodeName, "config") }
+
+// Events 事件主题
+func (s *TopicScheme) Events(nodeName string) string { return s.node(nodeName, "events") }
+
+// Control 节点控制主题，同名节点的所有实例订阅
+func (s *TopicScheme) Control(nodeName string) string { return s.node(nodeName, "control") }
+
+// InstanceControl 实例控制主题
+func (s *TopicScheme) InstanceControl(nodeName, instanceID string) string {
+	return s.instance(nodeName, instanceID, "control")
+}
+
+// Reply 命令应答主题
+func (s *TopicScheme) Reply(nodeName, instanceID string) string {
+	return s.instance(nodeName, instanceID, "reply")
+}
+
+// State 实例在线状态主题，保留消息，连接异常断开时由 broker 发布遗嘱
+func (s *TopicScheme) State(nodeName, instanceID string) string {
+	return s.instance(nodeName, instanceID, "state")
+}
+
+// BroadcastControl 全局广播控制主题
+func (s *TopicScheme) BroadcastControl() string { return s.Root() + "/broadcast/control" }
+
+// GroupControl 标签分组控制主题
+func (s *TopicScheme) GroupControl() string { return s.Root() + "/group/control" }
+
+// TopicKind 主题类别
+type TopicKind string
+
+const (
+	TopicKindNode      TopicKind = "node"      // 节点主题
+	TopicKindInstance  TopicKind = "instance"  // 实例主题
+	TopicKindBroadcast TopicKind = "broadcast" // 全局广播控制主题
+	TopicKindGroup     TopicKind = "group"     // 标签分组控制主题
+)
+
+// Topic 解析得到的主题信息
+type Topic struct {
+	Kind     TopicKind
+	Node     string // 节点名，广播和分组主题为空
+	Instance string // 实例ID，只有实例主题设置
+	Leaf     string // 末段，如 heartbeat、control
+}
+
+// 节点主题和实例主题的末段
+var (
+	nodeLeaves     = map[string]bool{"register": true, "heartbeat": true, "status": true, "log": true, "config": true, "events": true, "control": true}
+	instanceLeaves = map[string]bool{"control": true, "reply": true, "state": true}
+)
+
+// Parse 解析本规划生成的主题，不属于主题树的主题返回 false
+func (s *TopicScheme) Parse(topic string) (Topic, bool) {
+	rest := strings.TrimPrefix(topic, s.Root()+"/")
+	if rest == topic {
+		return Topic{}, false
+	}
+
+	levels := strings.Split(rest, "/")
+	for _, level := range levels {
+		if ValidateSegment(level) != nil {
+			return Topic{}, false
+		}
+	}
+	switch {
+	case rest == "broadcast/control":
+		return Topic{Kind: TopicKindBroadcast, Leaf: "control"}, true
+	case rest == "group/control":
+		return Topic{Kind: TopicKindGroup, Leaf: "control"}, true
+	case levels[0] != "pcs":
+		return Topic{}, false
+	case len(levels) == 3 && nodeLeaves[levels[2]]:
+		return Topic{Kind: TopicKindNode, Node: levels[1], Leaf: levels[2]}, true
+	case len(levels) == 4 && instanceLeaves[levels[3]]:
+		return Topic{Kind: TopicKindInstance, Node: levels[1], Instance: levels[2], Leaf: levels[3]}, true
+	}
+	return Topic{}, false
+}
@@ -0,0 +1,150 @@
+package protocol
+
+import "testing"
+
+func TestTopicSchemeBuild(t *testing.T) {
+	tests := []struct {
+		name   string
+		scheme *TopicScheme
+		root   string
+	}{
+		{"default", DefaultTopicScheme(), "v1/subapp"},
+		{"empty prefix", &TopicScheme{}, "v1/subapp"},
+		{"custom prefix", &TopicScheme{Prefix: "acme/"}, "acme"},
+		{"tenant", &TopicScheme{Prefix: "v1/subapp", Tenant: "factory-a"}, "v1/subapp/tenants/factory-a"},
+	}
+	for _, tt := range tests {
+		s := tt.scheme
+		if got := s.Root(); got != tt.root {
+			t.Errorf("%s: Root = %s, want %s", tt.name, got, tt.root)
+		}
+		topics := [][2]string{
+			{s.Register("n"), tt.root + "/pcs/n/register"},
+			{s.Heartbeat("n"), tt.root + "/pcs/n/heartbeat"},
+			{s.Status("n"), tt.root + "/pcs/n/status"},
+			{s.Log("n"), tt.root + "/pcs/n/log"},
+			{s.Config("n"), tt.root + "/pcs/n/config"},
+			{s.Events("n"), tt.root + "/pcs/n/events"},
+			{s.Control("n"), tt.root + "/pcs/n/control"},
+			{s.InstanceControl("n", "i"), tt.root + "/pcs/n/i/control"},
+			{s.Reply("n", "i"), tt.root + "/pcs/n/i/reply"},
+			{s.State("n", "i"), tt.root + "/pcs/n/i/state"},
+			{s.BroadcastControl(), tt.root + "/broadcast/control"},
+			{s.GroupControl(), tt.root + "/group/control"},
+		}
+		for _, topic := range topics {
+			if topic[0] != topic[1] {
+				t.Errorf("%s: topic = %s, want %s", tt.name, topic[0], topic[1])
+			}
+		}
+	}
+}
+
+func TestTopicSchemeParse(t *testing.T) {
+	s := &TopicScheme{Tenant: "factory-a"}
+	tests := []struct {
+		topic string
+		want  Topic
+	}{
+		{s.Heartbeat("collector"), Topic{Kind: TopicKindNode, Node: "collector", Leaf: "heartbeat"}},
+		{s.Control("collector"), Topic{Kind: TopicKindNode, Node: "collector", Leaf: "control"}},
+		{s.InstanceControl("collector", "c-1"), Topic{Kind: TopicKindInstance, Node: "collector", Instance: "c-1", Leaf: "control"}},
+		{s.Reply("collector", "c-1"), Topic{Kind: TopicKindInstance, Node: "collector", Instance: "c-1", Leaf: "reply"}},
+		{s.State("collector", "c-1"), Topic{Kind: TopicKindInstance, Node: "collector", Instance: "c-1", Leaf: "state"}},
+		{s.BroadcastControl(), Topic{Kind: TopicKindBroadcast, Leaf: "control"}},
+		{s.GroupControl(), Topic{Kind: TopicKindGroup, Leaf: "control"}},
+	}
+	for _, tt := range tests {
+		got, ok := s.Parse(tt.topic)
+		if !ok || got != tt.want {
+			t.Errorf("Parse(%s) = %+v, %v; want %+v", tt.topic, got, ok, tt.want)
+		}
+	}
+
+	for _, topic := range []string{
+		"v1/subapp/pcs/collector/heartbeat",                   // 其他租户（无租户段）
+		"v1/subapp/tenants/factory-b/pcs/collector/heartbeat", // 其他租户
+		"v1/subapp/tenants/factory-a/pcs/collector/unknown",
+		"v1/subapp/tenants/factory-a/pcs/collector/c-1/register",
+		"v1/subapp/tenants/factory-a/pcs/collector/c-1/x/reply",
+		"v1/subapp/tenants/factory-a/pcs/+/heartbeat",
+		"v1/subapp/tenants/factory-a/pcs//heartbeat",
+		"v1/subapp/tenants/factory-a/other/collector/heartbeat",
+		"v1/subapp/tenants/factory-a",
+	} {
+		if got, ok := s.Parse(topic); ok {
+			t.Errorf("Parse(%s) = %+v, want no match", topic, got)
+		}
+	}
+}
+
+func TestTenantTreeDoesNotOverlapDefaultTree(t *testing.T) {
+	// 租户名与主题树中的固定段相同时也不会与无租户的主题重叠
+	untenanted := DefaultTopicScheme()
+	for _, tenant := range []string{"pcs", "broadcast", "group", "tenants"} {
+		tenanted := &TopicScheme{Tenant: tenant}
+		topics := []string{
+			tenanted.Register("collector"),
+			tenanted.Control("collector"),
+			tenanted.InstanceControl("collector", "c-1"),
+			tenanted.Reply("collector", "c-1"),
+			tenanted.State("collector", "c-1"),
+			tenanted.BroadcastControl(),
+			tenanted.GroupControl(),
+		}
+		for _, topic := range topics {
+			if got, ok := untenanted.Parse(topic); ok {
+				t.Errorf("tenant %s topic %s parsed by default tree as %+v", tenant, topic, got)
+			}
+		}
+		// 无租户的节点名和实例ID取租户名时也不会落入租户主题树
+		for _, topic := range []string{
+			untenanted.InstanceControl(tenant, "collector"),
+			untenanted.Control(tenant),
+			untenanted.Reply(tenant, "collector"),
+		} {
+			if got, ok := tenanted.Parse(topic); ok {
+				t.Errorf("default topic %s parsed by tenant %s as %+v", topic, tenant, got)
+			}
+		}
+	}
+}
+
+func TestTopicSchemeValidate(t *testing.T) {
+	valid := []*TopicScheme{
+		DefaultTopicScheme(),
+		{},
+		{Prefix: "acme/", Tenant: "factory-a"},
+	}
+	for _, s := range valid {
+		if err := s.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error %v", s, err)
+		}
+	}
+	invalid := []*TopicScheme{
+		{Prefix: "v1/+/subapp"},
+		{Prefix: "v1//subapp"},
+		{Prefix: "#"},
+		{Tenant: "a/b"},
+		{Tenant: "+"},
+		{Tenant: "#"},
+	}
+	for _, s := range invalid {
+		if err := s.Validate(); err == nil {
+			t.Errorf("%+v: accepted invalid scheme", s)
+		}
+	}
+}
+
+func TestValidateSegment(t *testing.T) {
+	for _, segment := range []string{"collector", "factory-a", "node_1.2"} {
+		if err := ValidateSegment(segment); err != nil {
+			t.Errorf("%q: unexpected error %v", segment, err)
+		}
+	}
+	for _, segment := range []string{"", "a/b", "+", "node#1", "a+b", "a\x00"} {
+		if err := ValidateSegment(segment); err == nil {
+			t.Errorf("%q: accepted invalid segment", segment)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/sparkplug/edge.go
+ * Sparkplug B 边缘节点 - 管理 NBIRTH/NDEATH/NDATA 的发布、序号和 NCMD 的接收
+ *
+ * 会话规则：
+ *   - 每次 Start 注册 NDEATH 遗嘱时使用新的 bdSeq（首次为 0，之后递增，255 后回到 0），
+ *     遗嘱负载中的 bdSeq 与随后 NBIRTH 中的相同
+ *   - 每次连接（含重连）成功后发布 NBIRTH，seq 从 0 开始，之后每条 NDATA 递增，255 后回到 0
+ *   - 收到 Node Control/Rebirth 命令时重新发布 NBIRTH
+ *   - Stop 时主动发布 NDEATH 后断开
+ *
+ * bdSeq 在传输层自动重连期间保持不变，保证 broker 发布的遗嘱与最近的 NBIRTH 一致。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package sparkplug
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/HY-805/SubNodeSync/pkg/transport"
+)
+
+// ErrWillUnsupported 传输层不支持遗嘱消息时 Start 返回的错误
+var ErrWillUnsupported = errors.New("sparkplug: transport does not support last will")
+
+// EdgeNode Sparkplug B 边缘节点
+type EdgeNode struct {
+	groupID    string
+	edgeNodeID string
+	transport  transport.Transport
+
+	mu        sync.Mutex
+	bdSeq     uint64
+	willSet   bool // 已注册过遗嘱，之后每次注册使用新的 bdSeq
+	seq       uint64
+	birth     func() []Metric
+	onCommand func(metrics []Metric)
+}
+
+// NewEdgeNode 创建使用指定传输层的边缘节点
+// 传输层应专用于该边缘节点，遗嘱为 NDEATH
+func NewEdgeNode(groupID, edgeNodeID string, t transport.Transport) (*EdgeNode, error) {
+	if err := ValidateID(groupID); err != nil {
+		return nil, err
+	}
+	if err := ValidateID(edgeNodeID); err != nil {
+		return nil, err
+	}
+	return &EdgeNode{
+		groupID:    groupID,
+		edgeNodeID: edgeNodeID,
+		transport:  t,
+	}, nil
+}
+
+// GroupID 返回组ID
+func (n *EdgeNode) GroupID() string {
+	return n.groupID
+}
+
+// EdgeNodeID 返回边缘节点ID
+func (n *EdgeNode) EdgeNodeID() string {
+	return n.edgeNodeID
+}
+
+// SetBirthMetrics 设置 NBIRTH 指标的生成函数，每次发布 NBIRTH 时调用
+// bdSeq 和 Node Control/Rebirth 由边缘节点自动添加；NDATA 只应发布 NBIRTH 中出现过的指标
+func (n *EdgeNode) SetBirthMetrics(fn func() []Metric) {
+	n.mu.Lock()
+	n.birth = fn
+	n.mu.Unlock()
+}
+
+// OnCommand 设置 NCMD 回调，Rebirth 请求由边缘节点处理，不传给回调
+func (n *EdgeNode) OnCommand(fn func(metrics []Metric)) {
+	n.mu.Lock()
+	n.onCommand = fn
+	n.mu.Unlock()
+}
+
+// Start 使用新的 bdSeq 注册 NDEATH 遗嘱、订阅 NCMD 后连接 broker
+func (n *EdgeNode) Start() error {
+	ws, ok := n.transport.(transport.WillSetter)
+	if !ok {
+		return ErrWillUnsupported
+	}
+
+	n.mu.Lock()
+	if n.willSet {
+		n.bdSeq = (n.bdSeq + 1) % 256
+	}
+	n.willSet = true
+	death, err := n.deathPayload()
+	n.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	ws.SetWill(&transport.Message{
+		Topic:   Topic(n.groupID, NDEATH, n.edgeNodeID),
+		Payload: death,
+		QoS:     1,
+	})
+
+	n.transport.OnConnect(func() {
+		if err := n.Rebirth(); err != nil {
+			log.Printf("[SubNodeSync] 发布 Sparkplug NBIRTH 失败: %v", err)
+		}
+	})
+	n.transport.OnConnectionLost(func(err error) {
+		log.Printf("[SubNodeSync] Sparkplug 边缘节点 %s/%s 连接丢失: %v", n.groupID, n.edgeNodeID, err)
+	})
+	if err := n.transport.Subscribe(Topic(n.groupID, NCMD, n.edgeNodeID), 0, n.handleCommand); err != nil {
+		return err
+	}
+	return n.transport.Connect()
+}
+
+// Stop 发布 NDEATH 后断开连接
+func (n *EdgeNode) Stop() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.transport.IsConnected() {
+		death, err := n.deathPayload()
+		if err == nil {
+			err = n.transport.Publish(Topic(n.groupID, NDEATH, n.edgeNodeID), 1, false, death)
+		}
+		if err != nil {
+			log.Printf("[SubNodeSync] 发布 Sparkplug NDEATH 失败: %v", err)
+		}
+		n.transport.Unsubscribe(Topic(n.groupID, NCMD, n.edgeNodeID))
+		n.transport.Disconnect()
+	}
+}
+
+// Rebirth 发布 NBIRTH，序号重新从 0 开始
+func (n *EdgeNode) Rebirth() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.seq = 0
+	metrics := []Metric{
+		{Name: MetricBdSeq, DataType: TypeInt64, Value: int64(n.bdSeq)},
+		NewMetric(MetricRebirth, false),
+	}
+	if n.birth != nil {
+		metrics = append(metrics, n.birth()...)
+	}
+	return n.publish(NBIRTH, metrics)
+}
+
+// PublishData 发布 NDATA
+func (n *EdgeNode) PublishData(metrics ...Metric) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.publish(NDATA, metrics)
+}
+
+// publish 编码并发布带序号的消息，调用方需持有 n.mu
+func (n *EdgeNode) publish(msgType MessageType, metrics []Metric) error {
+	if !n.transport.IsConnected() {
+		return transport.ErrNotConnected
+	}
+
+	seq := n.seq
+	data, err := (&Payload{
+		Timestamp: timestamp(time.Now()),
+		Metrics:   metrics,
+		Seq:       &seq,
+	}).Marshal()
+	if err != nil {
+		return err
+	}
+	if err := n.transport.Publish(Topic(n.groupID, msgType, n.edgeNodeID), 0, false, data); err != nil {
+		return err
+	}
+	n.seq = (n.seq + 1) % 256
+	return nil
+}
+
+// deathPayload 生成 NDEATH 负载，只包含 bdSeq，调用方需持有 n.mu
+func (n *EdgeNode) deathPayload() ([]byte, error) {
+	return (&Payload{
+		Timestamp: timestamp(time.Now()),
+		Metrics:   []Metric{{Name: MetricBdSeq, DataType: TypeInt64, Value: int64(n.bdSeq)}},
+	}).Marshal()
+}
+
+// handleCommand 处理 NCMD，Rebirth 请求重新发布 NBIRTH，其余指标交给回调
+func (n *EdgeNode) handleCommand(msg *transport.Message) {
+	payload, err := Unmarshal(msg.Payload)
+	if err != nil {
+		log.Printf("[SubNodeSync] 解析 Sparkplug NCMD 失败: %v", err)
+		return
+	}
+
+	var rebirth bool
+	metrics := make([]Metric, 0, len(payload.Metrics))
+	for _, m := range payload.Metrics {
+		if m.Name == MetricRebirth {
+			rebirth = rebirth || m.Value == true
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+
+	if rebirth {
+		if err := n.Rebirth(); err != nil {
+			log.Printf("[SubNodeSync] 发布 Sparkplug NBIRTH 失败: %v", err)
+		}
+	}
+
+	n.mu.Lock()
+	onCommand := n.onCommand
+	n.mu.Unlock()
+	if onCommand != nil && len(metrics) > 0 {
+		onCommand(metrics)
+	}
+}
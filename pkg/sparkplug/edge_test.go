@@ -0,0 +1,104 @@
+package sparkplug
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/HY-805/SubNodeSync/pkg/transport"
+)
+
+// sessionMessage 边缘节点发布的 NBIRTH 或 NDEATH
+type sessionMessage struct {
+	msgType MessageType
+	bdSeq   int64
+}
+
+// watchSession 订阅边缘节点的 NBIRTH 和 NDEATH
+func watchSession(t *testing.T, broker *transport.MemoryBroker) <-chan sessionMessage {
+	t.Helper()
+	watcher := transport.NewMemoryTransport(broker)
+	if err := watcher.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(watcher.Disconnect)
+
+	msgs := make(chan sessionMessage, 8)
+	for _, msgType := range []MessageType{NBIRTH, NDEATH} {
+		msgType := msgType
+		watcher.Subscribe(Topic("g", msgType, "e"), 1, func(msg *transport.Message) {
+			payload, err := Unmarshal(msg.Payload)
+			if err != nil {
+				t.Errorf("unmarshal %s: %v", msgType, err)
+				return
+			}
+			for _, m := range payload.Metrics {
+				if m.Name == MetricBdSeq {
+					msgs <- sessionMessage{msgType, m.Value.(int64)}
+					return
+				}
+			}
+			t.Errorf("%s without bdSeq", msgType)
+		})
+	}
+	return msgs
+}
+
+func expectSession(t *testing.T, msgs <-chan sessionMessage, msgType MessageType, bdSeq int64) {
+	t.Helper()
+	select {
+	case got := <-msgs:
+		if got.msgType != msgType || got.bdSeq != bdSeq {
+			t.Fatalf("got %s bdSeq %d, want %s bdSeq %d", got.msgType, got.bdSeq, msgType, bdSeq)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s published", msgType)
+	}
+}
+
+func TestEdgeNodeBdSeqAdvancesOnEachWillRegistration(t *testing.T) {
+	broker := transport.NewMemoryBroker()
+	msgs := watchSession(t, broker)
+	mt := transport.NewMemoryTransport(broker)
+	edge, err := NewEdgeNode("g", "e", mt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := edge.Start(); err != nil {
+		t.Fatal(err)
+	}
+	expectSession(t, msgs, NBIRTH, 0)
+
+	// 遗嘱与最近的 NBIRTH 使用相同的 bdSeq
+	mt.SimulateConnectionLost(errors.New("network down"))
+	expectSession(t, msgs, NDEATH, 0)
+
+	// 重新注册遗嘱后连接使用新的 bdSeq
+	if err := edge.Start(); err != nil {
+		t.Fatal(err)
+	}
+	expectSession(t, msgs, NBIRTH, 1)
+	edge.Stop()
+	expectSession(t, msgs, NDEATH, 1)
+
+	if err := edge.Start(); err != nil {
+		t.Fatal(err)
+	}
+	expectSession(t, msgs, NBIRTH, 2)
+	mt.SimulateConnectionLost(errors.New("network down"))
+	expectSession(t, msgs, NDEATH, 2)
+}
+
+func TestEdgeNodeRequiresWillSupport(t *testing.T) {
+	edge, err := NewEdgeNode("g", "e", noWillTransport{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := edge.Start(); !errors.Is(err, ErrWillUnsupported) {
+		t.Fatalf("Start = %v, want ErrWillUnsupported", err)
+	}
+}
+
+// noWillTransport 不支持遗嘱的传输层
+type noWillTransport struct{ transport.Transport }
@@ -0,0 +1,438 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/sparkplug/payload.go
+ * Sparkplug B 负载 - org.eclipse.tahu.protobuf.Payload 的编解码
+ *
+ * 只实现节点使用到的字段：时间戳、序号和标量指标。
+ * 解码时忽略指标的元数据、属性集、数据集和模板等字段。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package sparkplug
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// DataType 指标数据类型
+type DataType uint32
+
+const (
+	TypeUnknown  DataType = 0
+	TypeInt8     DataType = 1
+	TypeInt16    DataType = 2
+	TypeInt32    DataType = 3
+	TypeInt64    DataType = 4
+	TypeUInt8    DataType = 5
+	TypeUInt16   DataType = 6
+	TypeUInt32   DataType = 7
+	TypeUInt64   DataType = 8
+	TypeFloat    DataType = 9
+	TypeDouble   DataType = 10
+	TypeBoolean  DataType = 11
+	TypeString   DataType = 12
+	TypeDateTime DataType = 13
+	TypeText     DataType = 14
+	TypeUUID     DataType = 15
+	TypeBytes    DataType = 17
+	TypeFile     DataType = 18
+)
+
+// Payload 字段编号
+const (
+	fieldPayloadTimestamp protowire.Number = 1
+	fieldPayloadMetrics   protowire.Number = 2
+	fieldPayloadSeq       protowire.Number = 3
+	fieldPayloadUUID      protowire.Number = 4
+	fieldPayloadBody      protowire.Number = 5
+)
+
+// Metric 字段编号
+const (
+	fieldMetricName      protowire.Number = 1
+	fieldMetricAlias     protowire.Number = 2
+	fieldMetricTimestamp protowire.Number = 3
+	fieldMetricDataType  protowire.Number = 4
+	fieldMetricIsNull    protowire.Number = 7
+	fieldMetricInt       protowire.Number = 10
+	fieldMetricLong      protowire.Number = 11
+	fieldMetricFloat     protowire.Number = 12
+	fieldMetricDouble    protowire.Number = 13
+	fieldMetricBoolean   protowire.Number = 14
+	fieldMetricString    protowire.Number = 15
+	fieldMetricBytes     protowire.Number = 16
+)
+
+var errMalformedPayload = errors.New("sparkplug: malformed payload")
+
+// Payload Sparkplug B 消息负载
+type Payload struct {
+	// Timestamp 负载生成时间，UTC毫秒
+	Timestamp uint64
+	// Metrics 指标列表
+	Metrics []Metric
+	// Seq 消息序号，NDEATH 不携带序号时为nil
+	Seq  *uint64
+	UUID string
+	Body []byte
+}
+
+// Metric 指标
+type Metric struct {
+	Name      string
+	Alias     uint64
+	Timestamp uint64 // UTC毫秒，为0时不编码
+	DataType  DataType
+	IsNull    bool
+	// Value 指标值，Go 类型由 DataType 决定，如 TypeInt64 为 int64、TypeString 为 string
+	Value interface{}
+}
+
+// NewMetric 按值的 Go 类型推断数据类型创建指标
+// 支持整数、浮点数、bool、string、[]byte 和 time.Time，其他类型编码时返回错误
+func NewMetric(name string, value interface{}) Metric {
+	m := Metric{Name: name, Value: value}
+	switch value.(type) {
+	case int8:
+		m.DataType = TypeInt8
+	case int16:
+		m.DataType = TypeInt16
+	case int32:
+		m.DataType = TypeInt32
+	case int, int64:
+		m.DataType = TypeInt64
+	case uint8:
+		m.DataType = TypeUInt8
+	case uint16:
+		m.DataType = TypeUInt16
+	case uint32:
+		m.DataType = TypeUInt32
+	case uint, uint64:
+		m.DataType = TypeUInt64
+	case float32:
+		m.DataType = TypeFloat
+	case float64:
+		m.DataType = TypeDouble
+	case bool:
+		m.DataType = TypeBoolean
+	case string:
+		m.DataType = TypeString
+	case []byte:
+		m.DataType = TypeBytes
+	case time.Time:
+		m.DataType = TypeDateTime
+	}
+	return m
+}
+
+// Marshal 编码负载
+func (p *Payload) Marshal() ([]byte, error) {
+	var buf []byte
+	if p.Timestamp != 0 {
+		buf = protowire.AppendTag(buf, fieldPayloadTimestamp, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, p.Timestamp)
+	}
+	for i := range p.Metrics {
+		metric, err := p.Metrics[i].marshal()
+		if err != nil {
+			return nil, err
+		}
+		buf = protowire.AppendTag(buf, fieldPayloadMetrics, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, metric)
+	}
+	if p.Seq != nil {
+		buf = protowire.AppendTag(buf, fieldPayloadSeq, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, *p.Seq)
+	}
+	if p.UUID != "" {
+		buf = protowire.AppendTag(buf, fieldPayloadUUID, protowire.BytesType)
+		buf = protowire.AppendString(buf, p.UUID)
+	}
+	if p.Body != nil {
+		buf = protowire.AppendTag(buf, fieldPayloadBody, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, p.Body)
+	}
+	return buf, nil
+}
+
+// Unmarshal 解码负载
+func Unmarshal(data []byte) (*Payload, error) {
+	p := &Payload{}
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, errMalformedPayload
+		}
+		data = data[n:]
+
+		switch {
+		case num == fieldPayloadTimestamp && typ == protowire.VarintType:
+			p.Timestamp, n = protowire.ConsumeVarint(data)
+		case num == fieldPayloadMetrics && typ == protowire.BytesType:
+			var b []byte
+			b, n = protowire.ConsumeBytes(data)
+			if n >= 0 {
+				metric, err := unmarshalMetric(b)
+				if err != nil {
+					return nil, err
+				}
+				p.Metrics = append(p.Metrics, metric)
+			}
+		case num == fieldPayloadSeq && typ == protowire.VarintType:
+			var seq uint64
+			seq, n = protowire.ConsumeVarint(data)
+			p.Seq = &seq
+		case num == fieldPayloadUUID && typ == protowire.BytesType:
+			p.UUID, n = protowire.ConsumeString(data)
+		case num == fieldPayloadBody && typ == protowire.BytesType:
+			var b []byte
+			b, n = protowire.ConsumeBytes(data)
+			p.Body = append([]byte(nil), b...)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return nil, errMalformedPayload
+		}
+		data = data[n:]
+	}
+	return p, nil
+}
+
+// marshal 编码单个指标
+func (m *Metric) marshal() ([]byte, error) {
+	var buf []byte
+	if m.Name != "" {
+		buf = protowire.AppendTag(buf, fieldMetricName, protowire.BytesType)
+		buf = protowire.AppendString(buf, m.Name)
+	}
+	if m.Alias != 0 {
+		buf = protowire.AppendTag(buf, fieldMetricAlias, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, m.Alias)
+	}
+	if m.Timestamp != 0 {
+		buf = protowire.AppendTag(buf, fieldMetricTimestamp, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, m.Timestamp)
+	}
+	buf = protowire.AppendTag(buf, fieldMetricDataType, protowire.VarintType)
+	buf = protowire.AppendVarint(buf, uint64(m.DataType))
+
+	if m.IsNull || m.Value == nil {
+		buf = protowire.AppendTag(buf, fieldMetricIsNull, protowire.VarintType)
+		return protowire.AppendVarint(buf, 1), nil
+	}
+
+	invalid := fmt.Errorf("sparkplug: metric %q: value of type %T does not match datatype %d", m.Name, m.Value, m.DataType)
+	switch m.DataType {
+	case TypeInt8, TypeInt16, TypeInt32:
+		v, ok := toInt64(m.Value)
+		if !ok {
+			return nil, invalid
+		}
+		buf = protowire.AppendTag(buf, fieldMetricInt, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, uint64(uint32(int32(v))))
+	case TypeUInt8, TypeUInt16, TypeUInt32:
+		v, ok := toInt64(m.Value)
+		if !ok {
+			return nil, invalid
+		}
+		buf = protowire.AppendTag(buf, fieldMetricInt, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, uint64(uint32(v)))
+	case TypeInt64, TypeUInt64, TypeDateTime:
+		var v uint64
+		switch x := m.Value.(type) {
+		case time.Time:
+			v = uint64(x.UnixMilli())
+		case uint64:
+			v = x
+		case uint:
+			v = uint64(x)
+		default:
+			i, ok := toInt64(m.Value)
+			if !ok {
+				return nil, invalid
+			}
+			v = uint64(i)
+		}
+		buf = protowire.AppendTag(buf, fieldMetricLong, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, v)
+	case TypeFloat:
+		v, ok := toFloat64(m.Value)
+		if !ok {
+			return nil, invalid
+		}
+		buf = protowire.AppendTag(buf, fieldMetricFloat, protowire.Fixed32Type)
+		buf = protowire.AppendFixed32(buf, math.Float32bits(float32(v)))
+	case TypeDouble:
+		v, ok := toFloat64(m.Value)
+		if !ok {
+			return nil, invalid
+		}
+		buf = protowire.AppendTag(buf, fieldMetricDouble, protowire.Fixed64Type)
+		buf = protowire.AppendFixed64(buf, math.Float64bits(v))
+	case TypeBoolean:
+		v, ok := m.Value.(bool)
+		if !ok {
+			return nil, invalid
+		}
+		buf = protowire.AppendTag(buf, fieldMetricBoolean, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, protowire.EncodeBool(v))
+	case TypeString, TypeText, TypeUUID:
+		v, ok := m.Value.(string)
+		if !ok {
+			return nil, invalid
+		}
+		buf = protowire.AppendTag(buf, fieldMetricString, protowire.BytesType)
+		buf = protowire.AppendString(buf, v)
+	case TypeBytes, TypeFile:
+		v, ok := m.Value.([]byte)
+		if !ok {
+			return nil, invalid
+		}
+		buf = protowire.AppendTag(buf, fieldMetricBytes, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, v)
+	default:
+		return nil, fmt.Errorf("sparkplug: metric %q: unsupported datatype %d", m.Name, m.DataType)
+	}
+	return buf, nil
+}
+
+// unmarshalMetric 解码单个指标，按 DataType 转换值的 Go 类型
+func unmarshalMetric(data []byte) (Metric, error) {
+	var m Metric
+	var raw interface{}
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return m, errMalformedPayload
+		}
+		data = data[n:]
+
+		var v uint64
+		switch {
+		case num == fieldMetricName && typ == protowire.BytesType:
+			m.Name, n = protowire.ConsumeString(data)
+		case num == fieldMetricAlias && typ == protowire.VarintType:
+			m.Alias, n = protowire.ConsumeVarint(data)
+		case num == fieldMetricTimestamp && typ == protowire.VarintType:
+			m.Timestamp, n = protowire.ConsumeVarint(data)
+		case num == fieldMetricDataType && typ == protowire.VarintType:
+			v, n = protowire.ConsumeVarint(data)
+			m.DataType = DataType(v)
+		case num == fieldMetricIsNull && typ == protowire.VarintType:
+			v, n = protowire.ConsumeVarint(data)
+			m.IsNull = protowire.DecodeBool(v)
+		case num == fieldMetricInt && typ == protowire.VarintType:
+			v, n = protowire.ConsumeVarint(data)
+			raw = uint32(v)
+		case num == fieldMetricLong && typ == protowire.VarintType:
+			v, n = protowire.ConsumeVarint(data)
+			raw = v
+		case num == fieldMetricFloat && typ == protowire.Fixed32Type:
+			var f uint32
+			f, n = protowire.ConsumeFixed32(data)
+			raw = math.Float32frombits(f)
+		case num == fieldMetricDouble && typ == protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(data)
+			raw = math.Float64frombits(v)
+		case num == fieldMetricBoolean && typ == protowire.VarintType:
+			v, n = protowire.ConsumeVarint(data)
+			raw = protowire.DecodeBool(v)
+		case num == fieldMetricString && typ == protowire.BytesType:
+			raw, n = protowire.ConsumeString(data)
+		case num == fieldMetricBytes && typ == protowire.BytesType:
+			var b []byte
+			b, n = protowire.ConsumeBytes(data)
+			raw = append([]byte(nil), b...)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return m, errMalformedPayload
+		}
+		data = data[n:]
+	}
+
+	if !m.IsNull {
+		m.Value = convertValue(m.DataType, raw)
+	}
+	return m, nil
+}
+
+// convertValue 将线上的值转换为数据类型对应的 Go 类型，数据类型未知时保持原样
+func convertValue(dt DataType, raw interface{}) interface{} {
+	switch v := raw.(type) {
+	case uint32:
+		switch dt {
+		case TypeInt8:
+			return int8(int32(v))
+		case TypeInt16:
+			return int16(int32(v))
+		case TypeInt32:
+			return int32(v)
+		case TypeUInt8:
+			return uint8(v)
+		case TypeUInt16:
+			return uint16(v)
+		}
+	case uint64:
+		switch dt {
+		case TypeInt64:
+			return int64(v)
+		case TypeDateTime:
+			return time.UnixMilli(int64(v)).UTC()
+		}
+	}
+	return raw
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	if i, ok := toInt64(v); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+// timestamp 返回 Sparkplug 使用的UTC毫秒时间戳
+func timestamp(t time.Time) uint64 {
+	return uint64(t.UnixMilli())
+}
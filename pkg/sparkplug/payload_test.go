@@ -0,0 +1,140 @@
+package sparkplug
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestPayloadRoundTrip(t *testing.T) {
+	seq := uint64(0)
+	now := time.UnixMilli(time.Now().UnixMilli()).UTC()
+	p := &Payload{
+		Timestamp: timestamp(now),
+		Seq:       &seq,
+		UUID:      "uuid-1",
+		Body:      []byte{0x01, 0x02},
+		Metrics: []Metric{
+			NewMetric("int8", int8(-5)),
+			NewMetric("int16", int16(-300)),
+			NewMetric("int32", int32(-70000)),
+			NewMetric("int", 42),
+			NewMetric("int64", int64(math.MinInt64)),
+			NewMetric("uint8", uint8(200)),
+			NewMetric("uint16", uint16(60000)),
+			NewMetric("uint32", uint32(4000000000)),
+			NewMetric("uint64", uint64(math.MaxUint64)),
+			NewMetric("float", float32(1.5)),
+			NewMetric("double", -2.25),
+			NewMetric("bool", true),
+			NewMetric("string", "running"),
+			NewMetric("bytes", []byte("raw")),
+			NewMetric("datetime", now),
+			{Name: "null", DataType: TypeString, IsNull: true},
+			{Name: "aliased", Alias: 7, Timestamp: 123, DataType: TypeInt64, Value: int64(1)},
+		},
+	}
+
+	data, err := p.Marshal()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	want := *p
+	want.Metrics = append([]Metric(nil), p.Metrics...)
+	want.Metrics[3].Value = int64(42) // int 按 Int64 编码
+	if !reflect.DeepEqual(got, &want) {
+		t.Fatalf("round trip mismatch\n got: %+v\nwant: %+v", got, &want)
+	}
+}
+
+func TestPayloadWithoutSeq(t *testing.T) {
+	data, err := (&Payload{Metrics: []Metric{NewMetric("bdSeq", uint64(3))}}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Seq != nil {
+		t.Fatalf("seq = %d, want absent", *got.Seq)
+	}
+}
+
+func TestPayloadWireFormat(t *testing.T) {
+	// 与 Tahu Payload 定义的字段编号一致：metrics=2、seq=3，Metric 的 name=1、datatype=4、int_value=10
+	var metric []byte
+	metric = protowire.AppendTag(metric, 1, protowire.BytesType)
+	metric = protowire.AppendString(metric, "t")
+	metric = protowire.AppendTag(metric, 4, protowire.VarintType)
+	metric = protowire.AppendVarint(metric, uint64(TypeInt32))
+	metric = protowire.AppendTag(metric, 10, protowire.VarintType)
+	metric = protowire.AppendVarint(metric, 0xFFFFFFFF)
+	var want []byte
+	want = protowire.AppendTag(want, 2, protowire.BytesType)
+	want = protowire.AppendBytes(want, metric)
+	want = protowire.AppendTag(want, 3, protowire.VarintType)
+	want = protowire.AppendVarint(want, 9)
+
+	seq := uint64(9)
+	data, err := (&Payload{Seq: &seq, Metrics: []Metric{NewMetric("t", int32(-1))}}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Fatalf("wire format\n got: %x\nwant: %x", data, want)
+	}
+}
+
+func TestUnmarshalSkipsUnknownFields(t *testing.T) {
+	data, err := (&Payload{Metrics: []Metric{NewMetric("a", "b")}}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 未实现的字段（如 Metric 的 metadata、Payload 的扩展字段）被忽略
+	data = protowire.AppendTag(data, 99, protowire.BytesType)
+	data = protowire.AppendString(data, "extension")
+
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(got.Metrics) != 1 || got.Metrics[0].Value != "b" {
+		t.Fatalf("metrics = %+v", got.Metrics)
+	}
+}
+
+func TestUnmarshalMalformed(t *testing.T) {
+	data, err := (&Payload{Metrics: []Metric{NewMetric("name", "value")}}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range [][]byte{data[:len(data)-1], {0xFF}} {
+		if _, err := Unmarshal(bad); err == nil {
+			t.Errorf("Unmarshal(%x) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestMarshalRejectsMismatchedValue(t *testing.T) {
+	for _, m := range []Metric{
+		{Name: "a", DataType: TypeBoolean, Value: "true"},
+		{Name: "b", DataType: TypeInt32, Value: 1.5},
+		{Name: "c", DataType: TypeString, Value: 1},
+		{Name: "d", DataType: DataType(99), Value: 1},
+		NewMetric("e", struct{}{}),
+	} {
+		if _, err := (&Payload{Metrics: []Metric{m}}).Marshal(); err == nil {
+			t.Errorf("metric %s with %T marshaled, want error", m.Name, m.Value)
+		}
+	}
+}
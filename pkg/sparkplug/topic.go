@@ -0,0 +1,48 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/sparkplug/topic.go
+ * Sparkplug B 主题 - spBv1.0/{group_id}/{message_type}/{edge_node_id}
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package sparkplug
+
+import (
+	"fmt"
+
+	"github.com/HY-805/SubNodeSync/pkg/protocol"
+)
+
+// Namespace Sparkplug B 主题命名空间
+const Namespace = "spBv1.0"
+
+// MessageType Sparkplug 消息类型
+type MessageType string
+
+const (
+	NBIRTH MessageType = "NBIRTH" // 边缘节点上线
+	NDEATH MessageType = "NDEATH" // 边缘节点离线，作为遗嘱注册
+	NDATA  MessageType = "NDATA"  // 边缘节点数据
+	NCMD   MessageType = "NCMD"   // 发给边缘节点的命令
+)
+
+// 节点控制指标
+const (
+	MetricBdSeq   = "bdSeq"
+	MetricRebirth = "Node Control/Rebirth"
+)
+
+// Topic 返回边缘节点的消息主题
+func Topic(groupID string, msgType MessageType, edgeNodeID string) string {
+	return Namespace + "/" + groupID + "/" + string(msgType) + "/" + edgeNodeID
+}
+
+// ValidateID 检查组ID或边缘节点ID能否用作主题段
+func ValidateID(id string) error {
+	if err := protocol.ValidateSegment(id); err != nil {
+		return fmt.Errorf("invalid sparkplug id: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/sync/audit.go
+ * 安全审计 - 记录被拒绝的命令
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package sync
+
+import (
+	"log"
+	"time"
+)
+
+// AuditRecord 命令审计记录
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	InstanceID string    `json:"instance_id"`
+	Topic      string    `json:"topic"`
+	Command    string    `json:"command"`
+	RequestID  string    `json:"request_id"`
+	Namespace  string    `json:"namespace,omitempty"`
+	KeyID      string    `json:"key_id,omitempty"`
+	Caller     string    `json:"caller,omitempty"`
+	Reason     string    `json:"reason"`
+}
+
+// SetAuditHandler 设置审计记录处理函数，默认输出到日志
+func (r *CommandReceiver) SetAuditHandler(fn func(record AuditRecord)) {
+	r.auditHandler = fn
+}
+
+// audit 记录被拒绝的命令
+func (r *CommandReceiver) audit(topic string, cmd *Command, reason string) {
+	record := AuditRecord{
+		Time:       time.Now(),
+		InstanceID: r.instanceID,
+		Topic:      topic,
+		Command:    cmd.Command,
+		RequestID:  cmd.RequestID,
+		Namespace:  cmd.Namespace,
+		Caller:     cmd.Caller,
+		Reason:     reason,
+	}
+	if cmd.Signature != nil {
+		record.KeyID = cmd.Signature.KeyID
+	}
+
+	if r.auditHandler != nil {
+		r.auditHandler(record)
+		return
+	}
+	log.Printf("[%s] [AUDIT] 拒绝命令 %s (request_id=%s, caller=%s, key_id=%s, topic=%s): %s",
+		r.instanceID, record.Command, record.RequestID, record.Caller, record.KeyID, record.Topic, record.Reason)
+}
@@ -11,25 +11,53 @@ package sync
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"runtime"
-	"strconv"
+	"slices"
+	"strings"
+	gosync "sync"
 	"time"
 
-	mqtt "github.com/eclipse/paho.mqtt.golang"
-	"github.com/shirou/gopsutil/v4/process"
+	"github.com/HY-805/SubNodeSync/pkg/codec"
+	"github.com/HY-805/SubNodeSync/pkg/protocol"
+	"github.com/HY-805/SubNodeSync/pkg/transport"
 )
 
 // MQTT 主题格式常量
+//
+// Deprecated: 主题由 protocol.TopicScheme 生成，以下常量只对应默认前缀且不含租户段
 const (
 	TopicHeartbeat = "v1/subapp/pcs/%s/heartbeat" // 心跳主题
 	TopicRegister  = "v1/subapp/pcs/%s/register"  // 注册主题
 	TopicStatus    = "v1/subapp/pcs/%s/status"    // 状态主题
 	TopicControl   = "v1/subapp/pcs/%s/control"   // 控制主题
 	TopicConfig    = "v1/subapp/pcs/%s/config"    // 配置主题
+	TopicReply     = "v1/subapp/pcs/%s/%s/reply"  // 命令应答主题（节点名/实例ID）
+
+	TopicInstanceControl  = "v1/subapp/pcs/%s/%s/control" // 实例控制主题（节点名/实例ID）
+	TopicBroadcastControl = "v1/subapp/broadcast/control" // 全局广播控制主题
+	TopicGroupControl     = "v1/subapp/group/control"     // 标签分组控制主题
+)
+
+// DefaultClockSkewTolerance 默认允许的引擎与节点之间的时钟偏差
+const DefaultClockSkewTolerance = 30 * time.Second
+
+// replyHookTimeout 执行 AfterReply 回调前等待应答发布完成的最长时间
+const replyHookTimeout = 10 * time.Second
+
+// poolStopTimeout 停止时等待工作池中正在执行的命令完成的最长时间
+const poolStopTimeout = 5 * time.Second
+
+// CommandScope 命令作用范围
+type CommandScope string
+
+const (
+	ScopeInstance  CommandScope = "instance"  // 单个实例，Target 为实例ID
+	ScopeNode      CommandScope = "node"      // 同名节点的所有实例，Target 为节点名
+	ScopeBroadcast CommandScope = "broadcast" // 所有节点
+	ScopeGroup     CommandScope = "group"     // 标签匹配 Selector 的节点
 )
 
 // Command 命令结构
@@ -38,13 +66,92 @@ type Command struct {
 	Timestamp  string                 `json:"timestamp"`
 	RequestID  string                 `json:"request_id"`
 	Parameters map[string]interface{} `json:"parameters,omitempty"`
+
+	// 寻址信息，Scope 为空时按节点范围处理以兼容旧版引擎
+	Scope    CommandScope `json:"scope,omitempty"`
+	Target   string       `json:"target,omitempty"`
+	Selector string       `json:"selector,omitempty"` // 标签选择器，仅 ScopeGroup 使用
+
+	// 有效期，ExpiresAt 优先；TTL 为相对 Timestamp 的秒数
+	ExpiresAt string `json:"expires_at,omitempty"`
+	TTL       int64  `json:"ttl,omitempty"`
+
+	// 调用方身份，用于授权检查，包含在签名原文中
+	Caller string `json:"caller,omitempty"`
+
+	// 目标租户命名空间，接收器设置了命名空间时必须一致
+	Namespace string `json:"namespace,omitempty"`
+
+	// 命令签名，接收器配置了校验器时必须提供
+	Signature *CommandSignature `json:"signature,omitempty"`
+
+	// 命令消息使用的编解码器，应答使用相同的格式
+	codec codec.Codec
+
+	// 收到的命令消息体（JSON），签名校验基于原文而不是解码后的字段
+	raw []byte
+
+	// MQTT v5 命令消息的应答主题和关联数据，应答时原样使用
+	responseTopic   string
+	correlationData []byte
+
+	// 通过 Submit 提交的命令的应答回调，设置后应答不发布到应答主题
+	onReply func(reply *CommandReply)
+
+	// 通过 SubmitUnsigned 提交的命令，不校验签名
+	unsigned bool
+}
+
+// Deadline 返回命令的过期时间，未设置有效期时 ok 为 false
+func (c *Command) Deadline() (deadline time.Time, ok bool, err error) {
+	if c.ExpiresAt != "" {
+		deadline, err = time.Parse(time.RFC3339, c.ExpiresAt)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid expires_at %q: %w", c.ExpiresAt, err)
+		}
+		return deadline, true, nil
+	}
+	if c.TTL > 0 {
+		issuedAt, err := time.Parse(time.RFC3339, c.Timestamp)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid timestamp %q for ttl: %w", c.Timestamp, err)
+		}
+		return issuedAt.Add(time.Duration(c.TTL) * time.Second), true, nil
+	}
+	return time.Time{}, false, nil
 }
 
 // CommandResult 命令执行结果
 type CommandResult struct {
-	Success   bool   `json:"success"`
-	Message   string `json:"message"`
-	RequestID string `json:"request_id,omitempty"`
+	Success   bool        `json:"success"`
+	Message   string      `json:"message"`
+	RequestID string      `json:"request_id,omitempty"`
+	Code      ErrorCode   `json:"code,omitempty"` // 失败时的错误码
+	Data      interface{} `json:"data,omitempty"` // 结构化结果数据，序列化为任意JSON
+}
+
+// CommandReply 命令应答消息
+// 发布到应答主题，引擎通过 RequestID 将其与发出的 Command 关联
+type CommandReply struct {
+	RequestID  string      `json:"request_id"`
+	Command    string      `json:"command"`
+	NodeName   string      `json:"node_name"`
+	InstanceID string      `json:"instance_id"`
+	Success    bool        `json:"success"`
+	Code       ErrorCode   `json:"code,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+	Event      JobEvent    `json:"event,omitempty"`    // 异步命令事件，同步命令为空
+	Progress   int         `json:"progress,omitempty"` // 异步命令进度（0-100）
+	ReceivedAt string      `json:"received_at"`
+	FinishedAt string      `json:"finished_at"`
+	DurationMs int64       `json:"duration_ms"`
+
+	codec           codec.Codec
+	responseTopic   string
+	correlationData []byte
+	onReply         func(reply *CommandReply)
 }
 
 // CommandHandler 命令处理器接口
@@ -62,38 +169,109 @@ const (
 	ReceiverStatusError   ReceiverStatus = "error"
 )
 
+// 注册消息中上报的节点能力
+const (
+	CapabilityMQTTControl     = "mqtt_control"     // 通过 MQTT 接收控制命令
+	CapabilityHeartbeat       = "heartbeat"        // 定期发送心跳
+	CapabilityAsyncJobs       = "async_jobs"       // 注册了异步命令，支持 jobs 查询和 cancel
+	CapabilityMQTT5Properties = "mqtt5_properties" // 应答携带 MQTT v5 关联数据、内容类型等消息属性
+	CapabilitySignedCommands  = "signed_commands"  // 校验命令签名
+	CapabilityAuthorization   = "authorization"    // 按调用方授权命令
+	CapabilityIdempotency     = "idempotency"      // 按 RequestID 去重
+	CapabilitySparkplug       = "sparkplug_b"      // 同时作为 Sparkplug B 边缘节点
+)
+
 // CommandReceiver MQTT命令接收器
 type CommandReceiver struct {
 	nodeName   string
 	instanceID string
 	brokerURL  string
-	client     mqtt.Client
-	handlers   map[string]CommandHandler
+	tlsConfig  *transport.TLSConfig
+	mqttProto  byte
+	sessionTTL time.Duration
+	messageTTL time.Duration
+	transport  transport.Transport
+	handlers   map[string]*handlerEntry
+	handlersMu gosync.RWMutex
+	labels     map[string]string
 	status     ReceiverStatus
+	statusMu   gosync.RWMutex
 	nodeCtx    *NodeContext
 	cancelFunc context.CancelFunc
+
+	// 未匹配任何处理器时使用的默认处理器
+	defaultHandler *handlerEntry
+
+	// 发布消息使用的编解码器
+	codec codec.Codec
+
+	// 主题规划
+	topics *protocol.TopicScheme
+
+	// 租户命名空间，为空时不检查命令的命名空间
+	namespace string
+
+	// 按 RequestID 去重，为nil时不去重
+	idempotency *idempotencyCache
+
+	// 判断命令过期时允许的时钟偏差
+	clockSkew time.Duration
+	// 过期检查使用的当前时间，测试中可替换
+	now func() time.Time
+
+	// 正在执行的异步命令
+	jobs *jobRegistry
+
+	// 全局中间件
+	middlewares []Middleware
+
+	// 执行命令的工作池，Start 时创建
+	pool       *workerPool
+	poolConfig *WorkerPoolConfig
+
+	// 命令签名校验器，为nil时不校验
+	verifier     *CommandVerifier
+	auditHandler func(record AuditRecord)
+
+	// 命令授权器，为nil时不检查
+	authorizer *PolicyAuthorizer
+
+	// AddCapabilities 添加的能力，随注册消息上报
+	extraCapabilities []string
 }
 
 // NewCommandReceiver 创建命令接收器
 func NewCommandReceiver(nodeName, brokerURL string) *CommandReceiver {
-	return &CommandReceiver{
-		nodeName:   nodeName,
-		instanceID: nodeName,
-		brokerURL:  brokerURL,
-		handlers:   make(map[string]CommandHandler),
-		status:     ReceiverStatusStopped,
-	}
+	return NewCommandReceiverWithInstanceID(nodeName, nodeName, brokerURL)
 }
 
 // NewCommandReceiverWithInstanceID 创建带实例ID的命令接收器
+// 默认启用仅保存在内存中的命令幂等缓存，并注册内置的 cancel、jobs 命令
 func NewCommandReceiverWithInstanceID(nodeName, instanceID, brokerURL string) *CommandReceiver {
-	return &CommandReceiver{
-		nodeName:   nodeName,
-		instanceID: instanceID,
-		brokerURL:  brokerURL,
-		handlers:   make(map[string]CommandHandler),
-		status:     ReceiverStatusStopped,
+	idempotency, _ := newIdempotencyCache(DefaultIdempotencyConfig())
+	r := &CommandReceiver{
+		nodeName:    nodeName,
+		instanceID:  instanceID,
+		brokerURL:   brokerURL,
+		handlers:    make(map[string]*handlerEntry),
+		status:      ReceiverStatusStopped,
+		idempotency: idempotency,
+		clockSkew:   DefaultClockSkewTolerance,
+		now:         time.Now,
+		jobs:        newJobRegistry(),
+		poolConfig:  DefaultWorkerPoolConfig(),
+		codec:       codec.Default(),
+		topics:      protocol.DefaultTopicScheme(),
 	}
+	r.registerBuiltinHandlers()
+	return r
+}
+
+// NewCommandReceiverWithTransport 创建使用指定传输层的命令接收器
+func NewCommandReceiverWithTransport(nodeName, instanceID string, t transport.Transport) *CommandReceiver {
+	r := NewCommandReceiverWithInstanceID(nodeName, instanceID, "")
+	r.SetTransport(t)
+	return r
 }
 
 // Start 启动命令接收器
@@ -104,44 +282,75 @@ func (r *CommandReceiver) Start(ctx context.Context) error {
 		r.nodeCtx = NewNodeContext(ctx, r.nodeName, "")
 	}
 
+	if r.authorizer != nil && r.verifier == nil {
+		return ErrAuthorizerWithoutVerifier
+	}
+	// 节点名和实例ID是控制主题中的一段，含有通配符时会订阅到其他节点的命令
+	if err := protocol.ValidateSegment(r.nodeName); err != nil {
+		return fmt.Errorf("invalid node name: %w", err)
+	}
+	if err := protocol.ValidateSegment(r.instanceID); err != nil {
+		return fmt.Errorf("invalid instance ID: %w", err)
+	}
+
 	// 创建可取消的上下文
 	ctx, r.cancelFunc = context.WithCancel(ctx)
 
-	// 配置MQTT客户端
-	opts := mqtt.NewClientOptions()
-	opts.AddBroker(r.brokerURL)
-	opts.SetClientID(fmt.Sprintf("%s-receiver", r.instanceID))
-	opts.SetAutoReconnect(true)
-	opts.SetCleanSession(false)
-	opts.SetKeepAlive(60 * time.Second)
+	// 启动命令工作池
+	r.pool = newWorkerPool(r.poolConfig)
 
-	// 连接成功回调
-	opts.OnConnect = func(client mqtt.Client) {
-		log.Printf("[%s] MQTT命令接收器已连接", r.instanceID)
-		// 订阅控制主题
-		controlTopic := fmt.Sprintf(TopicControl, r.nodeName)
-		if token := client.Subscribe(controlTopic, 1, r.handleControlMessage); token.Wait() && token.Error() != nil {
-			log.Printf("[%s] 订阅控制主题失败: %v", r.instanceID, token.Error())
-		} else {
-			log.Printf("[%s] 已订阅控制主题: %s", r.instanceID, controlTopic)
+	// 未指定传输层时使用 paho MQTT 客户端
+	if r.transport == nil {
+		r.transport = transport.NewTransport(&transport.MQTTConfig{
+			BrokerURL:         r.brokerURL,
+			ClientID:          fmt.Sprintf("%s-receiver", r.instanceID),
+			KeepAlive:         60 * time.Second,
+			PersistentSession: true,
+			TLS:               r.tlsConfig,
+			ProtocolVersion:   r.mqttProto,
+			SessionExpiry:     r.sessionTTL,
+			MessageExpiry:     r.messageTTL,
+		})
+	}
+
+	// 注册遗嘱，连接异常断开时由 broker 发布 offline 状态
+	if ws, ok := r.transport.(transport.WillSetter); ok {
+		will, err := r.stateMessage(protocol.StateOffline, protocol.OfflineReasonConnectionLost)
+		if err != nil {
+			return err
 		}
-		// 发送注册消息
-		r.sendRegisterMessage()
+		ws.SetWill(will)
 	}
 
+	// 连接成功回调，传输层已恢复控制主题订阅
+	r.transport.OnConnect(func() {
+		log.Printf("[%s] MQTT命令接收器已连接", r.instanceID)
+		// 发布在线状态和注册消息
+		r.publishState(protocol.StateOnline, "")
+		r.sendRegisterMessage()
+	})
+
 	// 连接丢失回调
-	opts.OnConnectionLost = func(client mqtt.Client, err error) {
+	r.transport.OnConnectionLost(func(err error) {
 		log.Printf("[%s] MQTT连接丢失: %v", r.instanceID, err)
+	})
+
+	// 订阅节点、实例和广播控制主题
+	for _, controlTopic := range r.controlTopics() {
+		if err := r.transport.Subscribe(controlTopic, 1, r.handleControlMessage); err != nil {
+			log.Printf("[%s] 订阅控制主题失败: %s, %v", r.instanceID, controlTopic, err)
+		}
 	}
 
-	// 创建并连接客户端
-	r.client = mqtt.NewClient(opts)
-	if token := r.client.Connect(); token.Wait() && token.Error() != nil {
-		r.status = ReceiverStatusError
-		return fmt.Errorf("MQTT连接失败: %w", token.Error())
+	if err := r.transport.Connect(); err != nil {
+		// 释放本次启动创建的资源，连接失败后可以再次调用 Start
+		r.cancelFunc()
+		r.pool.stop()
+		r.setStatus(ReceiverStatusError)
+		return fmt.Errorf("MQTT连接失败: %w", err)
 	}
 
-	r.status = ReceiverStatusRunning
+	r.setStatus(ReceiverStatusRunning)
 	r.nodeCtx.SetStatus(StatusRunning)
 
 	// 启动心跳发送
@@ -151,94 +360,807 @@ func (r *CommandReceiver) Start(ctx context.Context) error {
 }
 
 // Stop 停止命令接收器
+// 停止接收新命令后最多等待 poolStopTimeout 让执行中的命令完成，再将幂等缓存和 nonce 记录落盘
 func (r *CommandReceiver) Stop() error {
 	if r.cancelFunc != nil {
 		r.cancelFunc()
 	}
-	if r.client != nil && r.client.IsConnected() {
-		controlTopic := fmt.Sprintf(TopicControl, r.nodeName)
-		r.client.Unsubscribe(controlTopic)
-		r.client.Disconnect(250)
+	r.jobs.cancelAll()
+	if r.isConnected() {
+		// 正常断开时 broker 不发布遗嘱，需要主动发布离线状态
+		r.publishState(protocol.StateOffline, protocol.OfflineReasonShutdown)
+	}
+	// 离线时同样取消订阅并离开共享连接，由传输层在重连后补发或直接丢弃
+	if r.transport != nil {
+		r.transport.Unsubscribe(r.controlTopics()...)
+		r.transport.Disconnect()
+	}
+	if r.pool != nil {
+		r.pool.stop()
+		// 等待执行中的命令写入幂等缓存后再落盘，避免重启后重复执行
+		if !r.pool.wait(poolStopTimeout) {
+			log.Printf("[%s] 等待执行中的命令完成超时", r.instanceID)
+		}
+	}
+	if r.idempotency != nil {
+		r.idempotency.flush()
+	}
+	if r.verifier != nil {
+		r.verifier.flush()
 	}
-	r.status = ReceiverStatusStopped
+	r.setStatus(ReceiverStatusStopped)
 	return nil
 }
 
 // RegisterHandler 注册命令处理器
-func (r *CommandReceiver) RegisterHandler(command string, handler CommandHandler) error {
-	r.handlers[command] = handler
+// 可通过 opts 指定执行方式，如 WithAsync()
+func (r *CommandReceiver) RegisterHandler(command string, handler CommandHandler, opts ...HandlerOption) error {
+	if err := validateCommandPattern(command); err != nil {
+		return err
+	}
+	entry := newHandlerEntry(command, handler, opts)
+
+	r.handlersMu.Lock()
+	r.handlers[command] = entry
+	r.handlersMu.Unlock()
+
+	// 运行中注册的命令需要重新公布
+	r.readvertise()
 	return nil
 }
 
-// GetStatus 获取接收器状态
+// UnregisterHandler 移除命令处理器
+// 已提交执行的命令不受影响
+func (r *CommandReceiver) UnregisterHandler(command string) error {
+	r.handlersMu.Lock()
+	_, ok := r.handlers[command]
+	delete(r.handlers, command)
+	r.handlersMu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("command %q is not registered", command)
+	}
+	r.readvertise()
+	return nil
+}
+
+// Handlers 返回所有已注册命令的元数据，按名称排序
+func (r *CommandReceiver) Handlers() []CommandInfo {
+	return r.commandInfos()
+}
+
+// readvertise 已连接时重新发送注册消息，公布最新的命令列表
+func (r *CommandReceiver) readvertise() {
+	if r.isConnected() {
+		r.sendRegisterMessage()
+	}
+}
+
+// isConnected 返回传输层是否已连接
+func (r *CommandReceiver) isConnected() bool {
+	return r.transport != nil && r.transport.IsConnected()
+}
+
+// SetLabels 设置节点标签，用于匹配分组命令的标签选择器
+// 应在 Start 之前调用
+func (r *CommandReceiver) SetLabels(labels map[string]string) {
+	r.labels = make(map[string]string, len(labels))
+	for k, v := range labels {
+		r.labels[k] = v
+	}
+}
+
+// AddCapabilities 添加接收器之外的组件提供的能力（如 CapabilitySparkplug），随注册消息上报
+// 应在 Start 之前调用
+func (r *CommandReceiver) AddCapabilities(capabilities ...string) {
+	r.extraCapabilities = append(r.extraCapabilities, capabilities...)
+}
+
+// SetIdempotency 设置命令幂等配置，传入nil关闭去重
+// 配置了持久化路径时会加载已有记录，文件损坏时返回错误
+// 应在 Start 之前调用
+func (r *CommandReceiver) SetIdempotency(config *IdempotencyConfig) error {
+	if config == nil {
+		r.idempotency = nil
+		return nil
+	}
+	idempotency, err := newIdempotencyCache(config)
+	if err != nil {
+		return err
+	}
+	r.idempotency = idempotency
+	return nil
+}
+
+// SetVerifier 设置命令签名校验器
+// 设置后未签名、签名无效或重放的命令都会被拒绝并记录审计日志
+func (r *CommandReceiver) SetVerifier(verifier *CommandVerifier) {
+	r.verifier = verifier
+}
+
+// SetAuthorizer 设置命令授权器
+// 设置后调用方无权执行的命令会被拒绝并记录审计日志；
+// 授权依据的调用方只有经过签名校验才可信，Start 要求同时设置 SetVerifier
+func (r *CommandReceiver) SetAuthorizer(authorizer *PolicyAuthorizer) {
+	r.authorizer = authorizer
+}
+
+// SetCodec 设置注册、心跳等消息的编解码器，默认为 JSON
+// 命令应答使用与命令相同的格式，接收器可同时处理任意已注册格式的命令
+func (r *CommandReceiver) SetCodec(c codec.Codec) {
+	if c == nil {
+		c = codec.Default()
+	}
+	r.codec = c
+}
+
+// SetNamespace 设置租户命名空间，应在 Start 之前调用
+// 设置后只执行 Namespace 与之相同的命令，注册和心跳消息携带命名空间
+// 主题中的租户段由 SetTopicScheme 设置
+func (r *CommandReceiver) SetNamespace(namespace string) {
+	r.namespace = namespace
+}
+
+// SetTopicScheme 设置主题规划，应在 Start 之前调用
+func (r *CommandReceiver) SetTopicScheme(scheme *protocol.TopicScheme) {
+	if scheme == nil {
+		scheme = protocol.DefaultTopicScheme()
+	}
+	r.topics = scheme
+}
+
+// SetTransport 设置收发消息使用的传输层，应在 Start 之前调用
+// 未设置时 Start 使用 brokerURL 创建 paho MQTT 传输层
+func (r *CommandReceiver) SetTransport(t transport.Transport) {
+	r.transport = t
+}
+
+// SetTLSConfig 设置连接 broker 的 TLS 配置，应在 Start 之前调用
+// 仅在未通过 SetTransport 指定传输层时生效
+func (r *CommandReceiver) SetTLSConfig(config *transport.TLSConfig) {
+	r.tlsConfig = config
+}
+
+// SetProtocolVersion 设置 MQTT 协议版本，应在 Start 之前调用
+// transport.ProtocolV5 时命令应答发布到命令的 Response Topic 并带回 Correlation Data，
+// broker 不支持 v5 时自动改用 v3.1.1。仅在未通过 SetTransport 指定传输层时生效
+func (r *CommandReceiver) SetProtocolVersion(version byte) {
+	r.mqttProto = version
+}
+
+// SetSessionExpiry 设置 MQTT v5 会话过期时间，为0时使用 transport.DefaultSessionExpiry
+// 仅在未通过 SetTransport 指定传输层时生效
+func (r *CommandReceiver) SetSessionExpiry(d time.Duration) {
+	r.sessionTTL = d
+}
+
+// SetMessageExpiry 设置 MQTT v5 注册、心跳和应答消息的过期时间，为0时不过期
+// 仅在未通过 SetTransport 指定传输层时生效
+func (r *CommandReceiver) SetMessageExpiry(d time.Duration) {
+	r.messageTTL = d
+}
+
+// SetWorkerPool 设置执行命令的工作池大小和队列长度
+// 应在 Start 之前调用
+func (r *CommandReceiver) SetWorkerPool(config *WorkerPoolConfig) {
+	if config == nil {
+		config = DefaultWorkerPoolConfig()
+	}
+	r.poolConfig = config
+}
+
+// SetClockSkewTolerance 设置判断命令过期时允许的时钟偏差
+// 命令在 过期时间+偏差 之后到达才会被拒绝
+func (r *CommandReceiver) SetClockSkewTolerance(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	r.clockSkew = d
+}
+
+// GetLabels 获取节点标签
+func (r *CommandReceiver) GetLabels() map[string]string {
+	return r.labels
+}
+
+// GetStatus 获取接收器状态，可在 Start、Stop 执行期间从其他 goroutine 调用
 func (r *CommandReceiver) GetStatus() ReceiverStatus {
+	r.statusMu.RLock()
+	defer r.statusMu.RUnlock()
 	return r.status
 }
 
+func (r *CommandReceiver) setStatus(status ReceiverStatus) {
+	r.statusMu.Lock()
+	r.status = status
+	r.statusMu.Unlock()
+}
+
+// controlTopics 返回接收器订阅的所有控制主题
+func (r *CommandReceiver) controlTopics() []string {
+	return []string{
+		r.topics.Control(r.nodeName),
+		r.topics.InstanceControl(r.nodeName, r.instanceID),
+		r.topics.BroadcastControl(),
+		r.topics.GroupControl(),
+	}
+}
+
+// acceptsCommand 判断命令的寻址范围是否包含当前实例
+func (r *CommandReceiver) acceptsCommand(topic string, cmd *Command) bool {
+	switch cmd.Scope {
+	case "", ScopeNode:
+		return cmd.Target == "" || cmd.Target == r.nodeName
+	case ScopeInstance:
+		// 未指定Target时，只接受从实例主题收到的命令，避免同名副本重复执行
+		if cmd.Target == "" {
+			return topic == r.topics.InstanceControl(r.nodeName, r.instanceID)
+		}
+		return cmd.Target == r.instanceID
+	case ScopeBroadcast:
+		return true
+	case ScopeGroup:
+		// 空选择器匹配所有节点，选择器缺失的分组命令不应在整个集群执行
+		if strings.TrimSpace(cmd.Selector) == "" {
+			log.Printf("[%s] 忽略未指定标签选择器的分组命令: %s", r.instanceID, cmd.Command)
+			return false
+		}
+		selector, err := ParseSelector(cmd.Selector)
+		if err != nil {
+			log.Printf("[%s] 解析标签选择器失败: %v", r.instanceID, err)
+			return false
+		}
+		return selector.Matches(r.labels)
+	default:
+		return false
+	}
+}
+
 // handleControlMessage 处理控制消息
-func (r *CommandReceiver) handleControlMessage(client mqtt.Client, msg mqtt.Message) {
+func (r *CommandReceiver) handleControlMessage(msg *transport.Message) {
+	// 兼容无信封的旧版本命令
+	env, cmdCodec, err := protocol.Decode(msg.Payload)
+	if err != nil {
+		log.Printf("[%s] 解析控制消息失败: %v", r.nodeName, err)
+		return
+	}
 	var cmd Command
-	if err := json.Unmarshal(msg.Payload(), &cmd); err != nil {
+	if err := env.DecodeBody(&cmd); err != nil {
 		log.Printf("[%s] 解析控制消息失败: %v", r.nodeName, err)
 		return
 	}
+	if cmd.Command == "" && !r.legacyAction(msg.Topic, env, &cmd) {
+		return
+	}
+	if cmd.Signature != nil {
+		if cmd.raw, err = env.BodyJSON(); err != nil {
+			log.Printf("[%s] 解析控制消息失败: %v", r.nodeName, err)
+			return
+		}
+	}
+	cmd.codec = cmdCodec
+	if msg.Properties != nil {
+		cmd.responseTopic = msg.Properties.ResponseTopic
+		cmd.correlationData = msg.Properties.CorrelationData
+	}
+
+	if !r.acceptsCommand(msg.Topic, &cmd) {
+		log.Printf("[%s] 忽略非本实例的命令: %s (scope=%s, target=%s)", r.instanceID, cmd.Command, cmd.Scope, cmd.Target)
+		return
+	}
 
 	log.Printf("[%s] 收到控制命令: %s", r.nodeName, cmd.Command)
+	r.process(msg.Topic, &cmd)
+}
+
+// legacyAction 将旧版本的 {"action": "stop"} 控制消息转换为同名命令
+// 转换后的命令与其他命令一样经过签名、命名空间和授权检查；只接受节点控制主题上的旧版本消息
+func (r *CommandReceiver) legacyAction(topic string, env *protocol.Envelope, cmd *Command) bool {
+	var legacy struct {
+		Action string                 `json:"action"`
+		Params map[string]interface{} `json:"params,omitempty"`
+	}
+	if err := env.DecodeBody(&legacy); err != nil || legacy.Action == "" {
+		return false
+	}
+	if topic != r.topics.Control(r.nodeName) {
+		log.Printf("[%s] 忽略非节点控制主题上的旧版本控制消息: %s", r.instanceID, legacy.Action)
+		return false
+	}
+	cmd.Command = legacy.Action
+	if cmd.Parameters == nil {
+		cmd.Parameters = legacy.Params
+	}
+	return true
+}
+
+// Submit 执行从其他入口（如 Sparkplug NCMD）收到的命令
+// 命令与控制主题上的命令一样经过签名、授权、过期和幂等检查，
+// 应答（含异步命令的事件）交给 onReply，不发布到应答主题；source 用于审计日志
+func (r *CommandReceiver) Submit(source string, cmd *Command, onReply func(reply *CommandReply)) {
+	cmd.onReply = onReply
+	log.Printf("[%s] 收到命令: %s (%s)", r.nodeName, cmd.Command, source)
+	r.process(source, cmd)
+}
+
+// SubmitUnsigned 与 Submit 相同，但不校验签名
+// 用于没有签名机制的入口（如 Sparkplug NCMD），由调用方限制可执行的命令并负责入口自身的访问控制；
+// 命名空间、授权、过期和幂等检查照常进行
+func (r *CommandReceiver) SubmitUnsigned(source string, cmd *Command, onReply func(reply *CommandReply)) {
+	cmd.unsigned = true
+	r.Submit(source, cmd, onReply)
+}
+
+// process 检查命令后查找处理器执行
+func (r *CommandReceiver) process(topic string, cmd *Command) {
+	if !r.authenticate(topic, cmd) || !r.authorize(topic, cmd) {
+		return
+	}
+
+	// 重复投递先于过期检查：已执行过的命令在过期后重发，仍得到原来的应答
+	if r.replayed(cmd) {
+		return
+	}
+
+	if result := r.checkExpiry(cmd); result != nil {
+		log.Printf("[%s] 拒绝命令 %s: %s", r.instanceID, cmd.Command, result.Message)
+		r.publishReply(newCommandReply(r.nodeName, r.instanceID, cmd, result, nil, time.Now()))
+		return
+	}
 
 	// 查找并执行处理器
-	if handler, ok := r.handlers[cmd.Command]; ok {
-		ctx := WithNodeContext(context.Background(), r.nodeCtx)
-		result, err := handler.Handle(ctx, &cmd)
-		if err != nil {
-			log.Printf("[%s] 命令执行失败: %v", r.nodeName, err)
-		} else {
-			log.Printf("[%s] 命令执行结果: %+v", r.nodeName, result)
+	if entry, ok := r.lookupHandler(cmd.Command); ok {
+		if r.isDuplicate(cmd) {
+			return
 		}
+
+		r.dispatch(entry, cmd, time.Now())
 	} else {
 		log.Printf("[%s] 未找到命令处理器: %s", r.nodeName, cmd.Command)
+		result := NewErrorResult(cmd.RequestID, ErrCodeNotFound, "unknown command "+cmd.Command)
+		r.publishReply(newCommandReply(r.nodeName, r.instanceID, cmd, result, nil, time.Now()))
+	}
+}
+
+// dispatch 按并发策略将命令提交到工作池执行
+func (r *CommandReceiver) dispatch(entry *handlerEntry, cmd *Command, receivedAt time.Time) {
+	handler := r.chain(entry)
+	if entry.inline {
+		r.runSync(context.Background(), handler, cmd, receivedAt)
+		return
+	}
+
+	if !entry.limiter.admit() {
+		r.rejectBusy(cmd, receivedAt, fmt.Sprintf("command %s is already running (%s)", cmd.Command, entry.concurrency.Mode))
+		return
+	}
+
+	// 处理器超时返回后仍在运行时，执行记录使槽位保持到处理器实际返回
+	exec := &execution{}
+	ctx := withExecution(context.Background(), exec)
+
+	var task, abort func()
+	if entry.async {
+		var err error
+		if task, abort, err = r.asyncTask(ctx, handler, cmd, receivedAt); err != nil {
+			entry.limiter.withdraw()
+			r.rejectBusy(cmd, receivedAt, err.Error())
+			return
+		}
+	} else {
+		task = func() { r.runSync(ctx, handler, cmd, receivedAt) }
+	}
+
+	submitted := r.pool != nil && entry.limiter.submit(r.pool, exec, task)
+	if !submitted {
+		entry.limiter.withdraw()
+		if abort != nil {
+			abort()
+		}
+		r.rejectBusy(cmd, receivedAt, "command queue is full")
+	}
+}
+
+// runSync 同步执行命令并发布应答
+// 处理器通过 AfterReply 注册了回调时，等待应答发布完成（最长 replyHookTimeout）后在新的 goroutine 中执行回调，
+// 回调中停止接收器时不会等待当前工作协程
+func (r *CommandReceiver) runSync(parent context.Context, handler CommandHandler, cmd *Command, receivedAt time.Time) {
+	hooks := &replyHooks{}
+	ctx := WithNodeContext(context.WithValue(parent, replyHooksKey{}, hooks), r.nodeCtx)
+	result, err := handler.Handle(ctx, cmd)
+	if err != nil {
+		log.Printf("[%s] 命令执行失败: %v", r.nodeName, err)
+	} else {
+		log.Printf("[%s] 命令执行结果: %+v", r.nodeName, result)
+	}
+	reply := newCommandReply(r.nodeName, r.instanceID, cmd, result, err, receivedAt)
+	if r.idempotency != nil && cmd.RequestID != "" {
+		r.idempotency.complete(cmd.RequestID, reply)
+	}
+	published := r.publishReply(reply)
+
+	if !hooks.close() {
+		return
+	}
+	select {
+	case <-published:
+	case <-time.After(replyHookTimeout):
+		log.Printf("[%s] 等待命令应答发布超时: %s", r.instanceID, cmd.RequestID)
+	}
+	go hooks.run()
+}
+
+// replyHooksKey 用于在context中存储应答发布后的回调
+type replyHooksKey struct{}
+
+// replyHooks 同步命令的应答发布后依次执行的回调
+type replyHooks struct {
+	mu   gosync.Mutex
+	fns  []func()
+	done bool
+}
+
+// AfterReply 注册在命令应答发布完成（broker 确认或失败，最长等待 10 秒）后执行的回调，
+// 如 stop 命令在应答送达后再退出进程；回调在新的 goroutine 中执行
+// 只对同步执行的命令有效；context 不属于同步命令或应答已发布时返回false，回调不会执行
+func AfterReply(ctx context.Context, fn func()) bool {
+	hooks, ok := ctx.Value(replyHooksKey{}).(*replyHooks)
+	if !ok {
+		return false
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	if hooks.done {
+		return false
+	}
+	hooks.fns = append(hooks.fns, fn)
+	return true
+}
+
+// close 停止接受新的回调，返回是否有待执行的回调
+func (h *replyHooks) close() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.done = true
+	return len(h.fns) > 0
+}
+
+func (h *replyHooks) run() {
+	for _, fn := range h.fns {
+		fn()
+	}
+}
+
+// rejectBusy 拒绝无法执行的命令
+// 同时删除幂等记录，引擎可使用相同的 RequestID 重试
+func (r *CommandReceiver) rejectBusy(cmd *Command, receivedAt time.Time, message string) {
+	log.Printf("[%s] 拒绝命令 %s: %s", r.instanceID, cmd.Command, message)
+	if r.idempotency != nil && cmd.RequestID != "" {
+		r.idempotency.forget(cmd.RequestID)
+	}
+	result := NewErrorResult(cmd.RequestID, ErrCodeBusy, message)
+	r.publishReply(newCommandReply(r.nodeName, r.instanceID, cmd, result, nil, receivedAt))
+}
+
+// authenticate 校验命令签名，校验失败时发布 unauthorized 应答
+func (r *CommandReceiver) authenticate(topic string, cmd *Command) bool {
+	if r.verifier == nil || cmd.unsigned {
+		return true
+	}
+
+	err := r.verifier.Verify(cmd)
+	if err == nil {
+		return true
+	}
+
+	// 签名有效但 nonce 重复，可能是 MQTT 重复投递：与 isDuplicate 相同，
+	// 已完成的重发缓存的应答，仍在执行的直接忽略
+	if errors.Is(err, ErrCommandReplay) && r.replayed(cmd) {
+		return false
+	}
+
+	r.audit(topic, cmd, err.Error())
+	result := NewErrorResult(cmd.RequestID, ErrCodeUnauthorized, err.Error())
+	r.publishReply(newCommandReply(r.nodeName, r.instanceID, cmd, result, nil, time.Now()))
+	return false
+}
+
+// authorize 检查租户命名空间和调用方权限，不通过时发布 unauthorized 应答
+func (r *CommandReceiver) authorize(topic string, cmd *Command) bool {
+	var err error
+	switch {
+	case r.namespace != "" && cmd.Namespace != r.namespace:
+		// 不接受其他租户（或未声明租户）的命令
+		err = fmt.Errorf("command namespace %q does not match %q", cmd.Namespace, r.namespace)
+	case r.authorizer != nil:
+		err = r.authorizer.Authorize(cmd)
+	}
+	if err == nil {
+		return true
+	}
+
+	r.audit(topic, cmd, err.Error())
+	result := NewErrorResult(cmd.RequestID, ErrCodeUnauthorized, err.Error())
+	r.publishReply(newCommandReply(r.nodeName, r.instanceID, cmd, result, nil, time.Now()))
+	return false
+}
+
+// checkExpiry 检查命令是否已过期，未过期时返回nil
+// 例如节点离线期间积压在 broker 中的 stop 命令，不应在重连后执行
+func (r *CommandReceiver) checkExpiry(cmd *Command) *CommandResult {
+	deadline, ok, err := cmd.Deadline()
+	if err != nil {
+		return NewErrorResult(cmd.RequestID, ErrCodeInvalidParams, err.Error())
+	}
+	if !ok {
+		return nil
+	}
+	if now := r.now(); now.After(deadline.Add(r.clockSkew)) {
+		return NewErrorResult(cmd.RequestID, ErrCodeExpired,
+			fmt.Sprintf("command expired at %s (now %s, skew tolerance %s)",
+				deadline.Format(time.RFC3339), now.Format(time.RFC3339), r.clockSkew))
+	}
+	return nil
+}
+
+// isDuplicate 检查命令是否为重复投递
+// 已完成的重复命令会重发缓存的应答，未完成的直接忽略
+func (r *CommandReceiver) isDuplicate(cmd *Command) bool {
+	if r.idempotency == nil || cmd.RequestID == "" {
+		return false
 	}
+
+	cached, duplicate := r.idempotency.begin(cmd.RequestID)
+	if duplicate {
+		r.replayDuplicate(cached, cmd)
+	}
+	return duplicate
+}
+
+// replayed 只查询不登记，命令已收到过时按 isDuplicate 的方式处理
+// 用于过期检查和签名重放检查之前，此时命令尚未确定执行
+func (r *CommandReceiver) replayed(cmd *Command) bool {
+	if r.idempotency == nil || cmd.RequestID == "" {
+		return false
+	}
+
+	cached, found := r.idempotency.lookup(cmd.RequestID)
+	if found {
+		r.replayDuplicate(cached, cmd)
+	}
+	return found
+}
+
+// replayDuplicate 处理重复命令：已完成的重发缓存的应答，仍在执行的直接忽略
+func (r *CommandReceiver) replayDuplicate(cached *CommandReply, cmd *Command) {
+	if cached != nil {
+		log.Printf("[%s] 重复命令 %s，重发缓存的应答", r.instanceID, cmd.RequestID)
+		r.resendReply(cached, cmd)
+	} else {
+		log.Printf("[%s] 重复命令 %s 正在执行或上次执行未完成，忽略", r.instanceID, cmd.RequestID)
+	}
+}
+
+// resendReply 重发缓存的应答
+// 应答按本次收到的命令投递：使用其编解码器、应答主题、关联数据和回调，
+// 从持久化文件加载的记录不含这些信息，首次投递的回调也可能已失效
+func (r *CommandReceiver) resendReply(cached *CommandReply, cmd *Command) {
+	reply := *cached
+	reply.codec = cmd.codec
+	reply.responseTopic = cmd.responseTopic
+	reply.correlationData = cmd.correlationData
+	reply.onReply = cmd.onReply
+	r.publishReply(&reply)
+}
+
+// newCommandReply 根据处理结果构建应答消息
+// 处理器返回的错误优先于结果中的 Success 字段
+func newCommandReply(nodeName, instanceID string, cmd *Command, result *CommandResult, err error, receivedAt time.Time) *CommandReply {
+	finishedAt := time.Now()
+	reply := &CommandReply{
+		RequestID:  cmd.RequestID,
+		Command:    cmd.Command,
+		NodeName:   nodeName,
+		InstanceID: instanceID,
+		Success:    true,
+		ReceivedAt: receivedAt.Format(time.RFC3339),
+		FinishedAt: finishedAt.Format(time.RFC3339),
+		DurationMs: finishedAt.Sub(receivedAt).Milliseconds(),
+		codec:      cmd.codec,
+
+		responseTopic:   cmd.responseTopic,
+		correlationData: cmd.correlationData,
+		onReply:         cmd.onReply,
+	}
+	if result != nil {
+		reply.Success = result.Success
+		reply.Code = result.Code
+		reply.Message = result.Message
+		reply.Data = result.Data
+	}
+	if err != nil {
+		reply.Success = false
+		reply.Error = err.Error()
+		if reply.Code == "" {
+			reply.Code = ErrorCodeOf(err)
+		}
+	}
+	return reply
+}
+
+// publishReply 发布命令应答，返回的通道在发布完成（含失败）后关闭
+func (r *CommandReceiver) publishReply(reply *CommandReply) <-chan struct{} {
+	done := make(chan struct{})
+	if reply.onReply != nil {
+		reply.onReply(reply)
+		close(done)
+		return done
+	}
+	if !r.isConnected() {
+		log.Printf("[%s] MQTT未连接，丢弃命令应答: %s", r.instanceID, reply.RequestID)
+		close(done)
+		return done
+	}
+
+	replyCodec := reply.codec
+	if replyCodec == nil {
+		replyCodec = r.codec
+	}
+	env := protocol.NewEnvelope(protocol.TypeReply, r.instanceID, reply).WithCorrelationID(reply.RequestID)
+	payload, err := protocol.Encode(replyCodec, env)
+	if err != nil {
+		log.Printf("[%s] 编码命令应答失败: %v", r.instanceID, err)
+		close(done)
+		return done
+	}
+	topic := r.topics.Reply(r.nodeName, r.instanceID)
+	if reply.responseTopic != "" {
+		topic = reply.responseTopic
+	}
+
+	// 支持 MQTT v5 时通过消息属性携带关联数据和内容类型
+	publish := func() error {
+		return r.transport.Publish(topic, 1, false, payload)
+	}
+	if publisher, ok := r.transport.(transport.PropertyPublisher); ok {
+		correlationData := reply.correlationData
+		if correlationData == nil {
+			correlationData = []byte(reply.RequestID)
+		}
+		msg := &transport.Message{
+			Topic:   topic,
+			Payload: payload,
+			QoS:     1,
+			Properties: &transport.MessageProperties{
+				ContentType:     replyCodec.ContentType(),
+				CorrelationData: correlationData,
+				UserProperties: map[string]string{
+					"request_id":  reply.RequestID,
+					"instance_id": r.instanceID,
+				},
+			},
+		}
+		publish = func() error {
+			return publisher.PublishMessage(msg)
+		}
+	}
+
+	// 应答可能在消息回调中发布，不在回调内阻塞等待确认
+	go func() {
+		defer close(done)
+		if err := publish(); err != nil {
+			log.Printf("[%s] 发送命令应答失败: %v", r.instanceID, err)
+		}
+	}()
+	return done
 }
 
 // sendRegisterMessage 发送注册消息
 func (r *CommandReceiver) sendRegisterMessage() {
-	registerMsg := map[string]interface{}{
-		"timestamp":   time.Now().Format(time.RFC3339),
-		"app_name":    r.nodeName,
-		"instance_id": r.instanceID,
-		"version":     r.nodeCtx.GetVersion(),
-		"pid":         os.Getpid(),
-		"start_time":  r.nodeCtx.GetStartTime().Format(time.RFC3339),
-		"capabilities": []string{
-			"mqtt_control",
-			"heartbeat",
-		},
-		"metadata": map[string]string{
+	body := &protocol.RegisterBody{
+		Namespace:  r.namespace,
+		AppName:    r.nodeName,
+		InstanceID: r.instanceID,
+		Version:    r.nodeCtx.GetVersion(),
+		PID:        os.Getpid(),
+		StartTime:  r.nodeCtx.GetStartTime().Format(time.RFC3339),
+		Capabilities: r.capabilities(),
+		Commands:     r.commandInfos(),
+		Metadata: map[string]string{
 			"hostname": getHostname(),
 		},
+		Labels:     r.labels,
+		AppVersion: r.buildInfo(),
 	}
 
-	// 如果有完整的版本信息，则添加到消息中
-	if nodeVersion := r.nodeCtx.GetNodeVersion(); nodeVersion != nil {
-		registerMsg["app_version"] = map[string]interface{}{
-			"git_version":    nodeVersion.GitVersion,
-			"git_commit":     nodeVersion.GitCommit,
-			"git_tree_state": nodeVersion.GitTreeState,
-			"build_date":     nodeVersion.BuildDate,
-			"go_version":     nodeVersion.GoVersion,
-			"compiler":       nodeVersion.Compiler,
-			"platform":       nodeVersion.Platform,
-		}
+	payload, err := protocol.Encode(r.codec, protocol.NewEnvelope(protocol.TypeRegister, r.instanceID, body))
+	if err != nil {
+		log.Printf("[%s] 编码注册消息失败: %v", r.instanceID, err)
+		return
 	}
-
-	payload, _ := json.Marshal(registerMsg)
-	topic := fmt.Sprintf(TopicRegister, r.nodeName)
-	if token := r.client.Publish(topic, 1, false, payload); token.Wait() && token.Error() != nil {
-		log.Printf("[%s] 发送注册消息失败: %v", r.instanceID, token.Error())
+	topic := r.topics.Register(r.nodeName)
+	if err := r.transport.Publish(topic, 1, false, payload); err != nil {
+		log.Printf("[%s] 发送注册消息失败: %v", r.instanceID, err)
 	} else {
 		log.Printf("[%s] 已发送注册消息", r.instanceID)
 	}
 }
 
+// capabilities 根据已启用的功能生成注册消息中的能力列表
+func (r *CommandReceiver) capabilities() []string {
+	capabilities := []string{CapabilityMQTTControl, CapabilityHeartbeat}
+	if r.hasAsyncHandler() {
+		capabilities = append(capabilities, CapabilityAsyncJobs)
+	}
+	if transport.SupportsProperties(r.transport) {
+		capabilities = append(capabilities, CapabilityMQTT5Properties)
+	}
+	if r.verifier != nil {
+		capabilities = append(capabilities, CapabilitySignedCommands)
+	}
+	if r.authorizer != nil {
+		capabilities = append(capabilities, CapabilityAuthorization)
+	}
+	if r.idempotency != nil {
+		capabilities = append(capabilities, CapabilityIdempotency)
+	}
+	for _, capability := range r.extraCapabilities {
+		if !slices.Contains(capabilities, capability) {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	return capabilities
+}
+
+// stateMessage 生成发布到 state 主题的在线状态保留消息
+func (r *CommandReceiver) stateMessage(state, reason string) (*transport.Message, error) {
+	body := &protocol.StateBody{
+		Namespace:  r.namespace,
+		AppName:    r.nodeName,
+		InstanceID: r.instanceID,
+		State:      state,
+		Reason:     reason,
+	}
+	payload, err := protocol.Encode(r.codec, protocol.NewEnvelope(protocol.TypeState, r.instanceID, body))
+	if err != nil {
+		return nil, fmt.Errorf("编码在线状态失败: %w", err)
+	}
+	return &transport.Message{
+		Topic:    r.topics.State(r.nodeName, r.instanceID),
+		Payload:  payload,
+		QoS:      1,
+		Retained: true,
+	}, nil
+}
+
+// publishState 发布在线状态
+func (r *CommandReceiver) publishState(state, reason string) {
+	msg, err := r.stateMessage(state, reason)
+	if err == nil {
+		err = r.transport.Publish(msg.Topic, msg.QoS, msg.Retained, msg.Payload)
+	}
+	if err != nil {
+		log.Printf("[%s] 发布%s状态失败: %v", r.instanceID, state, err)
+	}
+}
+
+// buildInfo 返回完整的版本信息，未设置时返回nil
+func (r *CommandReceiver) buildInfo() *protocol.BuildInfo {
+	nodeVersion := r.nodeCtx.GetNodeVersion()
+	if nodeVersion == nil {
+		return nil
+	}
+	return &protocol.BuildInfo{
+		GitVersion:   nodeVersion.GitVersion,
+		GitCommit:    nodeVersion.GitCommit,
+		GitTreeState: nodeVersion.GitTreeState,
+		BuildDate:    nodeVersion.BuildDate,
+		GoVersion:    nodeVersion.GoVersion,
+		Compiler:     nodeVersion.Compiler,
+		Platform:     nodeVersion.Platform,
+	}
+}
+
 // heartbeatLoop 心跳发送循环
 func (r *CommandReceiver) heartbeatLoop(ctx context.Context) {
 	ticker := time.NewTicker(30 * time.Second)
@@ -261,78 +1183,32 @@ func (r *CommandReceiver) heartbeatLoop(ctx context.Context) {
 
 // sendHeartbeat 发送心跳消息
 func (r *CommandReceiver) sendHeartbeat() {
-	if r.client == nil || !r.client.IsConnected() {
+	if !r.isConnected() {
 		return
 	}
 
-	// 获取系统监控数据
-	var m runtime.MemStats
-	runtime.ReadMemStats(&m)
-	goroutineCount := runtime.NumGoroutine()
-
-	// 获取CPU使用率
-	cpuUsage := r.getCPUUsage()
-
-	// 获取进程内存信息
-	var memoryUsageMB uint64 = 0
-	if p, err := process.NewProcess(int32(os.Getpid())); err == nil {
-		if memInfo, err := p.MemoryInfo(); err == nil {
-			memoryUsageMB = memInfo.RSS / 1024 / 1024
-		} else {
-			memoryUsageMB = uint64(m.Alloc / 1024 / 1024)
-		}
-	}
-
-	// 构建心跳消息
-	heartbeatMsg := map[string]interface{}{
-		"timestamp":   time.Now().Format(time.RFC3339),
-		"app_name":    r.nodeName,
-		"instance_id": r.instanceID,
-		"status":      string(r.nodeCtx.GetStatus()),
-		"pid":         os.Getpid(),
-		"uptime":      r.nodeCtx.GetUptime(),
-		"version":     r.nodeCtx.GetVersion(),
-		"hostname":    getHostname(),
-		"metrics": map[string]interface{}{
-			"process_cpu_usage_percent": strconv.FormatFloat(cpuUsage, 'f', 2, 64),
-			"process_memory_usage_mb":   int(memoryUsageMB),
-			"process_goroutine_count":   goroutineCount,
-		},
+	body := &protocol.HeartbeatBody{
+		Namespace:  r.namespace,
+		AppName:    r.nodeName,
+		InstanceID: r.instanceID,
+		Status:     string(r.nodeCtx.GetStatus()),
+		PID:        os.Getpid(),
+		Uptime:     r.nodeCtx.GetUptime(),
+		Version:    r.nodeCtx.GetVersion(),
+		Hostname:   getHostname(),
+		Metrics:    protocol.CollectProcessMetrics(),
+		AppVersion: r.buildInfo(),
 	}
 
-	// 添加版本信息
-	if nodeVersion := r.nodeCtx.GetNodeVersion(); nodeVersion != nil {
-		heartbeatMsg["app_version"] = map[string]interface{}{
-			"git_version":    nodeVersion.GitVersion,
-			"git_commit":     nodeVersion.GitCommit,
-			"git_tree_state": nodeVersion.GitTreeState,
-			"build_date":     nodeVersion.BuildDate,
-			"go_version":     nodeVersion.GoVersion,
-			"compiler":       nodeVersion.Compiler,
-			"platform":       nodeVersion.Platform,
-		}
-	}
-
-	payload, _ := json.Marshal(heartbeatMsg)
-	topic := fmt.Sprintf(TopicHeartbeat, r.nodeName)
-	if token := r.client.Publish(topic, 1, false, payload); token.Wait() && token.Error() != nil {
-		log.Printf("[%s] 发送心跳失败: %v", r.instanceID, token.Error())
-	}
-}
-
-// getCPUUsage 获取进程CPU使用率
-func (r *CommandReceiver) getCPUUsage() float64 {
-	p, err := process.NewProcess(int32(os.Getpid()))
+	payload, err := protocol.Encode(r.codec, protocol.NewEnvelope(protocol.TypeHeartbeat, r.instanceID, body))
 	if err != nil {
-		return 0.0
+		log.Printf("[%s] 编码心跳失败: %v", r.instanceID, err)
+		return
 	}
-
-	cpuPercent, err := p.Percent(500 * time.Millisecond)
-	if err != nil {
-		return 0.0
+	topic := r.topics.Heartbeat(r.nodeName)
+	if err := r.transport.Publish(topic, 1, false, payload); err != nil {
+		log.Printf("[%s] 发送心跳失败: %v", r.instanceID, err)
 	}
-
-	return cpuPercent
 }
 
 // getHostname 获取主机名
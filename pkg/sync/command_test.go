@@ -0,0 +1,528 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/HY-805/SubNodeSync/pkg/codec"
+	"github.com/HY-805/SubNodeSync/pkg/protocol"
+	"github.com/HY-805/SubNodeSync/pkg/transport"
+)
+
+const testTimeout = 5 * time.Second
+
+// receiverHarness 使用进程内 broker 的命令接收器，通过 Submit 提交命令并收集应答
+type receiverHarness struct {
+	t       *testing.T
+	r       *CommandReceiver
+	broker  *transport.MemoryBroker
+	replies chan *CommandReply
+}
+
+// newReceiverHarness 创建并启动接收器，setup 在 Start 之前调用
+func newReceiverHarness(t *testing.T, setup func(r *CommandReceiver)) *receiverHarness {
+	t.Helper()
+	broker := transport.NewMemoryBroker()
+	r := NewCommandReceiverWithTransport("node", "node-1", transport.NewMemoryTransport(broker))
+	if setup != nil {
+		setup(r)
+	}
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatalf("start receiver: %v", err)
+	}
+	t.Cleanup(func() { r.Stop() })
+	return &receiverHarness{t: t, r: r, broker: broker, replies: make(chan *CommandReply, 16)}
+}
+
+func (h *receiverHarness) submit(cmd *Command) {
+	h.r.Submit("test", cmd, func(reply *CommandReply) { h.replies <- reply })
+}
+
+// reply 等待下一条应答
+func (h *receiverHarness) reply() *CommandReply {
+	h.t.Helper()
+	select {
+	case reply := <-h.replies:
+		return reply
+	case <-time.After(testTimeout):
+		h.t.Fatal("no reply received")
+		return nil
+	}
+}
+
+// expectReply 等待下一条应答并检查错误码，成功应答的错误码为空
+func (h *receiverHarness) expectReply(requestID string, code ErrorCode) *CommandReply {
+	h.t.Helper()
+	reply := h.reply()
+	if reply.RequestID != requestID || reply.Code != code || reply.Success != (code == "") {
+		h.t.Fatalf("reply %s success=%v code=%q (%s%s), want %s code %q",
+			reply.RequestID, reply.Success, reply.Code, reply.Message, reply.Error, requestID, code)
+	}
+	return reply
+}
+
+// expectCode 提交命令并检查应答的错误码
+func (h *receiverHarness) expectCode(cmd *Command, code ErrorCode) *CommandReply {
+	h.t.Helper()
+	h.submit(cmd)
+	return h.expectReply(cmd.RequestID, code)
+}
+
+// noReply 确认短时间内没有应答
+func (h *receiverHarness) noReply() {
+	h.t.Helper()
+	select {
+	case reply := <-h.replies:
+		h.t.Fatalf("unexpected reply %s: %+v", reply.RequestID, reply)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// echoHandler 返回成功结果并统计调用次数
+func echoHandler(name string, calls *atomic.Int32) CommandHandler {
+	return NewCustomHandler(name, func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+		calls.Add(1)
+		return NewSuccessResult(cmd.RequestID, "ok", nil), nil
+	})
+}
+
+// blockingHandler 阻塞到 release 关闭，开始执行时向 started 发送 RequestID
+func blockingHandler(name string, started chan<- string, release <-chan struct{}) CommandHandler {
+	return NewCustomHandler(name, func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+		started <- cmd.RequestID
+		<-release
+		return NewSuccessResult(cmd.RequestID, "done", nil), nil
+	})
+}
+
+// sameReply 判断重发的应答是否为缓存的应答
+func sameReply(a, b *CommandReply) bool {
+	return a.RequestID == b.RequestID && a.Success == b.Success &&
+		a.ReceivedAt == b.ReceivedAt && a.FinishedAt == b.FinishedAt && a.DurationMs == b.DurationMs
+}
+
+func waitStarted(t *testing.T, started <-chan string, requestID string) {
+	t.Helper()
+	select {
+	case got := <-started:
+		if got != requestID {
+			t.Fatalf("started %s, want %s", got, requestID)
+		}
+	case <-time.After(testTimeout):
+		t.Fatalf("%s did not start", requestID)
+	}
+}
+
+func TestProcessDispatchesAndReportsUnknownCommand(t *testing.T) {
+	var calls atomic.Int32
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.RegisterHandler("echo", echoHandler("echo", &calls))
+		r.RegisterHandler("fail", NewCustomHandler("fail", func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+			return nil, errors.New("disk full")
+		}))
+	})
+
+	reply := h.expectCode(&Command{Command: "echo", RequestID: "r1"}, "")
+	if reply.NodeName != "node" || reply.InstanceID != "node-1" || reply.Command != "echo" {
+		t.Fatalf("reply identity = %+v", reply)
+	}
+	if reply.ReceivedAt == "" || reply.FinishedAt == "" {
+		t.Fatalf("reply timestamps = %q, %q", reply.ReceivedAt, reply.FinishedAt)
+	}
+
+	if reply := h.expectCode(&Command{Command: "fail", RequestID: "r2"}, ErrCodeInternal); reply.Error != "disk full" {
+		t.Fatalf("reply error = %q, want handler error", reply.Error)
+	}
+
+	h.expectCode(&Command{Command: "missing", RequestID: "r3"}, ErrCodeNotFound)
+	if calls.Load() != 1 {
+		t.Fatalf("handler called %d times, want 1", calls.Load())
+	}
+}
+
+func TestControlTopicCommandRoundTrip(t *testing.T) {
+	var calls atomic.Int32
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.RegisterHandler("echo", echoHandler("echo", &calls))
+		r.RegisterHandler("stop", echoHandler("stop", &calls))
+	})
+	topics := protocol.DefaultTopicScheme()
+
+	engine := transport.NewMemoryTransport(h.broker)
+	if err := engine.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Disconnect()
+	replies := make(chan *CommandReply, 4)
+	engine.Subscribe(topics.Reply("node", "node-1"), 1, func(msg *transport.Message) {
+		env, _, err := protocol.Decode(msg.Payload)
+		if err != nil {
+			t.Errorf("decode reply: %v", err)
+			return
+		}
+		var reply CommandReply
+		if err := env.DecodeBody(&reply); err != nil {
+			t.Errorf("decode reply body: %v", err)
+			return
+		}
+		replies <- &reply
+	})
+	waitReply := func(requestID string) {
+		t.Helper()
+		select {
+		case reply := <-replies:
+			if reply.RequestID != requestID || !reply.Success {
+				t.Fatalf("reply = %+v, want success for %s", reply, requestID)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("no reply for %s", requestID)
+		}
+	}
+
+	engine.Publish(topics.InstanceControl("node", "node-1"), 1, false,
+		[]byte(`{"command":"echo","request_id":"c1","scope":"instance"}`))
+	waitReply("c1")
+
+	// 其他实例的命令被忽略
+	engine.Publish(topics.Control("node"), 1, false,
+		[]byte(`{"command":"echo","request_id":"c2","scope":"instance","target":"node-2"}`))
+	// 未指定目标的实例命令只在实例主题上接受
+	engine.Publish(topics.Control("node"), 1, false,
+		[]byte(`{"command":"echo","request_id":"c3","scope":"instance"}`))
+
+	engine.Publish(topics.BroadcastControl(), 1, false, []byte(`{"command":"echo","request_id":"c4","scope":"broadcast"}`))
+	waitReply("c4")
+	engine.Publish(topics.Control("node"), 1, false, []byte(`{"command":"echo","request_id":"c5","target":"node"}`))
+	waitReply("c5")
+
+	// 旧版本 action 消息转换为同名命令，只在节点控制主题上接受
+	engine.Publish(topics.BroadcastControl(), 1, false, []byte(`{"action":"stop"}`))
+	engine.Publish(topics.Control("node"), 1, false, []byte(`{"action":"stop"}`))
+	waitReply("")
+
+	select {
+	case reply := <-replies:
+		t.Fatalf("unexpected reply %+v", reply)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if calls.Load() != 4 {
+		t.Fatalf("handlers called %d times, want 4", calls.Load())
+	}
+}
+
+func TestControlTopicRepliesInCommandCodec(t *testing.T) {
+	var calls atomic.Int32
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.RegisterHandler("echo", echoHandler("echo", &calls))
+	})
+	topics := protocol.DefaultTopicScheme()
+
+	engine := transport.NewMemoryTransport(h.broker)
+	if err := engine.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Disconnect()
+	type decoded struct {
+		reply       CommandReply
+		contentType string
+	}
+	replies := make(chan decoded, 4)
+	engine.Subscribe(topics.Reply("node", "node-1"), 1, func(msg *transport.Message) {
+		env, c, err := protocol.Decode(msg.Payload)
+		if err != nil {
+			t.Errorf("decode reply: %v", err)
+			return
+		}
+		var d decoded
+		if err := env.DecodeBody(&d.reply); err != nil {
+			t.Errorf("decode reply body: %v", err)
+			return
+		}
+		d.contentType = c.ContentType()
+		replies <- d
+	})
+
+	for _, c := range []codec.Codec{protocol.Protobuf, codec.CBOR, codec.MsgPack} {
+		cmd := &Command{Command: "echo", RequestID: c.ContentType(), Scope: ScopeInstance,
+			Parameters: map[string]interface{}{"n": 5}}
+		payload, err := protocol.Encode(c, protocol.NewEnvelope(protocol.TypeCommand, "engine", cmd))
+		if err != nil {
+			t.Fatalf("encode %s: %v", c.ContentType(), err)
+		}
+		engine.Publish(topics.InstanceControl("node", "node-1"), 1, false, payload)
+
+		select {
+		case d := <-replies:
+			if !d.reply.Success || d.reply.RequestID != c.ContentType() || d.contentType != c.ContentType() {
+				t.Fatalf("reply = %+v in %s, want success in %s", d.reply, d.contentType, c.ContentType())
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("no reply for %s command", c.ContentType())
+		}
+	}
+}
+
+func TestProcessRejectsExpiredCommands(t *testing.T) {
+	var calls atomic.Int32
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.SetClockSkewTolerance(10 * time.Second)
+		r.RegisterHandler("echo", echoHandler("echo", &calls))
+	})
+
+	now := time.Now()
+	h.expectCode(&Command{Command: "echo", RequestID: "expired",
+		ExpiresAt: now.Add(-time.Minute).Format(time.RFC3339)}, ErrCodeExpired)
+	h.expectCode(&Command{Command: "echo", RequestID: "ttl-expired",
+		Timestamp: now.Add(-time.Hour).Format(time.RFC3339), TTL: 60}, ErrCodeExpired)
+	h.expectCode(&Command{Command: "echo", RequestID: "bad-deadline", ExpiresAt: "tomorrow"}, ErrCodeInvalidParams)
+
+	// 在时钟偏差范围内仍然执行
+	h.expectCode(&Command{Command: "echo", RequestID: "within-skew",
+		ExpiresAt: now.Add(-2 * time.Second).Format(time.RFC3339)}, "")
+	h.expectCode(&Command{Command: "echo", RequestID: "ttl-valid",
+		Timestamp: now.Format(time.RFC3339), TTL: 60}, "")
+	if calls.Load() != 2 {
+		t.Fatalf("handler called %d times, want 2", calls.Load())
+	}
+
+	// 过期命令不登记幂等记录，重新签发后可以使用相同的 RequestID
+	h.expectCode(&Command{Command: "echo", RequestID: "expired"}, "")
+}
+
+func TestProcessReplaysCompletedCommandAfterExpiry(t *testing.T) {
+	var calls atomic.Int32
+	var offset atomic.Int64
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.SetClockSkewTolerance(0)
+		r.now = func() time.Time { return time.Now().Add(time.Duration(offset.Load())) }
+		r.RegisterHandler("echo", echoHandler("echo", &calls))
+	})
+
+	cmd := &Command{Command: "echo", RequestID: "r1", ExpiresAt: time.Now().Add(time.Minute).Format(time.RFC3339)}
+	first := h.expectCode(cmd, "")
+
+	// 时钟越过 expires_at 后重发，返回原来的应答而不是 expired
+	offset.Store(int64(2 * time.Minute))
+	if again := h.expectCode(cmd, ""); !sameReply(first, again) {
+		t.Fatalf("redelivered reply = %+v, want cached %+v", again, first)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("handler called %d times, want 1", calls.Load())
+	}
+
+	// 从未执行过的命令仍按过期拒绝
+	h.expectCode(&Command{Command: "echo", RequestID: "r2", ExpiresAt: cmd.ExpiresAt}, ErrCodeExpired)
+}
+
+func TestProcessChecksNamespace(t *testing.T) {
+	var calls atomic.Int32
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.SetNamespace("tenant-a")
+		r.RegisterHandler("echo", echoHandler("echo", &calls))
+	})
+
+	h.expectCode(&Command{Command: "echo", RequestID: "none"}, ErrCodeUnauthorized)
+	h.expectCode(&Command{Command: "echo", RequestID: "other", Namespace: "tenant-b"}, ErrCodeUnauthorized)
+	h.expectCode(&Command{Command: "echo", RequestID: "same", Namespace: "tenant-a"}, "")
+	if calls.Load() != 1 {
+		t.Fatalf("handler called %d times, want 1", calls.Load())
+	}
+}
+
+func TestReceiverStopReleasesSessionWhileOffline(t *testing.T) {
+	mt := transport.NewMemoryTransport(transport.NewMemoryBroker())
+	conn := transport.NewConnectionManager(mt)
+	other := conn.NewSession()
+	if err := other.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer other.Disconnect()
+
+	session := conn.NewSession()
+	r := NewCommandReceiverWithTransport("node", "node-1", session)
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 离线时停止的接收器同样离开共享连接，重连后不再使用连接
+	mt.SimulateConnectionLost(errors.New("network down"))
+	r.Stop()
+	if err := mt.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if session.IsConnected() || !other.IsConnected() {
+		t.Fatalf("after reconnect: receiver session=%v other=%v", session.IsConnected(), other.IsConnected())
+	}
+}
+
+func TestStartRejectsWildcardNodeName(t *testing.T) {
+	for _, ids := range [][2]string{{"node/+", "node-1"}, {"#", "node-1"}, {"node", "node-1/x"}, {"node", ""}} {
+		r := NewCommandReceiverWithTransport(ids[0], ids[1], transport.NewMemoryTransport(transport.NewMemoryBroker()))
+		if err := r.Start(context.Background()); err == nil {
+			r.Stop()
+			t.Errorf("Start with node %q instance %q succeeded", ids[0], ids[1])
+		}
+	}
+}
+
+func TestReceiverPublishesStateAndWill(t *testing.T) {
+	broker := transport.NewMemoryBroker()
+	watcher := transport.NewMemoryTransport(broker)
+	if err := watcher.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Disconnect()
+	states := make(chan *protocol.StateBody, 8)
+	watcher.Subscribe(protocol.DefaultTopicScheme().State("node", "node-1"), 1, func(msg *transport.Message) {
+		env, _, err := protocol.Decode(msg.Payload)
+		if err != nil {
+			t.Errorf("decode state: %v", err)
+			return
+		}
+		var state protocol.StateBody
+		if err := env.DecodeBody(&state); err != nil {
+			t.Errorf("decode state body: %v", err)
+			return
+		}
+		states <- &state
+	})
+	expectState := func(state, reason string) {
+		t.Helper()
+		select {
+		case got := <-states:
+			if got.State != state || got.Reason != reason || got.InstanceID != "node-1" {
+				t.Fatalf("state = %+v, want %s/%s", got, state, reason)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("no %s state published", state)
+		}
+	}
+
+	mt := transport.NewMemoryTransport(broker)
+	r := NewCommandReceiverWithTransport("node", "node-1", mt)
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expectState(protocol.StateOnline, "")
+
+	// 连接异常断开时 broker 发布遗嘱，重连后重新发布 online
+	mt.SimulateConnectionLost(errors.New("network down"))
+	expectState(protocol.StateOffline, protocol.OfflineReasonConnectionLost)
+	if err := mt.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	expectState(protocol.StateOnline, "")
+
+	// 正常停止时主动发布 offline
+	r.Stop()
+	expectState(protocol.StateOffline, protocol.OfflineReasonShutdown)
+	select {
+	case got := <-states:
+		t.Fatalf("unexpected state %+v", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// slowTransport 延迟发布应答的传输层，模拟等待 broker 确认
+type slowTransport struct {
+	*transport.MemoryTransport
+	delay time.Duration
+}
+
+func (t *slowTransport) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	return t.PublishMessage(&transport.Message{Topic: topic, QoS: qos, Retained: retained, Payload: payload})
+}
+
+func (t *slowTransport) PublishMessage(msg *transport.Message) error {
+	if strings.HasSuffix(msg.Topic, "/reply") {
+		time.Sleep(t.delay)
+	}
+	return t.MemoryTransport.PublishMessage(msg)
+}
+
+func TestAfterReplyWaitsForReplyPublish(t *testing.T) {
+	broker := transport.NewMemoryBroker()
+	engine := transport.NewMemoryTransport(broker)
+	if err := engine.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Disconnect()
+	replies := make(chan string, 4)
+	engine.Subscribe(protocol.DefaultTopicScheme().Reply("node", "node-1"), 1, func(msg *transport.Message) {
+		env, _, err := protocol.Decode(msg.Payload)
+		if err != nil {
+			t.Errorf("decode reply: %v", err)
+			return
+		}
+		replies <- env.CorrelationID
+	})
+
+	// 与节点的 stop 命令相同，回调中停止接收器并断开连接
+	r := NewCommandReceiverWithTransport("node", "node-1", &slowTransport{transport.NewMemoryTransport(broker), 100 * time.Millisecond})
+	stopped := make(chan struct{})
+	r.RegisterHandler("stop", NewCustomHandler("stop", func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+		if !AfterReply(ctx, func() { r.Stop(); close(stopped) }) {
+			t.Error("AfterReply rejected a synchronous command")
+		}
+		return NewSuccessResult(cmd.RequestID, "ok", nil), nil
+	}))
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	engine.Publish(protocol.DefaultTopicScheme().InstanceControl("node", "node-1"), 1, false,
+		[]byte(`{"command":"stop","request_id":"s1","scope":"instance"}`))
+	select {
+	case <-stopped:
+	case <-time.After(testTimeout):
+		t.Fatal("hook did not run")
+	}
+	select {
+	case id := <-replies:
+		if id != "s1" {
+			t.Fatalf("reply for %q, want s1", id)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("reply lost when the hook stopped the receiver")
+	}
+
+	if AfterReply(context.Background(), func() {}) {
+		t.Fatal("AfterReply accepted a context without a command")
+	}
+}
+
+func TestGetStatusDuringStartStop(t *testing.T) {
+	r := NewCommandReceiverWithTransport("node", "node-1", transport.NewMemoryTransport(transport.NewMemoryBroker()))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			if err := r.Start(context.Background()); err != nil {
+				t.Error(err)
+				return
+			}
+			r.Stop()
+		}
+	}()
+
+	// 与 Start/Stop 并发读取状态，由 go test -race 检查
+	for {
+		switch status := r.GetStatus(); status {
+		case ReceiverStatusRunning, ReceiverStatusStopped:
+		default:
+			t.Fatalf("unexpected status %q", status)
+		}
+		select {
+		case <-done:
+			if status := r.GetStatus(); status != ReceiverStatusStopped {
+				t.Fatalf("status after Stop = %q", status)
+			}
+			return
+		default:
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 )
 
 // StopHandler 停止命令处理器
@@ -53,6 +54,21 @@ func (h *StopHandler) GetCommandName() string {
 	return "stop"
 }
 
+// StatusInfo 状态查询结果数据
+type StatusInfo struct {
+	Status NodeStatus `json:"status"`
+}
+
+// NodeInfo 节点信息查询结果数据
+type NodeInfo struct {
+	NodeName  string     `json:"node_name"`
+	Version   string     `json:"version"`
+	Status    NodeStatus `json:"status"`
+	PID       int        `json:"pid"`
+	Uptime    int64      `json:"uptime"`
+	StartTime string     `json:"start_time"`
+}
+
 // StatusHandler 状态查询命令处理器
 type StatusHandler struct{}
 
@@ -69,11 +85,7 @@ func (h *StatusHandler) Handle(ctx context.Context, cmd *Command) (*CommandResul
 		status = nodeCtx.GetStatus()
 	}
 
-	return &CommandResult{
-		Success:   true,
-		Message:   string(status),
-		RequestID: cmd.RequestID,
-	}, nil
+	return NewSuccessResult(cmd.RequestID, string(status), &StatusInfo{Status: status}), nil
 }
 
 // GetCommandName 获取命令名称
@@ -124,18 +136,10 @@ func NewQueryHandler() *QueryHandler {
 func (h *QueryHandler) Handle(ctx context.Context, cmd *Command) (*CommandResult, error) {
 	nodeCtx := GetNodeContextFromContext(ctx)
 	if nodeCtx == nil {
-		return &CommandResult{
-			Success:   false,
-			Message:   "Node context not available",
-			RequestID: cmd.RequestID,
-		}, nil
+		return NewErrorResult(cmd.RequestID, ErrCodeInternal, "Node context not available"), nil
 	}
 
-	return &CommandResult{
-		Success:   true,
-		Message:   formatNodeInfo(nodeCtx),
-		RequestID: cmd.RequestID,
-	}, nil
+	return NewSuccessResult(cmd.RequestID, formatNodeInfo(nodeCtx), newNodeInfo(nodeCtx)), nil
 }
 
 // GetCommandName 获取命令名称
@@ -143,7 +147,20 @@ func (h *QueryHandler) GetCommandName() string {
 	return "query"
 }
 
+// newNodeInfo 构建节点信息
+func newNodeInfo(nodeCtx *NodeContext) *NodeInfo {
+	return &NodeInfo{
+		NodeName:  nodeCtx.GetNodeName(),
+		Version:   nodeCtx.GetVersion(),
+		Status:    nodeCtx.GetStatus(),
+		PID:       os.Getpid(),
+		Uptime:    nodeCtx.GetUptime(),
+		StartTime: nodeCtx.GetStartTime().Format(time.RFC3339),
+	}
+}
+
 // formatNodeInfo 格式化节点信息
+// 保留 key=value 格式的消息以兼容旧版引擎，结构化数据见 NodeInfo
 func formatNodeInfo(nodeCtx *NodeContext) string {
 	return fmt.Sprintf("node_name=%s,version=%s,status=%s,pid=%d,uptime=%d",
 		nodeCtx.GetNodeName(),
@@ -177,4 +194,3 @@ func (h *CustomHandler) Handle(ctx context.Context, cmd *Command) (*CommandResul
 func (h *CustomHandler) GetCommandName() string {
 	return h.name
 }
-
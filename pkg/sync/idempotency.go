@@ -0,0 +1,254 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/sync/idempotency.go
+ * 命令幂等 - 按 RequestID 抑制 MQTT 重复投递导致的重复执行
+ *
+ * 接收器使用 QoS 1 和持久会话，broker 可能重复投递同一条命令。
+ * 幂等缓存记录最近处理过的 RequestID：
+ * - 命令已执行完成：直接重发缓存的应答，不再调用处理器
+ * - 命令仍在执行（或进程在执行中退出）：忽略重复投递
+ *
+ * 缓存按容量和时间窗口淘汰，可选持久化到磁盘，使 stop/restart
+ * 等会导致进程退出的命令在重启后也不会被重复执行。
+ * 持久化在后台合并写入，不阻塞消息回调；接收器停止时同步写入剩余的修改。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package sync
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	gosync "sync"
+	"time"
+)
+
+// IdempotencyConfig 命令幂等配置
+type IdempotencyConfig struct {
+	// Capacity 最多记录的 RequestID 数量，超出后淘汰最早的记录
+	Capacity int
+	// Window 记录的保留时长
+	Window time.Duration
+	// PersistPath 持久化文件路径，为空时仅保存在内存中
+	PersistPath string
+}
+
+// DefaultIdempotencyConfig 返回默认幂等配置
+func DefaultIdempotencyConfig() *IdempotencyConfig {
+	return &IdempotencyConfig{
+		Capacity: 1024,
+		Window:   10 * time.Minute,
+	}
+}
+
+// idempotencyPersistDelay 记录变化后延迟写入持久化文件的时间，期间的修改合并为一次写入
+const idempotencyPersistDelay = 200 * time.Millisecond
+
+// idempotencyEntry 幂等记录，Reply 为空表示命令尚未执行完成
+type idempotencyEntry struct {
+	RequestID string        `json:"request_id"`
+	SeenAt    time.Time     `json:"seen_at"`
+	Reply     *CommandReply `json:"reply,omitempty"`
+}
+
+// idempotencyCache 有界、带时间窗口的 RequestID 缓存
+type idempotencyCache struct {
+	config  IdempotencyConfig
+	entries map[string]*list.Element
+	order   *list.List // 按首次出现时间排序，队首最早
+	mu      gosync.Mutex
+
+	persistTimer *time.Timer  // 等待中的延迟写入，为nil表示没有未写入的修改
+	persistMu    gosync.Mutex // 串行化文件写入，保证后写入的是较新的快照
+}
+
+// newIdempotencyCache 创建幂等缓存，如配置了持久化路径则加载已有记录
+func newIdempotencyCache(config *IdempotencyConfig) (*idempotencyCache, error) {
+	c := &idempotencyCache{
+		config:  *config,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+	if c.config.Capacity <= 0 {
+		c.config.Capacity = DefaultIdempotencyConfig().Capacity
+	}
+	if c.config.Window <= 0 {
+		c.config.Window = DefaultIdempotencyConfig().Window
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// begin 登记即将执行的命令
+// 如果 RequestID 已存在则返回 duplicate=true，以及缓存的应答（命令未完成时为nil）
+func (c *idempotencyCache) begin(requestID string) (cached *CommandReply, duplicate bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evictExpired(time.Now())
+	if elem, ok := c.entries[requestID]; ok {
+		return elem.Value.(*idempotencyEntry).Reply, true
+	}
+
+	c.entries[requestID] = c.order.PushBack(&idempotencyEntry{
+		RequestID: requestID,
+		SeenAt:    time.Now(),
+	})
+	for c.order.Len() > c.config.Capacity {
+		c.remove(c.order.Front())
+	}
+	c.schedulePersist()
+	return nil, false
+}
+
+// lookup 查询 RequestID 的记录，found=true 时返回缓存的应答（命令未完成时为nil）
+func (c *idempotencyCache) lookup(requestID string) (cached *CommandReply, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[requestID]; ok {
+		return elem.Value.(*idempotencyEntry).Reply, true
+	}
+	return nil, false
+}
+
+// complete 记录命令的应答
+func (c *idempotencyCache) complete(requestID string, reply *CommandReply) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[requestID]; ok {
+		elem.Value.(*idempotencyEntry).Reply = reply
+		c.schedulePersist()
+	}
+}
+
+// forget 删除尚未完成的记录，使引擎可以使用相同的 RequestID 重试
+func (c *idempotencyCache) forget(requestID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[requestID]; ok {
+		c.remove(elem)
+		c.schedulePersist()
+	}
+}
+
+// evictExpired 淘汰超出时间窗口的记录
+func (c *idempotencyCache) evictExpired(now time.Time) {
+	for elem := c.order.Front(); elem != nil; elem = c.order.Front() {
+		if now.Sub(elem.Value.(*idempotencyEntry).SeenAt) <= c.config.Window {
+			return
+		}
+		c.remove(elem)
+	}
+}
+
+// remove 删除记录
+func (c *idempotencyCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*idempotencyEntry).RequestID)
+}
+
+// load 从持久化文件加载记录
+func (c *idempotencyCache) load() error {
+	if c.config.PersistPath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.config.PersistPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read idempotency store: %w", err)
+	}
+
+	var entries []*idempotencyEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("parse idempotency store: %w", err)
+	}
+	for _, entry := range entries {
+		if _, ok := c.entries[entry.RequestID]; ok {
+			continue
+		}
+		c.entries[entry.RequestID] = c.order.PushBack(entry)
+	}
+	c.evictExpired(time.Now())
+	for c.order.Len() > c.config.Capacity {
+		c.remove(c.order.Front())
+	}
+	return nil
+}
+
+// schedulePersist 安排延迟写入持久化文件，调用方需持有 c.mu
+func (c *idempotencyCache) schedulePersist() {
+	if c.config.PersistPath == "" || c.persistTimer != nil {
+		return
+	}
+	c.persistTimer = time.AfterFunc(idempotencyPersistDelay, c.flush)
+}
+
+// flush 立即写入未写入的修改，用于接收器停止（如执行 stop 命令退出进程）前
+func (c *idempotencyCache) flush() {
+	c.persistMu.Lock()
+	defer c.persistMu.Unlock()
+
+	c.mu.Lock()
+	if c.persistTimer == nil {
+		c.mu.Unlock()
+		return
+	}
+	c.persistTimer.Stop()
+	c.persistTimer = nil
+	data, err := c.snapshot()
+	c.mu.Unlock()
+	if err != nil {
+		return
+	}
+	c.persist(data)
+}
+
+// snapshot 序列化当前记录，调用方需持有 c.mu
+func (c *idempotencyCache) snapshot() ([]byte, error) {
+	entries := make([]*idempotencyEntry, 0, c.order.Len())
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		entries = append(entries, elem.Value.(*idempotencyEntry))
+	}
+	return json.Marshal(entries)
+}
+
+// persist 将记录写入持久化文件
+// 写入失败只影响重启后的去重效果，不影响命令执行
+func (c *idempotencyCache) persist(data []byte) {
+	_ = writeFileAtomic(c.config.PersistPath, data)
+}
+
+// writeFileAtomic 先写临时文件再重命名，保证文件内容完整
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil {
+		_ = os.Remove(tmp.Name())
+		if writeErr != nil {
+			return writeErr
+		}
+		return closeErr
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
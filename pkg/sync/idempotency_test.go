@@ -0,0 +1,245 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/HY-805/SubNodeSync/pkg/transport"
+)
+
+func newTestIdempotencyCache(t *testing.T, config IdempotencyConfig) *idempotencyCache {
+	t.Helper()
+	c, err := newIdempotencyCache(&config)
+	if err != nil {
+		t.Fatalf("new idempotency cache: %v", err)
+	}
+	return c
+}
+
+func TestIdempotencyBeginCompleteForget(t *testing.T) {
+	c := newTestIdempotencyCache(t, IdempotencyConfig{})
+
+	if _, dup := c.begin("r1"); dup {
+		t.Fatal("first begin reported duplicate")
+	}
+	if cached, dup := c.begin("r1"); !dup || cached != nil {
+		t.Fatalf("pending begin = %v, %v; want duplicate without reply", cached, dup)
+	}
+
+	reply := &CommandReply{RequestID: "r1", Success: true}
+	c.complete("r1", reply)
+	if cached, dup := c.begin("r1"); !dup || cached != reply {
+		t.Fatalf("completed begin = %v, %v; want cached reply", cached, dup)
+	}
+
+	c.begin("r2")
+	c.forget("r2")
+	if _, found := c.lookup("r2"); found {
+		t.Fatal("forgotten request still recorded")
+	}
+}
+
+func TestIdempotencyEvictsByCapacityAndWindow(t *testing.T) {
+	c := newTestIdempotencyCache(t, IdempotencyConfig{Capacity: 2})
+	for _, id := range []string{"r1", "r2", "r3"} {
+		c.begin(id)
+	}
+	if _, found := c.lookup("r1"); found {
+		t.Fatal("oldest entry not evicted at capacity")
+	}
+
+	c = newTestIdempotencyCache(t, IdempotencyConfig{Window: 50 * time.Millisecond})
+	c.begin("r1")
+	time.Sleep(100 * time.Millisecond)
+	if _, dup := c.begin("r1"); dup {
+		t.Fatal("entry outside window reported duplicate")
+	}
+}
+
+func TestIdempotencyPersistsAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.json")
+	c := newTestIdempotencyCache(t, IdempotencyConfig{PersistPath: path})
+
+	c.begin("done")
+	c.complete("done", &CommandReply{RequestID: "done", Command: "stop", Success: true})
+	c.begin("running")
+
+	// 写入在后台延迟合并执行，不在调用方中同步完成
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("store written synchronously: %v", err)
+	}
+	c.flush()
+
+	restarted := newTestIdempotencyCache(t, IdempotencyConfig{PersistPath: path})
+	if cached, dup := restarted.begin("done"); !dup || cached == nil || cached.Command != "stop" {
+		t.Fatalf("completed entry after restart = %+v, %v", cached, dup)
+	}
+	if cached, dup := restarted.begin("running"); !dup || cached != nil {
+		t.Fatalf("pending entry after restart = %+v, %v", cached, dup)
+	}
+}
+
+func TestIdempotencyPersistIsDebounced(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.json")
+	c := newTestIdempotencyCache(t, IdempotencyConfig{PersistPath: path})
+
+	for _, id := range []string{"r1", "r2", "r3"} {
+		c.begin(id)
+	}
+	deadline := time.Now().Add(testTimeout)
+	for {
+		if data, err := os.ReadFile(path); err == nil {
+			var entries []*idempotencyEntry
+			if err := json.Unmarshal(data, &entries); err != nil {
+				t.Fatalf("parse store: %v", err)
+			}
+			if len(entries) != 3 {
+				t.Fatalf("store has %d entries, want 3", len(entries))
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("store not written after persist delay")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	c.mu.Lock()
+	pending := c.persistTimer != nil
+	c.mu.Unlock()
+	if pending {
+		t.Fatal("persist timer still pending after write")
+	}
+	entries, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp-*"))
+	if len(entries) != 0 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestIdempotencyRejectsCorruptStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.json")
+	os.WriteFile(path, []byte("{not json"), 0644)
+	if _, err := newIdempotencyCache(&IdempotencyConfig{PersistPath: path}); err == nil {
+		t.Fatal("corrupt store loaded")
+	}
+}
+
+func TestReceiverStopFlushesIdempotency(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.json")
+	var calls atomic.Int32
+	newReceiver := func() *CommandReceiver {
+		r := NewCommandReceiverWithTransport("node", "node-1", transport.NewMemoryTransport(transport.NewMemoryBroker()))
+		if err := r.SetIdempotency(&IdempotencyConfig{PersistPath: path}); err != nil {
+			t.Fatal(err)
+		}
+		r.RegisterHandler("stop", echoHandler("stop", &calls))
+		if err := r.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	replies := make(chan *CommandReply, 2)
+	r := newReceiver()
+	r.Submit("test", &Command{Command: "stop", RequestID: "s1"}, func(reply *CommandReply) { replies <- reply })
+	select {
+	case <-replies:
+	case <-time.After(testTimeout):
+		t.Fatal("no reply received")
+	}
+	r.Stop()
+
+	// 重启后重复投递的 stop 命令不再执行，重发缓存的应答
+	r = newReceiver()
+	defer r.Stop()
+	r.Submit("test", &Command{Command: "stop", RequestID: "s1"}, func(reply *CommandReply) { replies <- reply })
+	select {
+	case reply := <-replies:
+		if !reply.Success || reply.RequestID != "s1" {
+			t.Fatalf("replayed reply = %+v", reply)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("cached reply not delivered to the new submission")
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("stop handler called %d times, want 1", calls.Load())
+	}
+}
+
+func TestReceiverStopWaitsForRunningCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.json")
+	started := make(chan string, 1)
+	release := make(chan struct{})
+	r := NewCommandReceiverWithTransport("node", "node-1", transport.NewMemoryTransport(transport.NewMemoryBroker()))
+	if err := r.SetIdempotency(&IdempotencyConfig{PersistPath: path}); err != nil {
+		t.Fatal(err)
+	}
+	r.RegisterHandler("slow", blockingHandler("slow", started, release))
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	r.Submit("test", &Command{Command: "slow", RequestID: "s1"}, func(*CommandReply) {})
+	waitStarted(t, started, "s1")
+
+	stopped := make(chan struct{})
+	go func() {
+		r.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned while a command was still running")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(testTimeout):
+		t.Fatal("Stop did not return after the command finished")
+	}
+
+	// 停止时落盘的记录包含执行中命令的应答
+	c := newTestIdempotencyCache(t, IdempotencyConfig{PersistPath: path})
+	if reply, ok := c.begin("s1"); !ok || reply == nil || !reply.Success {
+		t.Fatalf("persisted entry = %+v, %v; want completed reply", reply, ok)
+	}
+}
+
+func TestProcessSuppressesDuplicates(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.RegisterHandler("echo", echoHandler("echo", &calls))
+		r.RegisterHandler("slow", blockingHandler("slow", started, release))
+	})
+
+	first := h.expectCode(&Command{Command: "echo", RequestID: "r1"}, "")
+	h.submit(&Command{Command: "echo", RequestID: "r1"})
+	if cached := h.reply(); !sameReply(cached, first) {
+		t.Fatalf("duplicate reply = %+v, want cached %+v", cached, first)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("handler called %d times, want 1", calls.Load())
+	}
+
+	h.submit(&Command{Command: "slow", RequestID: "r2"})
+	waitStarted(t, started, "r2")
+	h.submit(&Command{Command: "slow", RequestID: "r2"})
+	h.noReply()
+	close(release)
+	h.expectReply("r2", "")
+	h.noReply()
+
+	// 没有 RequestID 的命令不去重
+	h.expectCode(&Command{Command: "echo"}, "")
+	h.expectCode(&Command{Command: "echo"}, "")
+	if calls.Load() != 3 {
+		t.Fatalf("handler called %d times, want 3", calls.Load())
+	}
+}
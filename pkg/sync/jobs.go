@@ -0,0 +1,312 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/sync/jobs.go
+ * 异步命令 - 长时间运行命令的进度上报、取消和查询
+ *
+ * 以 WithAsync 注册的命令在工作池中执行，生命周期事件通过应答主题发布：
+ *   accepted -> progress(n%) ... -> completed / failed / canceled
+ *
+ * 内置命令：
+ *   cancel  参数 request_id，取消对应的异步命令
+ *   jobs    返回正在执行的异步命令列表
+ * 内置命令直接在消息回调中执行，工作池被长任务占满时仍可取消任务。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package sync
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	gosync "sync"
+	"time"
+)
+
+// JobEvent 异步命令事件类型
+type JobEvent string
+
+const (
+	JobAccepted  JobEvent = "accepted"  // 已接收，开始执行
+	JobProgress  JobEvent = "progress"  // 进度更新
+	JobCompleted JobEvent = "completed" // 执行成功
+	JobFailed    JobEvent = "failed"    // 执行失败
+	JobCanceled  JobEvent = "canceled"  // 已取消
+)
+
+// ProgressReporter 异步命令进度上报接口
+type ProgressReporter interface {
+	Report(percent int, message string)
+}
+
+// JobInfo 异步命令信息
+type JobInfo struct {
+	RequestID string `json:"request_id"`
+	Command   string `json:"command"`
+	StartedAt string `json:"started_at"`
+	Progress  int    `json:"progress"`
+	Message   string `json:"message,omitempty"`
+}
+
+// progressKey 用于在context中存储ProgressReporter
+type progressKey struct{}
+
+// WithProgressReporter 将进度上报器存储到context中
+func WithProgressReporter(ctx context.Context, reporter ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressKey{}, reporter)
+}
+
+// GetProgressReporterFromContext 从context中获取进度上报器
+// 同步执行的命令没有进度上报器，返回一个忽略所有上报的实现
+func GetProgressReporterFromContext(ctx context.Context) ProgressReporter {
+	if v := ctx.Value(progressKey{}); v != nil {
+		return v.(ProgressReporter)
+	}
+	return nopReporter{}
+}
+
+// ReportProgress 上报当前命令的执行进度（0-100）
+func ReportProgress(ctx context.Context, percent int, message string) {
+	GetProgressReporterFromContext(ctx).Report(percent, message)
+}
+
+// nopReporter 忽略所有进度上报
+type nopReporter struct{}
+
+func (nopReporter) Report(int, string) {}
+
+// job 正在执行的异步命令
+type job struct {
+	info     JobInfo
+	cancel   context.CancelFunc
+	canceled bool
+	mu       gosync.Mutex
+}
+
+// snapshot 返回任务信息副本
+func (j *job) snapshot() JobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.info
+}
+
+// jobRegistry 异步命令注册表
+type jobRegistry struct {
+	jobs map[string]*job
+	mu   gosync.Mutex
+}
+
+// newJobRegistry 创建异步命令注册表
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{jobs: make(map[string]*job)}
+}
+
+// add 登记异步命令
+// 任务以 RequestID 为键，关闭幂等检查时重复的 RequestID 不能覆盖正在执行的任务，返回 ok=false
+func (reg *jobRegistry) add(cmd *Command, cancel context.CancelFunc) (j *job, ok bool) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if _, exists := reg.jobs[cmd.RequestID]; exists {
+		return nil, false
+	}
+	j = &job{
+		info: JobInfo{
+			RequestID: cmd.RequestID,
+			Command:   cmd.Command,
+			StartedAt: time.Now().Format(time.RFC3339),
+		},
+		cancel: cancel,
+	}
+	reg.jobs[cmd.RequestID] = j
+	return j, true
+}
+
+// remove 移除已结束的异步命令
+func (reg *jobRegistry) remove(requestID string) {
+	reg.mu.Lock()
+	delete(reg.jobs, requestID)
+	reg.mu.Unlock()
+}
+
+// cancel 取消指定的异步命令，不存在时返回false
+func (reg *jobRegistry) cancel(requestID string) bool {
+	reg.mu.Lock()
+	j, ok := reg.jobs[requestID]
+	reg.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	j.mu.Lock()
+	j.canceled = true
+	j.mu.Unlock()
+	j.cancel()
+	return true
+}
+
+// cancelAll 取消所有异步命令
+func (reg *jobRegistry) cancelAll() {
+	reg.mu.Lock()
+	ids := make([]string, 0, len(reg.jobs))
+	for id := range reg.jobs {
+		ids = append(ids, id)
+	}
+	reg.mu.Unlock()
+
+	for _, id := range ids {
+		reg.cancel(id)
+	}
+}
+
+// list 返回正在执行的异步命令，按开始时间排序
+func (reg *jobRegistry) list() []JobInfo {
+	reg.mu.Lock()
+	infos := make([]JobInfo, 0, len(reg.jobs))
+	for _, j := range reg.jobs {
+		infos = append(infos, j.snapshot())
+	}
+	reg.mu.Unlock()
+
+	sort.Slice(infos, func(i, k int) bool {
+		return infos[i].StartedAt < infos[k].StartedAt
+	})
+	return infos
+}
+
+// jobReporter 将进度发布为 progress 事件
+type jobReporter struct {
+	receiver   *CommandReceiver
+	job        *job
+	cmd        *Command
+	receivedAt time.Time
+}
+
+// Report 更新任务进度并发布 progress 事件
+func (p *jobReporter) Report(percent int, message string) {
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+
+	p.job.mu.Lock()
+	p.job.info.Progress = percent
+	p.job.info.Message = message
+	p.job.mu.Unlock()
+
+	reply := newCommandReply(p.receiver.nodeName, p.receiver.instanceID, p.cmd,
+		&CommandResult{Success: true, Message: message}, nil, p.receivedAt)
+	reply.Event = JobProgress
+	reply.Progress = percent
+	p.receiver.publishReply(reply)
+}
+
+// asyncTask 登记异步命令并返回在工作池中执行的任务
+// 任务提交失败时需调用 abort 注销登记；同一 RequestID 的任务正在执行时返回错误
+func (r *CommandReceiver) asyncTask(parent context.Context, handler CommandHandler, cmd *Command, receivedAt time.Time) (task func(), abort func(), err error) {
+	if cmd.RequestID == "" {
+		// 异步命令依赖 RequestID 关联事件和取消操作
+		cmd.RequestID = fmt.Sprintf("%s-%d", cmd.Command, receivedAt.UnixNano())
+	}
+
+	ctx, cancel := context.WithCancel(WithNodeContext(parent, r.nodeCtx))
+	j, ok := r.jobs.add(cmd, cancel)
+	if !ok {
+		cancel()
+		return nil, nil, fmt.Errorf("job %s is already running", cmd.RequestID)
+	}
+	ctx = WithProgressReporter(ctx, &jobReporter{receiver: r, job: j, cmd: cmd, receivedAt: receivedAt})
+
+	abort = func() {
+		cancel()
+		r.jobs.remove(cmd.RequestID)
+	}
+
+	task = func() {
+		defer abort()
+
+		accepted := newCommandReply(r.nodeName, r.instanceID, cmd,
+			&CommandResult{Success: true, Message: "accepted"}, nil, receivedAt)
+		accepted.Event = JobAccepted
+		r.publishReply(accepted)
+
+		// 排队期间已被取消的任务不再执行处理器
+		var result *CommandResult
+		err := ctx.Err()
+		if err == nil {
+			result, err = handler.Handle(ctx, cmd)
+		}
+
+		j.mu.Lock()
+		canceled := j.canceled
+		j.mu.Unlock()
+
+		reply := newCommandReply(r.nodeName, r.instanceID, cmd, result, err, receivedAt)
+		switch {
+		case canceled:
+			reply.Event = JobCanceled
+			reply.Success = false
+			reply.Code = ErrCodeCanceled
+		case reply.Success:
+			reply.Event = JobCompleted
+			reply.Progress = 100
+		default:
+			reply.Event = JobFailed
+		}
+		log.Printf("[%s] 异步命令 %s(%s) 结束: %s", r.instanceID, cmd.Command, cmd.RequestID, reply.Event)
+
+		if r.idempotency != nil {
+			r.idempotency.complete(cmd.RequestID, reply)
+		}
+		r.publishReply(reply)
+	}
+	return task, abort, nil
+}
+
+// registerBuiltinHandlers 注册接收器内置的 cancel、jobs 和 help 命令
+func (r *CommandReceiver) registerBuiltinHandlers() {
+	r.RegisterHandler("cancel", NewCustomHandler("cancel", func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+		requestID, _ := cmd.Parameters["request_id"].(string)
+		if requestID == "" {
+			return NewErrorResult(cmd.RequestID, ErrCodeInvalidParams, "parameter request_id is required"), nil
+		}
+		if !r.jobs.cancel(requestID) {
+			return NewErrorResult(cmd.RequestID, ErrCodeNotFound, "no running job with request_id "+requestID), nil
+		}
+		return NewSuccessResult(cmd.RequestID, "cancel requested", nil), nil
+	}), withInline(), WithDescription("取消正在执行的异步命令"), WithParamSchema(&JSONSchema{
+		Type: "object",
+		Properties: map[string]*JSONSchema{
+			"request_id": {Type: "string", Description: "异步命令的 RequestID"},
+		},
+		Required: []string{"request_id"},
+	}))
+
+	r.RegisterHandler("jobs", NewCustomHandler("jobs", func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+		jobs := r.jobs.list()
+		return NewSuccessResult(cmd.RequestID, fmt.Sprintf("%d running jobs", len(jobs)), jobs), nil
+	}), withInline(), WithDescription("查询正在执行的异步命令"))
+
+	r.RegisterHandler("help", NewCustomHandler("help", func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+		name, _ := cmd.Parameters["command"].(string)
+		if name == "" {
+			infos := r.Handlers()
+			return NewSuccessResult(cmd.RequestID, fmt.Sprintf("%d commands", len(infos)), infos), nil
+		}
+		// 默认处理器不属于任何命令，不返回其元数据
+		entry, ok := r.lookupHandler(name)
+		if !ok || entry.name == "" {
+			return NewErrorResult(cmd.RequestID, ErrCodeNotFound, "unknown command "+name), nil
+		}
+		return NewSuccessResult(cmd.RequestID, "ok", entry.info()), nil
+	}), withInline(), WithDescription("列出支持的命令及其参数说明"), WithParamSchema(&JSONSchema{
+		Type: "object",
+		Properties: map[string]*JSONSchema{
+			"command": {Type: "string", Description: "只查询指定命令"},
+		},
+	}))
+}
@@ -0,0 +1,114 @@
+package sync
+
+import (
+	"context"
+	"testing"
+)
+
+// call 提交内置命令并等待应答，不经过 harness 的应答通道，避免与异步事件交错
+func (h *receiverHarness) call(cmd *Command) *CommandReply {
+	h.t.Helper()
+	replies := make(chan *CommandReply, 1)
+	h.r.Submit("test", cmd, func(reply *CommandReply) { replies <- reply })
+	select {
+	case reply := <-replies:
+		return reply
+	default:
+		h.t.Fatalf("no reply for built-in command %s", cmd.Command)
+		return nil
+	}
+}
+
+// expectEvent 等待下一条应答并检查异步命令事件
+func (h *receiverHarness) expectEvent(requestID string, event JobEvent) *CommandReply {
+	h.t.Helper()
+	reply := h.reply()
+	if reply.RequestID != requestID || reply.Event != event {
+		h.t.Fatalf("reply %s event=%q code=%q, want %s event %q", reply.RequestID, reply.Event, reply.Code, requestID, event)
+	}
+	return reply
+}
+
+func TestAsyncJobReportsProgress(t *testing.T) {
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.RegisterHandler("upgrade", NewCustomHandler("upgrade", func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+			ReportProgress(ctx, 50, "half")
+			ReportProgress(ctx, 150, "clamped")
+			return NewSuccessResult(cmd.RequestID, "upgraded", nil), nil
+		}), WithAsync())
+	})
+
+	h.submit(&Command{Command: "upgrade", RequestID: "j1"})
+	h.expectEvent("j1", JobAccepted)
+	if reply := h.expectEvent("j1", JobProgress); reply.Progress != 50 || reply.Message != "half" {
+		t.Fatalf("progress reply = %d %q", reply.Progress, reply.Message)
+	}
+	if reply := h.expectEvent("j1", JobProgress); reply.Progress != 100 {
+		t.Fatalf("progress = %d, want clamped to 100", reply.Progress)
+	}
+	if reply := h.expectEvent("j1", JobCompleted); !reply.Success || reply.Progress != 100 {
+		t.Fatalf("completed reply = %+v", reply)
+	}
+}
+
+func TestAsyncJobCancel(t *testing.T) {
+	started := make(chan string, 1)
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.RegisterHandler("sleep", NewCustomHandler("sleep", func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+			started <- cmd.RequestID
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}), WithAsync())
+	})
+
+	h.submit(&Command{Command: "sleep", RequestID: "j1"})
+	h.expectEvent("j1", JobAccepted)
+	waitStarted(t, started, "j1")
+
+	list := h.call(&Command{Command: "jobs", RequestID: "list"})
+	if jobs, ok := list.Data.([]JobInfo); !ok || len(jobs) != 1 || jobs[0].RequestID != "j1" || jobs[0].Command != "sleep" {
+		t.Fatalf("jobs = %#v", list.Data)
+	}
+
+	if reply := h.call(&Command{Command: "cancel", RequestID: "c1",
+		Parameters: map[string]interface{}{"request_id": "missing"}}); reply.Code != ErrCodeNotFound {
+		t.Fatalf("cancel unknown job code = %q, want not_found", reply.Code)
+	}
+	if reply := h.call(&Command{Command: "cancel", RequestID: "c2"}); reply.Code != ErrCodeInvalidParams {
+		t.Fatalf("cancel without request_id code = %q, want invalid_params", reply.Code)
+	}
+	if reply := h.call(&Command{Command: "cancel", RequestID: "c3",
+		Parameters: map[string]interface{}{"request_id": "j1"}}); !reply.Success {
+		t.Fatalf("cancel reply = %+v", reply)
+	}
+
+	if reply := h.expectEvent("j1", JobCanceled); reply.Success || reply.Code != ErrCodeCanceled {
+		t.Fatalf("canceled reply = %+v", reply)
+	}
+	if list := h.call(&Command{Command: "jobs", RequestID: "list-2"}); len(list.Data.([]JobInfo)) != 0 {
+		t.Fatalf("jobs after cancel = %#v", list.Data)
+	}
+}
+
+func TestAsyncJobRejectsRunningRequestID(t *testing.T) {
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		// 关闭幂等检查后，重复的 RequestID 由任务注册表拒绝
+		r.SetIdempotency(nil)
+		r.RegisterHandler("sleep", blockingHandler("sleep", started, release), WithAsync())
+	})
+
+	h.submit(&Command{Command: "sleep", RequestID: "j1"})
+	h.expectEvent("j1", JobAccepted)
+	waitStarted(t, started, "j1")
+
+	h.expectCode(&Command{Command: "sleep", RequestID: "j1"}, ErrCodeBusy)
+	if jobs := h.r.jobs.list(); len(jobs) != 1 {
+		t.Fatalf("jobs = %+v, want the first job only", jobs)
+	}
+
+	close(release)
+	h.expectEvent("j1", JobCompleted)
+	h.noReply()
+}
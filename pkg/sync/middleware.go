@@ -0,0 +1,198 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/sync/middleware.go
+ * 命令处理器中间件 - 日志、计时、panic恢复、超时等通用逻辑
+ *
+ * 中间件按注册顺序由外向内包裹处理器：先执行通过 Use 注册的全局中间件，
+ * 再执行通过 WithMiddleware 为单个命令注册的中间件。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package sync
+
+import (
+	"context"
+	"runtime/debug"
+	gosync "sync"
+	"time"
+
+	nodelog "github.com/HY-805/SubNodeSync/pkg/log"
+)
+
+// Middleware 命令处理器中间件
+type Middleware func(next CommandHandler) CommandHandler
+
+// Use 注册全局中间件，作用于所有命令
+// 应在 Start 之前调用
+func (r *CommandReceiver) Use(mw ...Middleware) {
+	r.middlewares = append(r.middlewares, mw...)
+}
+
+// WithMiddleware 为单个命令注册中间件，在全局中间件之后执行
+func WithMiddleware(mw ...Middleware) HandlerOption {
+	return func(e *handlerEntry) {
+		e.middlewares = append(e.middlewares, mw...)
+	}
+}
+
+// chain 将全局中间件和命令中间件应用到处理器
+func (r *CommandReceiver) chain(entry *handlerEntry) CommandHandler {
+	handler := entry.handler
+	for i := len(entry.middlewares) - 1; i >= 0; i-- {
+		handler = entry.middlewares[i](handler)
+	}
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
+	}
+	return handler
+}
+
+// RecoveryMiddleware 捕获处理器中的panic，转换为 internal 错误
+func RecoveryMiddleware() Middleware {
+	return func(next CommandHandler) CommandHandler {
+		return NewCustomHandler(next.GetCommandName(), func(ctx context.Context, cmd *Command) (result *CommandResult, err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					nodelog.Error("command handler panic",
+						nodelog.String("command", cmd.Command),
+						nodelog.String("request_id", cmd.RequestID),
+						nodelog.Any("panic", p),
+						nodelog.String("stack", string(debug.Stack())),
+					)
+					result = nil
+					err = NewCommandError(ErrCodeInternal, "handler panic: %v", p)
+				}
+			}()
+			return next.Handle(ctx, cmd)
+		})
+	}
+}
+
+// TimeoutMiddleware 限制处理器的执行时间
+// 超时后立即返回 timeout 错误，处理器应响应 ctx 取消尽快退出；
+// 处理器返回前命令的并发和串行槽位不会释放
+func TimeoutMiddleware(timeout time.Duration) Middleware {
+	return func(next CommandHandler) CommandHandler {
+		return NewCustomHandler(next.GetCommandName(), func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			type outcome struct {
+				result *CommandResult
+				err    error
+			}
+			done := make(chan outcome, 1)
+			unhold := holdExecution(ctx)
+			go func() {
+				defer unhold()
+				result, err := next.Handle(ctx, cmd)
+				done <- outcome{result, err}
+			}()
+
+			select {
+			case o := <-done:
+				return o.result, o.err
+			case <-ctx.Done():
+				if ctx.Err() == context.DeadlineExceeded {
+					return nil, NewCommandError(ErrCodeTimeout, "command %s timed out after %s", cmd.Command, timeout)
+				}
+				return nil, ctx.Err()
+			}
+		})
+	}
+}
+
+// LoggingMiddleware 通过 pkg/log 输出命令执行的结构化日志
+func LoggingMiddleware() Middleware {
+	return func(next CommandHandler) CommandHandler {
+		return NewCustomHandler(next.GetCommandName(), func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+			start := time.Now()
+			result, err := next.Handle(ctx, cmd)
+
+			fields := []nodelog.Field{
+				nodelog.String("command", cmd.Command),
+				nodelog.String("request_id", cmd.RequestID),
+				nodelog.Int64("duration_ms", time.Since(start).Milliseconds()),
+			}
+			switch {
+			case err != nil:
+				nodelog.Error("command failed", append(fields, nodelog.String("code", string(ErrorCodeOf(err))), nodelog.Err(err))...)
+			case result != nil && !result.Success:
+				nodelog.Warn("command unsuccessful", append(fields, nodelog.String("code", string(result.Code)), nodelog.String("message", result.Message))...)
+			default:
+				nodelog.Info("command succeeded", fields...)
+			}
+			return result, err
+		})
+	}
+}
+
+// CommandStats 单个命令的执行统计
+type CommandStats struct {
+	Count         int64   `json:"count"`
+	Failures      int64   `json:"failures"`
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`
+	MaxLatencyMs  int64   `json:"max_latency_ms"`
+	LastLatencyMs int64   `json:"last_latency_ms"`
+
+	totalLatency time.Duration
+}
+
+// CommandMetrics 按命令统计执行次数、失败次数和延迟
+type CommandMetrics struct {
+	stats map[string]*CommandStats
+	mu    gosync.Mutex
+}
+
+// NewCommandMetrics 创建命令统计
+func NewCommandMetrics() *CommandMetrics {
+	return &CommandMetrics{stats: make(map[string]*CommandStats)}
+}
+
+// Observe 记录一次命令执行
+func (m *CommandMetrics) Observe(command string, latency time.Duration, success bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.stats[command]
+	if !ok {
+		s = &CommandStats{}
+		m.stats[command] = s
+	}
+	s.Count++
+	if !success {
+		s.Failures++
+	}
+	s.totalLatency += latency
+	s.AvgLatencyMs = float64(s.totalLatency.Microseconds()) / 1000 / float64(s.Count)
+	s.LastLatencyMs = latency.Milliseconds()
+	if s.LastLatencyMs > s.MaxLatencyMs {
+		s.MaxLatencyMs = s.LastLatencyMs
+	}
+}
+
+// Snapshot 返回所有命令的统计副本
+func (m *CommandMetrics) Snapshot() map[string]CommandStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[string]CommandStats, len(m.stats))
+	for command, s := range m.stats {
+		snapshot[command] = *s
+	}
+	return snapshot
+}
+
+// MetricsMiddleware 将命令执行延迟记录到 metrics
+func MetricsMiddleware(metrics *CommandMetrics) Middleware {
+	return func(next CommandHandler) CommandHandler {
+		return NewCustomHandler(next.GetCommandName(), func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+			start := time.Now()
+			result, err := next.Handle(ctx, cmd)
+			metrics.Observe(cmd.Command, time.Since(start), err == nil && (result == nil || result.Success))
+			return result, err
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package sync
+
+import (
+	"context"
+	"strings"
+	gosync "sync"
+	"testing"
+	"time"
+)
+
+func TestTimeoutMiddlewareHoldsSlotUntilHandlerReturns(t *testing.T) {
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	returned := make(chan error, 2)
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.RegisterHandler("slow", NewCustomHandler("slow", func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+			started <- cmd.RequestID
+			<-ctx.Done()
+			// 超时后处理器继续运行一段时间才返回
+			<-release
+			returned <- ctx.Err()
+			return NewSuccessResult(cmd.RequestID, "late", nil), nil
+		}), WithConcurrency(ConcurrencyPolicy{Mode: ConcurrencySingleFlight}),
+			WithMiddleware(TimeoutMiddleware(50*time.Millisecond)))
+	})
+
+	reply := h.expectCode(&Command{Command: "slow", RequestID: "r1"}, ErrCodeTimeout)
+	if !strings.Contains(reply.Error, "timed out") {
+		t.Fatalf("timeout reply error = %q", reply.Error)
+	}
+	waitStarted(t, started, "r1")
+
+	// 超时的处理器仍在运行，single_flight 槽位没有释放
+	h.expectCode(&Command{Command: "slow", RequestID: "r2"}, ErrCodeBusy)
+
+	close(release)
+	select {
+	case err := <-returned:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("handler context error = %v, want deadline exceeded", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("handler did not observe cancellation")
+	}
+
+	// 处理器返回后槽位释放，新命令可以执行
+	deadline := time.Now().Add(testTimeout)
+	for {
+		h.submit(&Command{Command: "slow", RequestID: "r3"})
+		if reply := h.reply(); reply.Code != ErrCodeBusy {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("slot not released after handler returned")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitStarted(t, started, "r3")
+}
+
+func TestTimeoutMiddlewarePassesFastResult(t *testing.T) {
+	handler := TimeoutMiddleware(time.Second)(NewCustomHandler("fast", func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("handler context has no deadline")
+		}
+		return NewSuccessResult(cmd.RequestID, "ok", nil), nil
+	}))
+
+	result, err := handler.Handle(context.Background(), &Command{Command: "fast", RequestID: "r1"})
+	if err != nil || !result.Success {
+		t.Fatalf("result = %+v, %v", result, err)
+	}
+
+	// 调用方取消时返回取消错误而不是超时
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	blocked := TimeoutMiddleware(time.Second)(NewCustomHandler("blocked", func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}))
+	if _, err := blocked.Handle(ctx, &Command{Command: "blocked"}); err != context.Canceled {
+		t.Fatalf("canceled err = %v, want context.Canceled", err)
+	}
+}
+
+func TestMiddlewareOrderRecoveryAndMetrics(t *testing.T) {
+	var mu gosync.Mutex
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next CommandHandler) CommandHandler {
+			return NewCustomHandler(next.GetCommandName(), func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+				return next.Handle(ctx, cmd)
+			})
+		}
+	}
+	metrics := NewCommandMetrics()
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.Use(trace("global"), MetricsMiddleware(metrics), RecoveryMiddleware())
+		r.RegisterHandler("panic", NewCustomHandler("panic", func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+			panic("boom")
+		}), WithMiddleware(trace("command")))
+	})
+
+	reply := h.expectCode(&Command{Command: "panic", RequestID: "r1"}, ErrCodeInternal)
+	if !strings.Contains(reply.Error, "boom") {
+		t.Fatalf("panic reply error = %q", reply.Error)
+	}
+	mu.Lock()
+	got := strings.Join(order, ",")
+	mu.Unlock()
+	if got != "global,command" {
+		t.Fatalf("middleware order = %s, want global,command", got)
+	}
+	if stats := metrics.Snapshot()["panic"]; stats.Count != 1 || stats.Failures != 1 {
+		t.Fatalf("metrics = %+v", stats)
+	}
+}
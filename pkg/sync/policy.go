@@ -0,0 +1,207 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/sync/policy.go
+ * 命令授权策略 - 按调用方角色限制可执行的命令
+ *
+ * 策略文件格式（JSON）：
+ *
+ *	{
+ *	  "roles": {
+ *	    "viewer":   ["status", "query", "jobs"],
+ *	    "operator": ["status", "query", "jobs", "cache.*"],
+ *	    "admin":    ["*"]
+ *	  },
+ *	  "bindings": {
+ *	    "alice":  ["viewer"],
+ *	    "engine": ["admin"]
+ *	  },
+ *	  "default_roles": []
+ *	}
+ *
+ * 调用方身份取自命令的 Caller 字段（包含在签名原文中），校验器确认 Caller 绑定到
+ * 签名密钥（CommandVerifier.BindCallers）；未设置 Caller 的签名命令使用签名的 key_id 作为身份。
+ * 命令模式与处理器注册使用相同的语法：精确命令名、"ns.*" 命名空间通配符或 "*"。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	gosync "sync"
+	"time"
+)
+
+// DefaultPolicyWatchInterval 默认策略文件检查间隔
+const DefaultPolicyWatchInterval = 10 * time.Second
+
+// ErrAuthorizerWithoutVerifier 设置了授权器但未设置签名校验器
+// 未签名命令中的调用方可被任意伪造，按调用方授权没有意义
+var ErrAuthorizerWithoutVerifier = errors.New("command authorizer requires a command verifier")
+
+// AuthorizationPolicy 命令授权策略
+type AuthorizationPolicy struct {
+	Roles        map[string][]string `json:"roles"`                   // 角色 -> 允许的命令模式
+	Bindings     map[string][]string `json:"bindings"`                // 调用方 -> 角色
+	DefaultRoles []string            `json:"default_roles,omitempty"` // 未绑定调用方的角色
+}
+
+// LoadAuthorizationPolicy 从文件加载授权策略
+func LoadAuthorizationPolicy(filePath string) (*AuthorizationPolicy, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy AuthorizationPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("parse policy %s: %w", filePath, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", filePath, err)
+	}
+	return &policy, nil
+}
+
+// Validate 检查策略中的命令模式和角色引用是否有效
+func (p *AuthorizationPolicy) Validate() error {
+	for role, patterns := range p.Roles {
+		for _, pattern := range patterns {
+			if err := validateCommandPattern(pattern); err != nil {
+				return fmt.Errorf("role %q has invalid pattern: %w", role, err)
+			}
+		}
+	}
+	for caller, roles := range p.Bindings {
+		for _, role := range roles {
+			if _, ok := p.Roles[role]; !ok {
+				return fmt.Errorf("caller %q bound to unknown role %q", caller, role)
+			}
+		}
+	}
+	for _, role := range p.DefaultRoles {
+		if _, ok := p.Roles[role]; !ok {
+			return fmt.Errorf("unknown default role %q", role)
+		}
+	}
+	return nil
+}
+
+// Allows 判断调用方是否可以执行命令
+func (p *AuthorizationPolicy) Allows(caller, command string) bool {
+	roles, ok := p.Bindings[caller]
+	if !ok || caller == "" {
+		roles = p.DefaultRoles
+	}
+	for _, role := range roles {
+		for _, pattern := range p.Roles[role] {
+			if matchCommandPattern(pattern, command) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PolicyAuthorizer 基于策略文件的命令授权器，支持运行时重新加载
+type PolicyAuthorizer struct {
+	path    string
+	policy  *AuthorizationPolicy
+	modTime time.Time
+	mu      gosync.RWMutex
+}
+
+// NewPolicyAuthorizer 创建授权器并加载策略文件
+func NewPolicyAuthorizer(filePath string) (*PolicyAuthorizer, error) {
+	a := &PolicyAuthorizer{path: filePath}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Reload 重新加载策略文件，加载失败时保留原有策略
+func (a *PolicyAuthorizer) Reload() error {
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return err
+	}
+	policy, err := LoadAuthorizationPolicy(a.path)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.policy = policy
+	a.modTime = info.ModTime()
+	a.mu.Unlock()
+	return nil
+}
+
+// Watch 定期检查策略文件，文件变化时重新加载，ctx 取消后返回
+func (a *PolicyAuthorizer) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultPolicyWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(a.path)
+			if err != nil {
+				log.Printf("[SubNodeSync] 检查授权策略文件失败: %v", err)
+				continue
+			}
+			a.mu.RLock()
+			changed := !info.ModTime().Equal(a.modTime)
+			a.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := a.Reload(); err != nil {
+				log.Printf("[SubNodeSync] 重新加载授权策略失败: %v，继续使用原有策略", err)
+				continue
+			}
+			log.Printf("[SubNodeSync] 授权策略已重新加载: %s", a.path)
+		}
+	}
+}
+
+// Policy 返回当前生效的策略
+func (a *PolicyAuthorizer) Policy() *AuthorizationPolicy {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.policy
+}
+
+// Authorize 检查命令的调用方是否有权执行该命令
+func (a *PolicyAuthorizer) Authorize(cmd *Command) error {
+	caller := cmd.CallerIdentity()
+	if !a.Policy().Allows(caller, cmd.Command) {
+		return fmt.Errorf("caller %q is not allowed to run %q", caller, cmd.Command)
+	}
+	return nil
+}
+
+// CallerIdentity 返回命令调用方身份
+// 未设置 Caller 时使用签名的 key_id
+func (c *Command) CallerIdentity() string {
+	if c.Caller != "" {
+		return c.Caller
+	}
+	if c.Signature != nil {
+		return c.Signature.KeyID
+	}
+	return ""
+}
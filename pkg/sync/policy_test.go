@@ -0,0 +1,151 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/HY-805/SubNodeSync/pkg/transport"
+)
+
+func TestProcessAuthorizesCaller(t *testing.T) {
+	policyPath := filepath.Join(t.TempDir(), "policy.json")
+	policy := `{"roles": {"viewer": ["echo"], "admin": ["*"]}, "bindings": {"alice": ["viewer"], "engine": ["admin"]}}`
+	if err := os.WriteFile(policyPath, []byte(policy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	authorizer, err := NewPolicyAuthorizer(policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有签名校验时调用方可被伪造，拒绝启动
+	r := NewCommandReceiverWithTransport("node", "node-1", transport.NewMemoryTransport(transport.NewMemoryBroker()))
+	r.SetAuthorizer(authorizer)
+	if err := r.Start(context.Background()); err != ErrAuthorizerWithoutVerifier {
+		t.Fatalf("start error = %v, want %v", err, ErrAuthorizerWithoutVerifier)
+	}
+
+	var calls atomic.Int32
+	verifier := NewCommandVerifier()
+	verifier.AddHMACKey("alice-key", []byte("alice-secret"))
+	verifier.AddHMACKey("engine-key", []byte("engine-secret"))
+	verifier.BindCallers("alice-key", "alice")
+	verifier.BindCallers("engine-key", "engine")
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.SetVerifier(verifier)
+		r.SetAuthorizer(authorizer)
+		r.RegisterHandler("echo", echoHandler("echo", &calls))
+		r.RegisterHandler("purge", echoHandler("purge", &calls))
+	})
+	alice := NewHMACSigner("alice-key", []byte("alice-secret"))
+	engine := NewHMACSigner("engine-key", []byte("engine-secret"))
+
+	for i, tc := range []struct {
+		signer  *CommandSigner
+		caller  string
+		command string
+		code    ErrorCode
+	}{
+		{alice, "alice", "echo", ""},
+		{alice, "alice", "purge", ErrCodeUnauthorized},
+		{engine, "engine", "purge", ""},
+		// 调用方必须绑定到签名密钥
+		{alice, "engine", "purge", ErrCodeUnauthorized},
+		// 未声明调用方时使用 key_id，策略中没有绑定角色
+		{engine, "", "echo", ErrCodeUnauthorized},
+	} {
+		cmd := &Command{Command: tc.command, RequestID: fmt.Sprintf("r%d", i), Caller: tc.caller,
+			Scope: ScopeInstance, Target: "node-1"}
+		if err := tc.signer.Sign(cmd); err != nil {
+			t.Fatal(err)
+		}
+		h.expectCode(cmd, tc.code)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("handlers called %d times, want 2", calls.Load())
+	}
+}
+
+func TestPolicyPatternsMatchRouter(t *testing.T) {
+	policy := &AuthorizationPolicy{
+		Roles: map[string][]string{
+			"operator": {"status", "cache.*"},
+			"admin":    {"*"},
+		},
+		Bindings: map[string][]string{"alice": {"operator"}, "engine": {"admin"}},
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 授权结果与处理器查找一致：注册了 "cache.*" 时，operator 可以执行该处理器负责的所有命令
+	r := NewCommandReceiverWithInstanceID("node", "node-1", "")
+	r.RegisterHandler("status", namedHandler("status"))
+	r.RegisterHandler("cache.*", namedHandler("cache.*"))
+	for _, command := range []string{"status", "cache.flush", "cache.a.b", "cache", "cachex.flush", "status.detail", "cache/flush"} {
+		_, routed := r.lookupHandler(command)
+		if allowed := policy.Allows("alice", command); allowed != routed {
+			t.Errorf("alice %s: allowed=%v, routed=%v", command, allowed, routed)
+		}
+		if !policy.Allows("engine", command) {
+			t.Errorf("engine %s: denied by \"*\"", command)
+		}
+	}
+
+	// path.Match 风格的模式与处理器注册规则不一致，加载策略时拒绝
+	for _, pattern := range []string{"cache*", "*.flush", "cache.fl*", "cache.[a-z]*"} {
+		invalid := &AuthorizationPolicy{Roles: map[string][]string{"r": {pattern}}}
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("pattern %q accepted", pattern)
+		}
+	}
+}
+
+func TestSubmitUnsignedStillAuthorizes(t *testing.T) {
+	policyPath := filepath.Join(t.TempDir(), "policy.json")
+	policy := `{"roles": {"viewer": ["echo"]}, "bindings": {"sparkplug": ["viewer"]}}`
+	if err := os.WriteFile(policyPath, []byte(policy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	authorizer, err := NewPolicyAuthorizer(policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls atomic.Int32
+	replies := make(chan *CommandReply, 4)
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.SetVerifier(NewCommandVerifier())
+		r.SetAuthorizer(authorizer)
+		r.RegisterHandler("echo", echoHandler("echo", &calls))
+		r.RegisterHandler("purge", echoHandler("purge", &calls))
+	})
+	submit := func(command string) *CommandReply {
+		h.r.SubmitUnsigned("ncmd", &Command{Command: command, RequestID: command, Caller: "sparkplug"},
+			func(reply *CommandReply) { replies <- reply })
+		select {
+		case reply := <-replies:
+			return reply
+		case <-time.After(testTimeout):
+			t.Fatal("no reply received")
+			return nil
+		}
+	}
+
+	// 跳过签名校验，授权检查照常进行
+	if reply := submit("echo"); !reply.Success {
+		t.Fatalf("unsigned echo = %+v", reply)
+	}
+	if reply := submit("purge"); reply.Code != ErrCodeUnauthorized {
+		t.Fatalf("unsigned purge = %+v, want unauthorized", reply)
+	}
+	// Submit 仍然要求签名
+	h.expectCode(&Command{Command: "echo", RequestID: "signed-path", Caller: "sparkplug"}, ErrCodeUnauthorized)
+	if calls.Load() != 1 {
+		t.Fatalf("handlers called %d times, want 1", calls.Load())
+	}
+}
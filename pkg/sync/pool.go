@@ -0,0 +1,305 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/sync/pool.go
+ * 命令工作池 - 限制并发执行的命令数量和单个命令的并发策略
+ *
+ * MQTT消息回调只负责解析和校验命令，处理器在工作池中执行，
+ * 队列已满或命令并发已达上限时立即返回 busy 应答，不阻塞MQTT网络协程。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package sync
+
+import (
+	"context"
+	gosync "sync"
+	"time"
+)
+
+// ConcurrencyMode 单个命令的并发模式
+type ConcurrencyMode string
+
+const (
+	ConcurrencyParallel     ConcurrencyMode = "parallel"      // 并行执行，MaxParallel 大于0时限制并发数
+	ConcurrencySerial       ConcurrencyMode = "serial"        // 串行执行，后到的命令排队等待
+	ConcurrencySingleFlight ConcurrencyMode = "single_flight" // 同一时间只执行一个，正在执行时拒绝新命令
+)
+
+// ConcurrencyPolicy 命令并发策略
+type ConcurrencyPolicy struct {
+	Mode        ConcurrencyMode `json:"mode"`
+	MaxParallel int             `json:"max_parallel,omitempty"`
+}
+
+// WorkerPoolConfig 工作池配置
+type WorkerPoolConfig struct {
+	// Workers 并发执行命令的协程数
+	Workers int
+	// QueueSize 等待执行的命令队列长度
+	QueueSize int
+}
+
+// DefaultWorkerPoolConfig 返回默认工作池配置
+func DefaultWorkerPoolConfig() *WorkerPoolConfig {
+	return &WorkerPoolConfig{
+		Workers:   4,
+		QueueSize: 64,
+	}
+}
+
+// workerPool 固定协程数、有界队列的工作池
+type workerPool struct {
+	tasks   chan func()
+	stopped bool
+	mu      gosync.RWMutex
+	wg      gosync.WaitGroup
+}
+
+// newWorkerPool 创建并启动工作池
+func newWorkerPool(config *WorkerPoolConfig) *workerPool {
+	workers, queueSize := config.Workers, config.QueueSize
+	if workers <= 0 {
+		workers = DefaultWorkerPoolConfig().Workers
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &workerPool{tasks: make(chan func(), queueSize)}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for task := range p.tasks {
+				task()
+			}
+		}()
+	}
+	return p
+}
+
+// submit 提交任务，队列已满或工作池已停止时返回false
+func (p *workerPool) submit(task func()) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.stopped {
+		return false
+	}
+	select {
+	case p.tasks <- task:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop 停止接收新任务，已提交的任务继续执行
+func (p *workerPool) stop() {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+}
+
+// wait 等待工作协程执行完已提交的任务并退出，超过 timeout 仍未退出时返回false
+// 超时中间件返回后处理器可能仍在后台运行，也可能在工作协程中调用了 Stop，因此只做有界等待
+func (p *workerPool) wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// execution 一次命令执行占用的执行槽位
+// 超时中间件返回后处理器可能仍在后台运行，处理器通过 hold 推迟槽位的释放
+type execution struct {
+	mu      gosync.Mutex
+	holds   int
+	release func()
+}
+
+// executionKey 用于在context中存储execution
+type executionKey struct{}
+
+// withExecution 将执行记录存储到context中
+func withExecution(ctx context.Context, e *execution) context.Context {
+	return context.WithValue(ctx, executionKey{}, e)
+}
+
+// holdExecution 在处理器仍在运行期间保持命令的执行槽位，返回的函数在处理器返回时调用
+// context 中没有执行记录（如在消息回调中直接执行的命令）时返回空操作
+func holdExecution(ctx context.Context) (unhold func()) {
+	e, ok := ctx.Value(executionKey{}).(*execution)
+	if !ok {
+		return func() {}
+	}
+
+	e.mu.Lock()
+	e.holds++
+	e.mu.Unlock()
+
+	var once gosync.Once
+	return func() {
+		once.Do(func() {
+			e.mu.Lock()
+			e.holds--
+			var release func()
+			if e.holds == 0 {
+				release, e.release = e.release, nil
+			}
+			e.mu.Unlock()
+			if release != nil {
+				release()
+			}
+		})
+	}
+}
+
+// finish 在任务返回后调用，没有处理器仍在运行时返回true，由调用方立即释放槽位；
+// 否则返回false，最后一个处理器返回时调用 release
+func (e *execution) finish(release func()) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.holds == 0 {
+		return true
+	}
+	e.release = release
+	return false
+}
+
+// concurrencyLimiter 按并发策略限制单个命令的执行
+//
+// 并行和单飞模式在消息回调中占用执行槽位，槽位已满时立即拒绝；
+// 串行模式的命令在限制器中排队，前一个命令结束后才交给工作池，不占用等待中的工作协程。
+type concurrencyLimiter struct {
+	mode  ConcurrencyMode
+	slots chan struct{}
+
+	mu      gosync.Mutex
+	running bool        // 串行命令正在执行或已提交到工作池
+	waiting []serialJob // 等待执行的串行命令
+}
+
+// serialJob 等待执行的串行命令
+type serialJob struct {
+	exec *execution
+	task func()
+}
+
+// newConcurrencyLimiter 创建并发限制器，不限制并发时返回nil
+func newConcurrencyLimiter(policy ConcurrencyPolicy) *concurrencyLimiter {
+	switch policy.Mode {
+	case ConcurrencySerial:
+		return &concurrencyLimiter{mode: ConcurrencySerial}
+	case ConcurrencySingleFlight:
+		return &concurrencyLimiter{mode: policy.Mode, slots: make(chan struct{}, 1)}
+	default:
+		if policy.MaxParallel <= 0 {
+			return nil
+		}
+		return &concurrencyLimiter{mode: ConcurrencyParallel, slots: make(chan struct{}, policy.MaxParallel)}
+	}
+}
+
+// admit 在消息回调中判断命令能否进入工作池
+// 串行命令总是允许排队，其他模式占用一个执行槽位，槽位已满时返回false
+func (l *concurrencyLimiter) admit() bool {
+	if l == nil || l.mode == ConcurrencySerial {
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// withdraw 撤销 admit 占用的槽位，用于命令未能进入工作池的情况
+func (l *concurrencyLimiter) withdraw() {
+	if l != nil && l.mode != ConcurrencySerial {
+		<-l.slots
+	}
+}
+
+// release 释放执行槽位
+func (l *concurrencyLimiter) release() {
+	if l != nil && l.mode != ConcurrencySerial {
+		<-l.slots
+	}
+}
+
+// submit 将已通过 admit 的命令提交到工作池，任务返回且处理器都已返回后释放槽位
+// 串行命令有命令正在执行时在限制器中排队，排队数量受工作池队列长度限制；
+// 队列已满或工作池已停止时返回false
+func (l *concurrencyLimiter) submit(p *workerPool, exec *execution, task func()) bool {
+	if l == nil || l.mode != ConcurrencySerial {
+		return p.submit(func() {
+			task()
+			if exec.finish(l.release) {
+				l.release()
+			}
+		})
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	job := serialJob{exec: exec, task: task}
+	if l.running {
+		if len(l.waiting) >= cap(p.tasks) {
+			return false
+		}
+		l.waiting = append(l.waiting, job)
+		return true
+	}
+	if !p.submit(func() { l.runSerial(p, job) }) {
+		return false
+	}
+	l.running = true
+	return true
+}
+
+// runSerial 执行串行命令，命令结束后将下一个等待的命令交给工作池
+// 超时返回的命令在处理器实际返回后才开始下一个命令
+func (l *concurrencyLimiter) runSerial(p *workerPool, job serialJob) {
+	for job.task != nil {
+		job.task()
+		if !job.exec.finish(func() { l.runSerial(p, l.next(p)) }) {
+			return
+		}
+		job = l.next(p)
+	}
+}
+
+// next 取出下一个等待的串行命令并提交到工作池
+// 工作池队列已满或已停止时返回该命令，由当前协程继续执行，已接受的命令不会丢失
+func (l *concurrencyLimiter) next(p *workerPool) serialJob {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.waiting) == 0 {
+		l.running = false
+		return serialJob{}
+	}
+	job := l.waiting[0]
+	l.waiting[0] = serialJob{}
+	l.waiting = l.waiting[1:]
+	if p.submit(func() { l.runSerial(p, job) }) {
+		return serialJob{}
+	}
+	return job
+}
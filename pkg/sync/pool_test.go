@@ -0,0 +1,80 @@
+package sync
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestProcessRejectsBusySingleFlight(t *testing.T) {
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.RegisterHandler("deploy", blockingHandler("deploy", started, release),
+			WithConcurrency(ConcurrencyPolicy{Mode: ConcurrencySingleFlight}))
+	})
+
+	h.submit(&Command{Command: "deploy", RequestID: "d1"})
+	waitStarted(t, started, "d1")
+	h.expectCode(&Command{Command: "deploy", RequestID: "d2"}, ErrCodeBusy)
+
+	close(release)
+	h.expectReply("d1", "")
+
+	// 被拒绝的命令删除了幂等记录，可以使用相同的 RequestID 重试
+	h.submit(&Command{Command: "deploy", RequestID: "d2"})
+	waitStarted(t, started, "d2")
+	h.expectReply("d2", "")
+}
+
+func TestProcessRejectsWhenQueueFull(t *testing.T) {
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.SetWorkerPool(&WorkerPoolConfig{Workers: 1, QueueSize: 1})
+		r.RegisterHandler("slow", blockingHandler("slow", started, release))
+	})
+
+	h.submit(&Command{Command: "slow", RequestID: "s1"})
+	waitStarted(t, started, "s1")
+	h.submit(&Command{Command: "slow", RequestID: "s2"}) // 排队
+	h.expectCode(&Command{Command: "slow", RequestID: "s3"}, ErrCodeBusy)
+
+	close(release)
+	replies := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		replies[h.reply().RequestID] = true
+	}
+	if !replies["s1"] || !replies["s2"] {
+		t.Fatalf("replies = %v, want s1 and s2", replies)
+	}
+}
+
+func TestSerialCommandsDoNotOccupyWorkers(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.SetWorkerPool(&WorkerPoolConfig{Workers: 2, QueueSize: 4})
+		r.RegisterHandler("migrate", blockingHandler("migrate", started, release),
+			WithConcurrency(ConcurrencyPolicy{Mode: ConcurrencySerial}))
+		r.RegisterHandler("echo", echoHandler("echo", &calls))
+	})
+
+	h.submit(&Command{Command: "migrate", RequestID: "m1"})
+	waitStarted(t, started, "m1")
+	h.submit(&Command{Command: "migrate", RequestID: "m2"})
+	h.submit(&Command{Command: "migrate", RequestID: "m3"})
+
+	// 排队的串行命令不占用工作协程，其他命令仍可执行
+	h.expectCode(&Command{Command: "echo", RequestID: "e1"}, "")
+
+	close(release)
+	for _, id := range []string{"m2", "m3"} {
+		waitStarted(t, started, id)
+	}
+	got := []string{h.reply().RequestID, h.reply().RequestID, h.reply().RequestID}
+	if strings.Join(got, ",") != "m1,m2,m3" {
+		t.Fatalf("serial replies = %v, want m1,m2,m3", got)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/sync/registry.go
+ * 命令注册选项 - 描述命令处理器的执行方式和对外公布的元数据
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package sync
+
+import "sort"
+
+// handlerEntry 已注册的命令处理器及其选项
+type handlerEntry struct {
+	name        string // 注册的命令名或命名空间通配符
+	handler     CommandHandler
+	async       bool
+	inline      bool // 直接在消息回调中执行，不进入工作池
+	middlewares []Middleware
+	concurrency ConcurrencyPolicy
+	limiter     *concurrencyLimiter
+
+	// 随注册消息公布的元数据
+	description string
+	version     string
+	paramSchema *JSONSchema
+}
+
+// CommandInfo 命令元数据，随注册消息上报，供引擎生成命令表单
+type CommandInfo struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Version     string            `json:"version,omitempty"`
+	Async       bool              `json:"async,omitempty"`
+	Concurrency ConcurrencyPolicy `json:"concurrency"`
+	ParamSchema *JSONSchema       `json:"param_schema,omitempty"`
+}
+
+// paramSchemaProvider 可提供参数 Schema 的处理器，如 RegisterTyped 注册的处理器
+type paramSchemaProvider interface {
+	ParamSchema() *JSONSchema
+}
+
+// HandlerOption 命令注册选项
+type HandlerOption func(*handlerEntry)
+
+// WithAsync 以异步任务方式执行命令
+//
+// 异步命令不阻塞MQTT消息回调，接收器依次发布 accepted、progress、
+// completed/failed/canceled 事件。处理器可通过 ReportProgress 上报进度，
+// 并应在 ctx 取消后尽快返回以响应 cancel 命令。
+func WithAsync() HandlerOption {
+	return func(e *handlerEntry) {
+		e.async = true
+	}
+}
+
+// WithConcurrency 设置命令的并发策略，默认不限制同一命令的并发数
+func WithConcurrency(policy ConcurrencyPolicy) HandlerOption {
+	return func(e *handlerEntry) {
+		e.concurrency = policy
+	}
+}
+
+// WithDescription 设置命令描述
+func WithDescription(description string) HandlerOption {
+	return func(e *handlerEntry) {
+		e.description = description
+	}
+}
+
+// WithVersion 设置命令版本
+func WithVersion(version string) HandlerOption {
+	return func(e *handlerEntry) {
+		e.version = version
+	}
+}
+
+// WithParamSchema 设置命令参数的 JSON Schema
+// RegisterTyped 注册的命令会根据参数类型自动生成
+func WithParamSchema(schema *JSONSchema) HandlerOption {
+	return func(e *handlerEntry) {
+		e.paramSchema = schema
+	}
+}
+
+// withInline 内置的轻量命令直接在消息回调中执行
+func withInline() HandlerOption {
+	return func(e *handlerEntry) {
+		e.inline = true
+	}
+}
+
+// newHandlerEntry 创建命令注册项
+func newHandlerEntry(name string, handler CommandHandler, opts []HandlerOption) *handlerEntry {
+	entry := &handlerEntry{
+		name:        name,
+		handler:     handler,
+		concurrency: ConcurrencyPolicy{Mode: ConcurrencyParallel},
+	}
+	if provider, ok := handler.(paramSchemaProvider); ok {
+		entry.paramSchema = provider.ParamSchema()
+	}
+	for _, opt := range opts {
+		opt(entry)
+	}
+	entry.limiter = newConcurrencyLimiter(entry.concurrency)
+	return entry
+}
+
+// info 返回命令元数据
+func (e *handlerEntry) info() CommandInfo {
+	return CommandInfo{
+		Name:        e.name,
+		Description: e.description,
+		Version:     e.version,
+		Async:       e.async,
+		Concurrency: e.concurrency,
+		ParamSchema: e.paramSchema,
+	}
+}
+
+// hasAsyncHandler 判断是否注册了异步执行的处理器（包括默认处理器）
+func (r *CommandReceiver) hasAsyncHandler() bool {
+	r.handlersMu.RLock()
+	defer r.handlersMu.RUnlock()
+
+	if r.defaultHandler != nil && r.defaultHandler.async {
+		return true
+	}
+	for _, entry := range r.handlers {
+		if entry.async {
+			return true
+		}
+	}
+	return false
+}
+
+// commandInfos 返回所有已注册命令的元数据，按名称排序
+func (r *CommandReceiver) commandInfos() []CommandInfo {
+	r.handlersMu.RLock()
+	defer r.handlersMu.RUnlock()
+
+	infos := make([]CommandInfo, 0, len(r.handlers))
+	for _, entry := range r.handlers {
+		infos = append(infos, entry.info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
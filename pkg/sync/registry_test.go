@@ -0,0 +1,163 @@
+package sync
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/HY-805/SubNodeSync/pkg/protocol"
+	"github.com/HY-805/SubNodeSync/pkg/transport"
+)
+
+// registerMessage 注册消息中测试关心的字段
+type registerMessage struct {
+	Commands     []CommandInfo `json:"commands"`
+	Capabilities []string      `json:"capabilities"`
+}
+
+// registerWatcher 订阅注册主题，返回收到的注册消息
+func registerWatcher(t *testing.T, h *receiverHarness) <-chan *registerMessage {
+	t.Helper()
+	engine := transport.NewMemoryTransport(h.broker)
+	if err := engine.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { engine.Disconnect() })
+
+	registers := make(chan *registerMessage, 4)
+	engine.Subscribe(protocol.DefaultTopicScheme().Register("node"), 1, func(msg *transport.Message) {
+		env, _, err := protocol.Decode(msg.Payload)
+		if err != nil {
+			t.Errorf("decode register: %v", err)
+			return
+		}
+		var body registerMessage
+		if err := env.DecodeBody(&body); err != nil {
+			t.Errorf("decode register body: %v", err)
+			return
+		}
+		registers <- &body
+	})
+	return registers
+}
+
+func nextRegister(t *testing.T, registers <-chan *registerMessage) map[string]CommandInfo {
+	t.Helper()
+	select {
+	case register := <-registers:
+		byName := make(map[string]CommandInfo, len(register.Commands))
+		for _, info := range register.Commands {
+			byName[info.Name] = info
+		}
+		return byName
+	case <-time.After(testTimeout):
+		t.Fatal("no register message")
+		return nil
+	}
+}
+
+func TestRegisterMessageAdvertisesCommands(t *testing.T) {
+	h := newReceiverHarness(t, nil)
+	registers := registerWatcher(t, h)
+
+	// 连接后注册的命令立即重新公布
+	h.r.RegisterHandler("backup", NewCustomHandler("backup", func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+		return NewSuccessResult(cmd.RequestID, "ok", nil), nil
+	}), WithAsync(), WithDescription("备份数据"), WithVersion("2"),
+		WithConcurrency(ConcurrencyPolicy{Mode: ConcurrencySingleFlight}))
+
+	commands := nextRegister(t, registers)
+	backup, ok := commands["backup"]
+	if !ok {
+		t.Fatalf("register commands = %v, want backup", commands)
+	}
+	if !backup.Async || backup.Description != "备份数据" || backup.Version != "2" ||
+		backup.Concurrency.Mode != ConcurrencySingleFlight {
+		t.Fatalf("backup info = %+v", backup)
+	}
+	for _, builtin := range []string{"cancel", "jobs", "help"} {
+		if _, ok := commands[builtin]; !ok {
+			t.Errorf("built-in command %s not advertised", builtin)
+		}
+	}
+	if cancel := commands["cancel"]; cancel.ParamSchema == nil || len(cancel.ParamSchema.Required) != 1 {
+		t.Fatalf("cancel schema = %+v", cancel.ParamSchema)
+	}
+
+	// 注销后公布的命令列表不再包含该命令
+	if err := h.r.UnregisterHandler("backup"); err != nil {
+		t.Fatal(err)
+	}
+	if commands := nextRegister(t, registers); len(commands) != 3 {
+		t.Fatalf("register commands after unregister = %v", commands)
+	}
+}
+
+func TestRegisterMessageAdvertisesCapabilities(t *testing.T) {
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.SetIdempotency(nil)
+		r.AddCapabilities(CapabilitySparkplug)
+	})
+	registers := registerWatcher(t, h)
+
+	// 能力随启用的功能变化：MemoryTransport 支持消息属性，注册异步命令后增加 async_jobs
+	h.r.RegisterHandler("backup", echoHandler("backup", new(atomic.Int32)), WithAsync())
+	select {
+	case register := <-registers:
+		want := []string{CapabilityMQTTControl, CapabilityHeartbeat, CapabilityAsyncJobs,
+			CapabilityMQTT5Properties, CapabilitySparkplug}
+		if !slices.Equal(register.Capabilities, want) {
+			t.Fatalf("capabilities = %v, want %v", register.Capabilities, want)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("no register message")
+	}
+
+	verifier := NewCommandVerifier()
+	r := NewCommandReceiverWithTransport("node", "node-1", transport.NewMemoryTransport(transport.NewMemoryBroker()))
+	r.SetVerifier(verifier)
+	want := []string{CapabilityMQTTControl, CapabilityHeartbeat, CapabilityMQTT5Properties,
+		CapabilitySignedCommands, CapabilityIdempotency}
+	if got := r.capabilities(); !slices.Equal(got, want) {
+		t.Fatalf("capabilities = %v, want %v", got, want)
+	}
+}
+
+func TestHelpHandlersAndUnregister(t *testing.T) {
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.RegisterHandler("cache.flush", NewCustomHandler("cache.flush", func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+			return NewSuccessResult(cmd.RequestID, "flushed", nil), nil
+		}), WithDescription("清空缓存"))
+	})
+
+	infos := h.r.Handlers()
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name
+	}
+	if got := names; len(got) != 4 || got[0] != "cache.flush" || got[1] != "cancel" || got[2] != "help" || got[3] != "jobs" {
+		t.Fatalf("Handlers() = %v, want sorted names", got)
+	}
+
+	reply := h.expectCode(&Command{Command: "help", RequestID: "r1"}, "")
+	if list, ok := reply.Data.([]CommandInfo); !ok || len(list) != 4 {
+		t.Fatalf("help data = %#v", reply.Data)
+	}
+	reply = h.expectCode(&Command{Command: "help", RequestID: "r2",
+		Parameters: map[string]interface{}{"command": "cache.flush"}}, "")
+	if info, ok := reply.Data.(CommandInfo); !ok || info.Description != "清空缓存" {
+		t.Fatalf("help cache.flush data = %#v", reply.Data)
+	}
+	h.expectCode(&Command{Command: "help", RequestID: "r3",
+		Parameters: map[string]interface{}{"command": "missing"}}, ErrCodeNotFound)
+
+	if err := h.r.UnregisterHandler("cache.flush"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.r.UnregisterHandler("cache.flush"); err == nil {
+		t.Fatal("unregistering twice succeeded")
+	}
+	h.expectCode(&Command{Command: "cache.flush", RequestID: "r4"}, ErrCodeNotFound)
+}
@@ -0,0 +1,86 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/sync/result.go
+ * 命令结果错误码 - 提供机器可读的错误分类
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrorCode 命令结果错误码
+type ErrorCode string
+
+const (
+	ErrCodeNotFound      ErrorCode = "not_found"      // 命令或资源不存在
+	ErrCodeInvalidParams ErrorCode = "invalid_params" // 参数错误
+	ErrCodeTimeout       ErrorCode = "timeout"        // 执行超时
+	ErrCodeUnauthorized  ErrorCode = "unauthorized"   // 未授权
+	ErrCodeInternal      ErrorCode = "internal"       // 内部错误
+	ErrCodeExpired       ErrorCode = "expired"        // 命令已过期
+	ErrCodeCanceled      ErrorCode = "canceled"       // 命令已取消
+	ErrCodeBusy          ErrorCode = "busy"           // 工作池或命令并发已满
+)
+
+// CommandError 带错误码的命令错误
+// 处理器返回该错误时，错误码会透传到应答消息中
+type CommandError struct {
+	Code    ErrorCode
+	Message string
+}
+
+// NewCommandError 创建带错误码的命令错误
+func NewCommandError(code ErrorCode, format string, args ...interface{}) *CommandError {
+	return &CommandError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Error 实现error接口
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// ErrorCodeOf 获取错误对应的错误码
+// 非 CommandError 的错误中，超时归类为 timeout，其余归类为 internal
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Code
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrCodeTimeout
+	}
+	return ErrCodeInternal
+}
+
+// NewSuccessResult 创建携带结构化数据的成功结果
+func NewSuccessResult(requestID, message string, data interface{}) *CommandResult {
+	return &CommandResult{
+		Success:   true,
+		Message:   message,
+		RequestID: requestID,
+		Data:      data,
+	}
+}
+
+// NewErrorResult 创建带错误码的失败结果
+func NewErrorResult(requestID string, code ErrorCode, message string) *CommandResult {
+	return &CommandResult{
+		Success:   false,
+		Message:   message,
+		RequestID: requestID,
+		Code:      code,
+	}
+}
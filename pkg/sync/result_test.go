@@ -0,0 +1,52 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{"nil", nil, ""},
+		{"command error", NewCommandError(ErrCodeInvalidParams, "bad %s", "x"), ErrCodeInvalidParams},
+		{"wrapped command error", fmt.Errorf("load: %w", NewCommandError(ErrCodeNotFound, "missing")), ErrCodeNotFound},
+		{"deadline", fmt.Errorf("call: %w", context.DeadlineExceeded), ErrCodeTimeout},
+		{"other", errors.New("disk full"), ErrCodeInternal},
+	}
+	for _, tt := range tests {
+		if got := ErrorCodeOf(tt.err); got != tt.want {
+			t.Errorf("%s: ErrorCodeOf = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewCommandReplyCarriesDataAndCode(t *testing.T) {
+	cmd := &Command{Command: "status", RequestID: "r1"}
+	data := map[string]interface{}{"cpu": 12.5}
+
+	reply := newCommandReply("node", "node-1", cmd, NewSuccessResult("r1", "ok", data), nil, time.Now())
+	if !reply.Success || reply.Code != "" || reply.Data == nil || reply.Message != "ok" {
+		t.Fatalf("success reply = %+v", reply)
+	}
+
+	// 结果中的错误码优先于错误推断的错误码
+	reply = newCommandReply("node", "node-1", cmd,
+		NewErrorResult("r1", ErrCodeBusy, "busy"), errors.New("queue full"), time.Now())
+	if reply.Success || reply.Code != ErrCodeBusy || reply.Error != "queue full" {
+		t.Fatalf("error result reply = %+v", reply)
+	}
+
+	// 处理器返回错误时覆盖结果中的 Success
+	err := NewCommandError(ErrCodeInvalidParams, "missing path")
+	reply = newCommandReply("node", "node-1", cmd, NewSuccessResult("r1", "ok", nil), err, time.Now())
+	if reply.Success || reply.Code != ErrCodeInvalidParams || reply.Error != err.Error() {
+		t.Fatalf("handler error reply = %+v", reply)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/sync/router.go
+ * 命令路由 - 支持点分命名空间和通配符
+ *
+ * 命令名使用点分命名空间（如 cache.flush、cache.stats），
+ * 注册 "cache.*" 的处理器负责 cache 命名空间下的所有命令（包括 cache.a.b），
+ * 注册 "*" 的处理器匹配所有命令。
+ *
+ * 匹配顺序：
+ * 1. 精确匹配
+ * 2. 最长的命名空间通配符
+ * 3. SetDefaultHandler 设置的默认处理器
+ * 4. 以上都未匹配时应答 not_found
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package sync
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateCommandPattern 检查命令名或通配符是否合法
+// 通配符只能作为最后一段出现
+func validateCommandPattern(command string) error {
+	if command == "" {
+		return fmt.Errorf("command name is empty")
+	}
+	segments := strings.Split(command, ".")
+	for i, segment := range segments {
+		if segment == "" {
+			return fmt.Errorf("command %q has an empty namespace segment", command)
+		}
+		if strings.Contains(segment, "*") && (segment != "*" || i != len(segments)-1) {
+			return fmt.Errorf("command %q: wildcard is only allowed as the last segment", command)
+		}
+	}
+	return nil
+}
+
+// matchCommandPattern 判断命令名是否匹配命令模式
+// "ns.*" 匹配 ns 命名空间下的所有命令（不包括 ns 本身），"*" 匹配所有命令，其他模式只做精确匹配
+func matchCommandPattern(pattern, command string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasSuffix(pattern, ".*"):
+		return strings.HasPrefix(command, pattern[:len(pattern)-1])
+	default:
+		return pattern == command
+	}
+}
+
+// SetDefaultHandler 设置未匹配任何命令时使用的默认处理器，传入nil取消
+func (r *CommandReceiver) SetDefaultHandler(handler CommandHandler, opts ...HandlerOption) {
+	r.handlersMu.Lock()
+	defer r.handlersMu.Unlock()
+
+	if handler == nil {
+		r.defaultHandler = nil
+		return
+	}
+	r.defaultHandler = newHandlerEntry("", handler, opts)
+}
+
+// lookupHandler 按精确匹配、命名空间通配符、默认处理器的顺序查找处理器
+func (r *CommandReceiver) lookupHandler(command string) (*handlerEntry, bool) {
+	r.handlersMu.RLock()
+	defer r.handlersMu.RUnlock()
+
+	if entry, ok := r.handlers[command]; ok {
+		return entry, true
+	}
+
+	namespace := command
+	for {
+		i := strings.LastIndex(namespace, ".")
+		if i < 0 {
+			break
+		}
+		namespace = namespace[:i]
+		if entry, ok := r.handlers[namespace+".*"]; ok {
+			return entry, true
+		}
+	}
+	if entry, ok := r.handlers["*"]; ok {
+		return entry, true
+	}
+
+	if r.defaultHandler != nil {
+		return r.defaultHandler, true
+	}
+	return nil, false
+}
@@ -0,0 +1,93 @@
+package sync
+
+import (
+	"context"
+	"testing"
+)
+
+// namedHandler 在结果消息中返回处理器名称
+func namedHandler(name string) CommandHandler {
+	return NewCustomHandler(name, func(ctx context.Context, cmd *Command) (*CommandResult, error) {
+		return NewSuccessResult(cmd.RequestID, name, nil), nil
+	})
+}
+
+func TestLookupHandlerWildcards(t *testing.T) {
+	r := NewCommandReceiverWithInstanceID("node", "node-1", "")
+	for _, pattern := range []string{"cache.flush", "cache.*", "cache.redis.*", "db.*"} {
+		if err := r.RegisterHandler(pattern, namedHandler(pattern)); err != nil {
+			t.Fatalf("register %s: %v", pattern, err)
+		}
+	}
+
+	tests := []struct {
+		command string
+		want    string // 为空表示未匹配
+	}{
+		{"cache.flush", "cache.flush"},
+		{"cache.stats", "cache.*"},
+		{"cache.a.b", "cache.*"},
+		{"cache.redis.ping", "cache.redis.*"},
+		{"cache.redis.cluster.nodes", "cache.redis.*"},
+		{"cache", ""},
+		{"db.migrate", "db.*"},
+		{"dbx.migrate", ""},
+		{"other", ""},
+	}
+	check := func(stage string) {
+		t.Helper()
+		for _, tt := range tests {
+			entry, ok := r.lookupHandler(tt.command)
+			switch {
+			case tt.want == "" && ok:
+				t.Errorf("%s: %s matched %q, want no match", stage, tt.command, entry.name)
+			case tt.want != "" && (!ok || entry.name != tt.want):
+				t.Errorf("%s: %s matched %v, want %s", stage, tt.command, entry, tt.want)
+			}
+		}
+	}
+	check("namespaces")
+
+	// "*" 匹配其他所有命令，默认处理器只在 "*" 之后使用
+	r.SetDefaultHandler(namedHandler("default"))
+	if entry, ok := r.lookupHandler("other"); !ok || entry.name != "" {
+		t.Fatalf("default handler lookup = %v, %v", entry, ok)
+	}
+	r.RegisterHandler("*", namedHandler("*"))
+	for i := range tests {
+		if tests[i].want == "" {
+			tests[i].want = "*"
+		}
+	}
+	check("catch-all")
+}
+
+func TestValidateCommandPattern(t *testing.T) {
+	for _, pattern := range []string{"stop", "cache.flush", "cache.*", "*", "a.b.c.*"} {
+		if err := validateCommandPattern(pattern); err != nil {
+			t.Errorf("%q: unexpected error %v", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"", "cache.", ".flush", "cache..flush", "*.flush", "cache.*.stats", "cache.fl*"} {
+		if err := validateCommandPattern(pattern); err == nil {
+			t.Errorf("%q: accepted invalid pattern", pattern)
+		}
+	}
+}
+
+func TestDefaultHandlerReceivesUnmatchedCommands(t *testing.T) {
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.RegisterHandler("cache.*", namedHandler("cache.*"))
+		r.SetDefaultHandler(namedHandler("default"))
+	})
+
+	if reply := h.expectCode(&Command{Command: "cache.flush", RequestID: "r1"}, ""); reply.Message != "cache.*" {
+		t.Fatalf("cache.flush handled by %q", reply.Message)
+	}
+	if reply := h.expectCode(&Command{Command: "reindex", RequestID: "r2"}, ""); reply.Message != "default" {
+		t.Fatalf("reindex handled by %q", reply.Message)
+	}
+
+	h.r.SetDefaultHandler(nil)
+	h.expectCode(&Command{Command: "reindex", RequestID: "r3"}, ErrCodeNotFound)
+}
@@ -0,0 +1,243 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/sync/selector.go
+ * 标签选择器 - 用于按标签分组下发命令
+ *
+ * 语法（多个条件以逗号分隔，全部满足才匹配）：
+ *   key=value / key==value  标签等于指定值
+ *   key!=value              标签不等于指定值（标签不存在也视为满足）
+ *   key in (v1,v2)          标签值属于集合
+ *   key notin (v1,v2)       标签值不属于集合（标签不存在也视为满足）
+ *   key                     标签存在
+ *   !key                    标签不存在
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package sync
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SelectorOperator 选择器操作符
+type SelectorOperator string
+
+const (
+	SelectorEquals    SelectorOperator = "="
+	SelectorNotEquals SelectorOperator = "!="
+	SelectorIn        SelectorOperator = "in"
+	SelectorNotIn     SelectorOperator = "notin"
+	SelectorExists    SelectorOperator = "exists"
+	SelectorNotExists SelectorOperator = "!"
+)
+
+// Requirement 单个标签匹配条件
+type Requirement struct {
+	Key      string
+	Operator SelectorOperator
+	Values   []string
+}
+
+// Selector 标签选择器，所有条件均满足时匹配
+type Selector struct {
+	requirements []Requirement
+}
+
+// ParseSelector 解析标签选择器表达式，如 "region=cn-east,env!=prod"
+// 空表达式匹配所有节点；接收器不执行选择器为空的分组命令
+func ParseSelector(expr string) (*Selector, error) {
+	terms, err := splitSelectorTerms(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	selector := &Selector{}
+	for _, term := range terms {
+		req, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		selector.requirements = append(selector.requirements, req)
+	}
+	return selector, nil
+}
+
+// Matches 判断标签集合是否满足选择器
+func (s *Selector) Matches(labels map[string]string) bool {
+	for _, req := range s.requirements {
+		if !req.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Requirements 返回选择器包含的条件
+func (s *Selector) Requirements() []Requirement {
+	return s.requirements
+}
+
+// String 返回选择器的规范化表达式
+func (s *Selector) String() string {
+	parts := make([]string, 0, len(s.requirements))
+	for _, req := range s.requirements {
+		parts = append(parts, req.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// Matches 判断标签集合是否满足条件
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, exists := labels[r.Key]
+	switch r.Operator {
+	case SelectorEquals:
+		return exists && value == r.Values[0]
+	case SelectorNotEquals:
+		return !exists || value != r.Values[0]
+	case SelectorIn:
+		return exists && containsString(r.Values, value)
+	case SelectorNotIn:
+		return !exists || !containsString(r.Values, value)
+	case SelectorExists:
+		return exists
+	case SelectorNotExists:
+		return !exists
+	default:
+		return false
+	}
+}
+
+// String 返回条件的表达式
+func (r Requirement) String() string {
+	switch r.Operator {
+	case SelectorEquals, SelectorNotEquals:
+		return r.Key + string(r.Operator) + r.Values[0]
+	case SelectorIn, SelectorNotIn:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	case SelectorNotExists:
+		return "!" + r.Key
+	default:
+		return r.Key
+	}
+}
+
+// splitSelectorTerms 按顶层逗号拆分表达式，忽略括号内的逗号
+func splitSelectorTerms(expr string) ([]string, error) {
+	var terms []string
+	depth, start := 0, 0
+	for i, c := range expr {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("invalid selector %q: unbalanced parentheses", expr)
+			}
+		case ',':
+			if depth == 0 {
+				terms = appendTerm(terms, expr[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("invalid selector %q: unbalanced parentheses", expr)
+	}
+	return appendTerm(terms, expr[start:]), nil
+}
+
+// appendTerm 追加非空条件
+func appendTerm(terms []string, term string) []string {
+	if term = strings.TrimSpace(term); term != "" {
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+// parseRequirement 解析单个条件
+func parseRequirement(term string) (Requirement, error) {
+	if strings.HasPrefix(term, "!") && !strings.Contains(term, "=") {
+		return newRequirement(term[1:], SelectorNotExists, nil)
+	}
+	if i := strings.Index(term, "!="); i >= 0 {
+		return newRequirement(term[:i], SelectorNotEquals, []string{term[i+2:]})
+	}
+	if i := strings.Index(term, "=="); i >= 0 {
+		return newRequirement(term[:i], SelectorEquals, []string{term[i+2:]})
+	}
+	if i := strings.Index(term, "="); i >= 0 {
+		return newRequirement(term[:i], SelectorEquals, []string{term[i+1:]})
+	}
+
+	// 集合条件: key in (a,b) / key notin (a,b)
+	if open := strings.Index(term, "("); open >= 0 {
+		if !strings.HasSuffix(term, ")") {
+			return Requirement{}, fmt.Errorf("invalid selector term %q: missing ')'", term)
+		}
+		fields := strings.Fields(term[:open])
+		if len(fields) != 2 {
+			return Requirement{}, fmt.Errorf("invalid selector term %q", term)
+		}
+		var values []string
+		for _, v := range strings.Split(term[open+1:len(term)-1], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			return Requirement{}, fmt.Errorf("invalid selector term %q: empty value set", term)
+		}
+		switch SelectorOperator(fields[1]) {
+		case SelectorIn:
+			return newRequirement(fields[0], SelectorIn, values)
+		case SelectorNotIn:
+			return newRequirement(fields[0], SelectorNotIn, values)
+		default:
+			return Requirement{}, fmt.Errorf("invalid selector term %q: unknown operator %q", term, fields[1])
+		}
+	}
+
+	return newRequirement(term, SelectorExists, nil)
+}
+
+// newRequirement 校验并创建条件
+func newRequirement(key string, op SelectorOperator, values []string) (Requirement, error) {
+	key = strings.TrimSpace(key)
+	if !isValidLabelKey(key) {
+		return Requirement{}, fmt.Errorf("invalid selector label key %q", key)
+	}
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return Requirement{Key: key, Operator: op, Values: values}, nil
+}
+
+// isValidLabelKey 校验标签键，只允许字母、数字和 -_./
+func isValidLabelKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == '/':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// containsString 判断字符串是否在切片中
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
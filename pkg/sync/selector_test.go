@@ -0,0 +1,106 @@
+package sync
+
+import (
+	"testing"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // 规范化表达式
+	}{
+		{"", ""},
+		{"region=cn-east", "region=cn-east"},
+		{"region==cn-east", "region=cn-east"},
+		{" region = cn-east , env != prod ", "region=cn-east,env!=prod"},
+		{"zone in (a, b,c)", "zone in (a,b,c)"},
+		{"zone notin (a)", "zone notin (a)"},
+		{"gpu", "gpu"},
+		{"!gpu", "!gpu"},
+		{"app.kubernetes.io/name=edge,tier in (x,y),!canary", "app.kubernetes.io/name=edge,tier in (x,y),!canary"},
+	}
+	for _, tc := range tests {
+		selector, err := ParseSelector(tc.expr)
+		if err != nil {
+			t.Errorf("ParseSelector(%q): %v", tc.expr, err)
+			continue
+		}
+		if got := selector.String(); got != tc.want {
+			t.Errorf("ParseSelector(%q) = %q, want %q", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestParseSelectorErrors(t *testing.T) {
+	for _, expr := range []string{
+		"zone in (a,b",
+		"zone in a,b)",
+		"zone in ()",
+		"zone within (a)",
+		"in (a)",
+		"=value",
+		"bad key=x",
+		"key$=x",
+		"!",
+	} {
+		if _, err := ParseSelector(expr); err == nil {
+			t.Errorf("ParseSelector(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	labels := map[string]string{"region": "cn-east", "env": "staging", "gpu": "true"}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"region=cn-east", true},
+		{"region=cn-west", false},
+		{"env!=prod", true},
+		{"owner!=bob", true}, // 标签不存在也满足
+		{"region in (cn-east,cn-north)", true},
+		{"region in (cn-west)", false},
+		{"owner in (bob)", false},
+		{"env notin (prod)", true},
+		{"owner notin (bob)", true},
+		{"gpu", true},
+		{"owner", false},
+		{"!owner", true},
+		{"!gpu", false},
+		{"region=cn-east,env=staging,gpu", true},
+		{"region=cn-east,env=prod", false},
+	}
+	for _, tc := range tests {
+		selector, err := ParseSelector(tc.expr)
+		if err != nil {
+			t.Fatalf("ParseSelector(%q): %v", tc.expr, err)
+		}
+		if got := selector.Matches(labels); got != tc.want {
+			t.Errorf("%q matches = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestGroupCommandUsesSelector(t *testing.T) {
+	r := NewCommandReceiver("node", "")
+	r.SetLabels(map[string]string{"region": "cn-east"})
+	topic := r.topics.GroupControl()
+
+	for _, tc := range []struct {
+		selector string
+		want     bool
+	}{
+		{"region=cn-east", true},
+		{"region=cn-west", false},
+		{"region in (cn-east", false}, // 无法解析的选择器不匹配
+		{"", false},                   // 选择器缺失的分组命令不在所有节点执行
+		{"  ", false},
+	} {
+		cmd := &Command{Command: "status", Scope: ScopeGroup, Selector: tc.selector}
+		if got := r.acceptsCommand(topic, cmd); got != tc.want {
+			t.Errorf("selector %q accepted = %v, want %v", tc.selector, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/sync/signature.go
+ * 命令签名 - 校验命令来源并防止重放
+ *
+ * 签名原文为命令的JSON序列化结果（encoding/json，签名对象中的 value 置空），
+ * key_id、alg、nonce 均包含在原文中。引擎应使用 CommandSigner 生成签名，
+ * 其他语言实现需保证序列化结果与 Go 一致（结构体字段顺序、map键排序）。
+ * 接收方基于收到的消息体重建原文，参数中的数值保持原样，不经过 float64 转换。
+ *
+ * 签名命令必须声明目标，避免发给一个节点的命令被转发到其他节点重放：
+ * - Scope 必须显式设置
+ * - node、instance 范围必须设置 Target
+ * - broadcast、group 范围由 Namespace 和 Selector 限定，两者都包含在原文中
+ *
+ * 防重放：
+ * - 命令的 Timestamp 必须在重放窗口内
+ * - 同一 key_id 下的 nonce 在重放窗口内只能使用一次
+ * - 设置 nonce 持久化文件（SetNonceStore）后，重启前使用过的 nonce 在重启后仍然无效，
+ *   否则截获的命令可在进程重启后、重放窗口结束前再次执行
+ *
+ * 密钥轮换：校验器可同时信任多个 key_id，新旧密钥并存期间两者签名均有效。
+ *
+ * 调用方绑定：命令的 Caller 必须是签名密钥绑定的调用方（BindCallers），
+ * 密钥没有绑定调用方时 Caller 只能为空或等于 key_id，调用方身份即为 key_id。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package sync
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	gosync "sync"
+	"time"
+)
+
+// SignatureAlgorithm 签名算法
+type SignatureAlgorithm string
+
+const (
+	SignatureHMACSHA256 SignatureAlgorithm = "hmac-sha256" // 共享密钥
+	SignatureEd25519    SignatureAlgorithm = "ed25519"     // 公私钥
+)
+
+// DefaultReplayWindow 默认重放窗口
+const DefaultReplayWindow = 5 * time.Minute
+
+// ErrCommandReplay 命令重放错误
+var ErrCommandReplay = errors.New("command replay detected")
+
+// CommandSignature 命令签名
+type CommandSignature struct {
+	KeyID     string             `json:"key_id"`
+	Algorithm SignatureAlgorithm `json:"alg"`
+	Nonce     string             `json:"nonce"`
+	Value     string             `json:"value,omitempty"` // base64编码的签名值
+}
+
+// SigningPayload 返回命令的签名原文
+// 从控制主题收到的命令基于消息原文重建，数值使用 json.Number 避免精度丢失
+func (c *Command) SigningPayload() ([]byte, error) {
+	if c.raw != nil {
+		dec := json.NewDecoder(bytes.NewReader(c.raw))
+		dec.UseNumber()
+		var exact Command
+		if err := dec.Decode(&exact); err != nil {
+			return nil, err
+		}
+		c = &exact
+	}
+	clone := *c
+	if c.Signature != nil {
+		sig := *c.Signature
+		sig.Value = ""
+		clone.Signature = &sig
+	}
+	return json.Marshal(&clone)
+}
+
+// checkDestination 检查签名命令是否声明了目标
+func checkDestination(cmd *Command) error {
+	switch cmd.Scope {
+	case "":
+		return errors.New("signed command must declare a scope")
+	case ScopeNode, ScopeInstance:
+		if cmd.Target == "" {
+			return fmt.Errorf("signed %s command must declare a target", cmd.Scope)
+		}
+	}
+	return nil
+}
+
+// CommandSigner 命令签名器，供引擎或运维工具使用
+type CommandSigner struct {
+	keyID      string
+	algorithm  SignatureAlgorithm
+	secret     []byte
+	privateKey ed25519.PrivateKey
+}
+
+// NewHMACSigner 创建 HMAC-SHA256 签名器
+func NewHMACSigner(keyID string, secret []byte) *CommandSigner {
+	return &CommandSigner{keyID: keyID, algorithm: SignatureHMACSHA256, secret: secret}
+}
+
+// NewEd25519Signer 创建 Ed25519 签名器
+func NewEd25519Signer(keyID string, privateKey ed25519.PrivateKey) *CommandSigner {
+	return &CommandSigner{keyID: keyID, algorithm: SignatureEd25519, privateKey: privateKey}
+}
+
+// Sign 为命令生成签名
+// 未设置 Timestamp 时使用当前时间，每次签名生成新的 nonce；命令必须声明目标
+func (s *CommandSigner) Sign(cmd *Command) error {
+	if err := checkDestination(cmd); err != nil {
+		return err
+	}
+	if cmd.Timestamp == "" {
+		cmd.Timestamp = time.Now().Format(time.RFC3339)
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("generate nonce: %w", err)
+	}
+	cmd.Signature = &CommandSignature{
+		KeyID:     s.keyID,
+		Algorithm: s.algorithm,
+		Nonce:     hex.EncodeToString(nonce),
+	}
+
+	payload, err := cmd.SigningPayload()
+	if err != nil {
+		return fmt.Errorf("marshal signing payload: %w", err)
+	}
+
+	var sig []byte
+	switch s.algorithm {
+	case SignatureHMACSHA256:
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write(payload)
+		sig = mac.Sum(nil)
+	case SignatureEd25519:
+		sig = ed25519.Sign(s.privateKey, payload)
+	}
+	cmd.Signature.Value = base64.StdEncoding.EncodeToString(sig)
+	return nil
+}
+
+// trustedKey 受信任的密钥
+type trustedKey struct {
+	algorithm SignatureAlgorithm
+	secret    []byte
+	publicKey ed25519.PublicKey
+}
+
+// CommandVerifier 命令签名校验器
+type CommandVerifier struct {
+	keys    map[string]trustedKey
+	callers map[string]map[string]bool // key_id -> 允许声明的调用方
+	window  time.Duration
+	nonces  map[string]time.Time // key_id/nonce -> 首次出现时间
+	mu      gosync.Mutex
+
+	noncePath    string       // nonce 持久化文件路径，为空时仅保存在内存中
+	persistTimer *time.Timer  // 等待中的延迟写入，为nil表示没有未写入的修改
+	persistMu    gosync.Mutex // 串行化文件写入
+}
+
+// NewCommandVerifier 创建命令签名校验器
+func NewCommandVerifier() *CommandVerifier {
+	return &CommandVerifier{
+		keys:    make(map[string]trustedKey),
+		callers: make(map[string]map[string]bool),
+		window:  DefaultReplayWindow,
+		nonces:  make(map[string]time.Time),
+	}
+}
+
+// AddHMACKey 添加受信任的 HMAC 共享密钥
+func (v *CommandVerifier) AddHMACKey(keyID string, secret []byte) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys[keyID] = trustedKey{algorithm: SignatureHMACSHA256, secret: secret}
+}
+
+// AddEd25519Key 添加受信任的 Ed25519 公钥，长度不是 ed25519.PublicKeySize 时返回错误
+func (v *CommandVerifier) AddEd25519Key(keyID string, publicKey ed25519.PublicKey) error {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("ed25519 public key %q has invalid size %d", keyID, len(publicKey))
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys[keyID] = trustedKey{algorithm: SignatureEd25519, publicKey: publicKey}
+	return nil
+}
+
+// RemoveKey 移除密钥，用于完成密钥轮换
+func (v *CommandVerifier) RemoveKey(keyID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.keys, keyID)
+}
+
+// BindCallers 将调用方绑定到签名密钥，该密钥签名的命令只能声明这些调用方
+// 重复调用会追加绑定
+func (v *CommandVerifier) BindCallers(keyID string, callers ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	bound := v.callers[keyID]
+	if bound == nil {
+		bound = make(map[string]bool)
+		v.callers[keyID] = bound
+	}
+	for _, caller := range callers {
+		bound[caller] = true
+	}
+}
+
+// checkCaller 检查命令声明的调用方是否绑定到签名密钥
+func (v *CommandVerifier) checkCaller(cmd *Command) error {
+	keyID := cmd.Signature.KeyID
+	if cmd.Caller == "" || cmd.Caller == keyID || v.callers[keyID][cmd.Caller] {
+		return nil
+	}
+	return fmt.Errorf("caller %q is not bound to signing key %q", cmd.Caller, keyID)
+}
+
+// SetReplayWindow 设置重放窗口
+func (v *CommandVerifier) SetReplayWindow(window time.Duration) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if window > 0 {
+		v.window = window
+	}
+}
+
+// SetNonceStore 设置 nonce 记录的持久化文件，并加载重放窗口内的已有记录
+// 记录在后台合并写入，接收器停止时同步写入剩余的修改；应在 Start 之前调用
+func (v *CommandVerifier) SetNonceStore(path string) error {
+	var nonces map[string]time.Time
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &nonces); err != nil {
+			return fmt.Errorf("parse nonce store: %w", err)
+		}
+	case !os.IsNotExist(err):
+		return fmt.Errorf("read nonce store: %w", err)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.noncePath = path
+	now := time.Now()
+	for nonce, seenAt := range nonces {
+		if now.Sub(seenAt) <= 2*v.window {
+			v.nonces[nonce] = seenAt
+		}
+	}
+	return nil
+}
+
+// schedulePersist 安排延迟写入 nonce 记录，调用方需持有 v.mu
+func (v *CommandVerifier) schedulePersist() {
+	if v.noncePath == "" || v.persistTimer != nil {
+		return
+	}
+	v.persistTimer = time.AfterFunc(idempotencyPersistDelay, v.flush)
+}
+
+// flush 立即写入未写入的 nonce 记录
+func (v *CommandVerifier) flush() {
+	v.persistMu.Lock()
+	defer v.persistMu.Unlock()
+
+	v.mu.Lock()
+	if v.persistTimer == nil {
+		v.mu.Unlock()
+		return
+	}
+	v.persistTimer.Stop()
+	v.persistTimer = nil
+	path := v.noncePath
+	data, err := json.Marshal(v.nonces)
+	v.mu.Unlock()
+	if err != nil {
+		return
+	}
+	_ = writeFileAtomic(path, data)
+}
+
+// Verify 校验命令目标、签名、时间戳和 nonce
+// nonce 重复时返回的错误包装了 ErrCommandReplay
+func (v *CommandVerifier) Verify(cmd *Command) error {
+	sig := cmd.Signature
+	if sig == nil || sig.Value == "" {
+		return errors.New("command is not signed")
+	}
+	if err := checkDestination(cmd); err != nil {
+		return err
+	}
+	if sig.Nonce == "" {
+		return errors.New("signature nonce is required")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key, ok := v.keys[sig.KeyID]
+	if !ok {
+		return fmt.Errorf("unknown signing key %q", sig.KeyID)
+	}
+	if key.algorithm != sig.Algorithm {
+		return fmt.Errorf("signing key %q does not support algorithm %q", sig.KeyID, sig.Algorithm)
+	}
+
+	value, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	payload, err := cmd.SigningPayload()
+	if err != nil {
+		return fmt.Errorf("marshal signing payload: %w", err)
+	}
+	switch key.algorithm {
+	case SignatureHMACSHA256:
+		mac := hmac.New(sha256.New, key.secret)
+		mac.Write(payload)
+		if !hmac.Equal(value, mac.Sum(nil)) {
+			return errors.New("invalid signature")
+		}
+	case SignatureEd25519:
+		if !ed25519.Verify(key.publicKey, payload, value) {
+			return errors.New("invalid signature")
+		}
+	}
+
+	if err := v.checkCaller(cmd); err != nil {
+		return err
+	}
+
+	// 签名有效后再检查时间窗口和 nonce，避免伪造命令污染 nonce 记录
+	issuedAt, err := time.Parse(time.RFC3339, cmd.Timestamp)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q: %w", cmd.Timestamp, err)
+	}
+	now := time.Now()
+	if d := now.Sub(issuedAt); d > v.window || d < -v.window {
+		return fmt.Errorf("timestamp %s outside replay window %s", cmd.Timestamp, v.window)
+	}
+
+	for nonce, seenAt := range v.nonces {
+		if now.Sub(seenAt) > 2*v.window {
+			delete(v.nonces, nonce)
+		}
+	}
+	nonceKey := sig.KeyID + "/" + sig.Nonce
+	if _, seen := v.nonces[nonceKey]; seen {
+		return fmt.Errorf("%w: nonce %s already used", ErrCommandReplay, sig.Nonce)
+	}
+	v.nonces[nonceKey] = now
+	v.schedulePersist()
+	return nil
+}
+
+// LoadEd25519PublicKeyFile 从文件加载 Ed25519 公钥
+// 支持 PEM 格式（PKIX "PUBLIC KEY"）和 base64 编码的原始公钥
+func LoadEd25519PublicKeyFile(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if block, _ := pem.Decode(data); block != nil {
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse public key %s: %w", path, err)
+		}
+		key, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key %s is not ed25519", path)
+		}
+		return key, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("decode public key %s: %w", path, err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key %s has invalid size %d", path, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
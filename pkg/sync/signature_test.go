@@ -0,0 +1,440 @@
+package sync
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/HY-805/SubNodeSync/pkg/codec"
+	"github.com/HY-805/SubNodeSync/pkg/protocol"
+	"github.com/HY-805/SubNodeSync/pkg/transport"
+)
+
+func signedCommand(t *testing.T, signer *CommandSigner) *Command {
+	t.Helper()
+	cmd := &Command{
+		Command:    "restart",
+		RequestID:  "req-1",
+		Scope:      ScopeInstance,
+		Target:     "node-1",
+		Parameters: map[string]interface{}{"delay": 5.0},
+	}
+	if err := signer.Sign(cmd); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return cmd
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	v := NewCommandVerifier()
+	v.AddHMACKey("k1", []byte("secret"))
+
+	cmd := signedCommand(t, NewHMACSigner("k1", []byte("secret")))
+	if cmd.Signature.Algorithm != SignatureHMACSHA256 || cmd.Timestamp == "" || cmd.Signature.Nonce == "" {
+		t.Fatalf("signature = %+v, timestamp %q", cmd.Signature, cmd.Timestamp)
+	}
+	if err := v.Verify(cmd); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+}
+
+func TestVerifyEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewCommandVerifier()
+	if err := v.AddEd25519Key("ops", pub); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v.Verify(signedCommand(t, NewEd25519Signer("ops", priv))); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	// 其他私钥签名的命令无效
+	_, other, _ := ed25519.GenerateKey(nil)
+	if err := v.Verify(signedCommand(t, NewEd25519Signer("ops", other))); err == nil {
+		t.Fatal("signature by another key verified")
+	}
+}
+
+func TestAddEd25519KeyRejectsInvalidSize(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewCommandVerifier()
+	for _, key := range []ed25519.PublicKey{nil, pub[:16], append(pub[:len(pub):len(pub)], 0)} {
+		if err := v.AddEd25519Key("ops", key); err == nil {
+			t.Fatalf("key of size %d accepted", len(key))
+		}
+	}
+
+	// 被拒绝的密钥不会进入信任列表，签名命令按未知密钥拒绝而不是在校验时 panic
+	if err := v.Verify(signedCommand(t, NewEd25519Signer("ops", priv))); err == nil {
+		t.Fatal("command signed with rejected key verified")
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	v := NewCommandVerifier()
+	v.AddHMACKey("k1", []byte("secret"))
+	signer := NewHMACSigner("k1", []byte("secret"))
+
+	tamper := map[string]func(cmd *Command){
+		"command":   func(cmd *Command) { cmd.Command = "stop" },
+		"caller":    func(cmd *Command) { cmd.Caller = "admin" },
+		"parameter": func(cmd *Command) { cmd.Parameters["delay"] = 0.0 },
+		"nonce":     func(cmd *Command) { cmd.Signature.Nonce = "00" },
+		"namespace": func(cmd *Command) { cmd.Namespace = "other" },
+		"target":    func(cmd *Command) { cmd.Target = "node-2" },
+		"scope":     func(cmd *Command) { cmd.Scope = "" },
+		"value":     func(cmd *Command) { cmd.Signature.Value = base64.StdEncoding.EncodeToString([]byte("forged")) },
+		"encoding":  func(cmd *Command) { cmd.Signature.Value = "!!" },
+		"unsigned":  func(cmd *Command) { cmd.Signature = nil },
+		"key":       func(cmd *Command) { cmd.Signature.KeyID = "k2" },
+		"algorithm": func(cmd *Command) { cmd.Signature.Algorithm = SignatureEd25519 },
+	}
+	for name, modify := range tamper {
+		cmd := signedCommand(t, signer)
+		modify(cmd)
+		if err := v.Verify(cmd); err == nil {
+			t.Errorf("%s: tampered command verified", name)
+		}
+	}
+}
+
+func TestSignRequiresDestination(t *testing.T) {
+	signer := NewHMACSigner("k1", []byte("secret"))
+	for _, cmd := range []*Command{
+		{Command: "stop"},
+		{Command: "stop", Scope: ScopeNode},
+		{Command: "stop", Scope: ScopeInstance},
+	} {
+		if err := signer.Sign(cmd); err == nil {
+			t.Errorf("command without destination signed: scope=%q target=%q", cmd.Scope, cmd.Target)
+		}
+	}
+	for _, cmd := range []*Command{
+		{Command: "stop", Scope: ScopeNode, Target: "node"},
+		{Command: "stop", Scope: ScopeBroadcast, Namespace: "tenant-a"},
+		{Command: "stop", Scope: ScopeGroup, Selector: "env=prod"},
+	} {
+		if err := signer.Sign(cmd); err != nil {
+			t.Errorf("sign scope=%q: %v", cmd.Scope, err)
+		}
+	}
+}
+
+func TestVerifyBindsCallerToKey(t *testing.T) {
+	v := NewCommandVerifier()
+	v.AddHMACKey("k1", []byte("secret"))
+	signer := NewHMACSigner("k1", []byte("secret"))
+	sign := func(caller string) *Command {
+		cmd := &Command{Command: "status", Scope: ScopeBroadcast, Caller: caller}
+		if err := signer.Sign(cmd); err != nil {
+			t.Fatal(err)
+		}
+		return cmd
+	}
+
+	// 没有绑定时只能使用 key_id 作为身份
+	for caller, ok := range map[string]bool{"": true, "k1": true, "engine": false} {
+		if err := v.Verify(sign(caller)); (err == nil) != ok {
+			t.Errorf("unbound key, caller %q: err = %v", caller, err)
+		}
+	}
+
+	v.BindCallers("k1", "engine")
+	if err := v.Verify(sign("engine")); err != nil {
+		t.Fatalf("bound caller: %v", err)
+	}
+	if err := v.Verify(sign("admin")); err == nil {
+		t.Fatal("caller not bound to the key verified")
+	}
+}
+
+func TestVerifyRejectsReplay(t *testing.T) {
+	v := NewCommandVerifier()
+	v.AddHMACKey("k1", []byte("secret"))
+	signer := NewHMACSigner("k1", []byte("secret"))
+
+	cmd := signedCommand(t, signer)
+	if err := v.Verify(cmd); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err := v.Verify(cmd); !errors.Is(err, ErrCommandReplay) {
+		t.Fatalf("replay error = %v, want %v", err, ErrCommandReplay)
+	}
+
+	// 重新签名生成新的 nonce
+	if err := v.Verify(signedCommand(t, signer)); err != nil {
+		t.Fatalf("verify re-signed command: %v", err)
+	}
+}
+
+func TestVerifyRejectsReplayAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.json.nonces")
+	newVerifier := func() *CommandVerifier {
+		v := NewCommandVerifier()
+		v.AddHMACKey("k1", []byte("secret"))
+		if err := v.SetNonceStore(path); err != nil {
+			t.Fatalf("set nonce store: %v", err)
+		}
+		return v
+	}
+
+	v := newVerifier()
+	cmd := signedCommand(t, NewHMACSigner("k1", []byte("secret")))
+	if err := v.Verify(cmd); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	// 写入在后台延迟合并执行，停止接收器时同步写入
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("nonce store written synchronously: %v", err)
+	}
+	v.flush()
+
+	// 重启后截获的命令仍在重放窗口内，但 nonce 已使用过
+	if err := newVerifier().Verify(cmd); !errors.Is(err, ErrCommandReplay) {
+		t.Fatalf("replay after restart error = %v, want %v", err, ErrCommandReplay)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewCommandVerifier().SetNonceStore(path); err == nil {
+		t.Fatal("corrupt nonce store loaded without error")
+	}
+}
+
+func TestVerifyRejectsStaleTimestamp(t *testing.T) {
+	v := NewCommandVerifier()
+	v.AddHMACKey("k1", []byte("secret"))
+	v.SetReplayWindow(time.Minute)
+	signer := NewHMACSigner("k1", []byte("secret"))
+
+	for _, offset := range []time.Duration{-2 * time.Minute, 2 * time.Minute} {
+		cmd := &Command{Command: "status", Scope: ScopeBroadcast, Timestamp: time.Now().Add(offset).Format(time.RFC3339)}
+		if err := signer.Sign(cmd); err != nil {
+			t.Fatal(err)
+		}
+		if err := v.Verify(cmd); err == nil {
+			t.Errorf("command issued %s from now verified", offset)
+		}
+	}
+
+	// 伪造的命令不登记 nonce
+	cmd := signedCommand(t, NewHMACSigner("k1", []byte("wrong")))
+	v.Verify(cmd)
+	if len(v.nonces) != 0 {
+		t.Fatalf("%d nonces recorded for rejected commands", len(v.nonces))
+	}
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	v := NewCommandVerifier()
+	v.AddHMACKey("old", []byte("old-secret"))
+	v.AddHMACKey("new", []byte("new-secret"))
+
+	oldCmd := signedCommand(t, NewHMACSigner("old", []byte("old-secret")))
+	if err := v.Verify(signedCommand(t, NewHMACSigner("new", []byte("new-secret")))); err != nil {
+		t.Fatalf("new key: %v", err)
+	}
+	if err := v.Verify(oldCmd); err != nil {
+		t.Fatalf("old key during rotation: %v", err)
+	}
+
+	v.RemoveKey("old")
+	if err := v.Verify(signedCommand(t, NewHMACSigner("old", []byte("old-secret")))); err == nil {
+		t.Fatal("removed key still verifies")
+	}
+}
+
+func TestLoadEd25519PublicKeyFile(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"key.pem": pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+		"key.b64": []byte(base64.StdEncoding.EncodeToString(pub) + "\n"),
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadEd25519PublicKeyFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !loaded.Equal(pub) {
+			t.Fatalf("%s: loaded key differs", name)
+		}
+	}
+
+	short := filepath.Join(dir, "short.b64")
+	os.WriteFile(short, []byte(base64.StdEncoding.EncodeToString(pub[:16])), 0644)
+	if _, err := LoadEd25519PublicKeyFile(short); err == nil {
+		t.Fatal("truncated key loaded")
+	}
+}
+
+func TestProcessRequiresValidSignature(t *testing.T) {
+	var calls, audits atomic.Int32
+	verifier := NewCommandVerifier()
+	verifier.AddHMACKey("k1", []byte("secret"))
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.SetVerifier(verifier)
+		r.SetAuditHandler(func(AuditRecord) { audits.Add(1) })
+		r.RegisterHandler("echo", echoHandler("echo", &calls))
+	})
+
+	h.expectCode(&Command{Command: "echo", RequestID: "unsigned"}, ErrCodeUnauthorized)
+
+	forged := &Command{Command: "echo", RequestID: "forged", Scope: ScopeInstance, Target: "node-1"}
+	if err := NewHMACSigner("k1", []byte("wrong")).Sign(forged); err != nil {
+		t.Fatal(err)
+	}
+	h.expectCode(forged, ErrCodeUnauthorized)
+
+	signed := &Command{Command: "echo", RequestID: "signed", Scope: ScopeInstance, Target: "node-1"}
+	if err := NewHMACSigner("k1", []byte("secret")).Sign(signed); err != nil {
+		t.Fatal(err)
+	}
+	h.expectCode(signed, "")
+
+	if calls.Load() != 1 {
+		t.Fatalf("handler called %d times, want 1", calls.Load())
+	}
+	if audits.Load() != 2 {
+		t.Fatalf("%d audit records, want 2", audits.Load())
+	}
+}
+
+func TestProcessReplayedSignatureUsesIdempotency(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	signer := NewHMACSigner("k1", []byte("secret"))
+	verifier := NewCommandVerifier()
+	verifier.AddHMACKey("k1", []byte("secret"))
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.SetVerifier(verifier)
+		r.RegisterHandler("echo", echoHandler("echo", &calls))
+		r.RegisterHandler("slow", blockingHandler("slow", started, release))
+	})
+
+	// 已完成的命令被重复投递：重发缓存的应答，不再执行
+	cmd := &Command{Command: "echo", RequestID: "done", Scope: ScopeInstance, Target: "node-1"}
+	if err := signer.Sign(cmd); err != nil {
+		t.Fatal(err)
+	}
+	first := h.expectCode(cmd, "")
+	again := *cmd
+	h.submit(&again)
+	if replayed := h.reply(); !sameReply(replayed, first) {
+		t.Fatalf("replayed reply = %+v, want cached %+v", replayed, first)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("handler called %d times, want 1", calls.Load())
+	}
+
+	// 仍在执行的命令被重复投递：静默忽略
+	slow := &Command{Command: "slow", RequestID: "pending", Scope: ScopeInstance, Target: "node-1"}
+	if err := signer.Sign(slow); err != nil {
+		t.Fatal(err)
+	}
+	h.submit(slow)
+	waitStarted(t, started, "pending")
+	slowAgain := *slow
+	h.submit(&slowAgain)
+	h.noReply()
+	close(release)
+	h.expectReply("pending", "")
+	h.noReply()
+}
+
+func TestProcessReplayWithoutIdempotencyIsRejected(t *testing.T) {
+	var calls atomic.Int32
+	verifier := NewCommandVerifier()
+	verifier.AddHMACKey("k1", []byte("secret"))
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.SetVerifier(verifier)
+		r.SetIdempotency(nil)
+		r.RegisterHandler("echo", echoHandler("echo", &calls))
+	})
+
+	cmd := &Command{Command: "echo", RequestID: "r1", Scope: ScopeInstance, Target: "node-1"}
+	if err := NewHMACSigner("k1", []byte("secret")).Sign(cmd); err != nil {
+		t.Fatal(err)
+	}
+	h.expectCode(cmd, "")
+	again := *cmd
+	reply := h.expectCode(&again, ErrCodeUnauthorized)
+	if !strings.Contains(reply.Message, ErrCommandReplay.Error()) {
+		t.Fatalf("reply message = %q, want replay error", reply.Message)
+	}
+}
+
+func TestControlTopicVerifiesLargeIntegers(t *testing.T) {
+	var calls atomic.Int32
+	verifier := NewCommandVerifier()
+	verifier.AddHMACKey("k1", []byte("secret"))
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		r.SetVerifier(verifier)
+		r.RegisterHandler("echo", echoHandler("echo", &calls))
+	})
+	topics := protocol.DefaultTopicScheme()
+	replies := make(chan *CommandReply, 2)
+	engine := transport.NewMemoryTransport(h.broker)
+	if err := engine.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Disconnect()
+	engine.Subscribe(topics.Reply("node", "node-1"), 1, func(msg *transport.Message) {
+		env, _, err := protocol.Decode(msg.Payload)
+		if err != nil {
+			t.Errorf("decode reply: %v", err)
+			return
+		}
+		var reply CommandReply
+		env.DecodeBody(&reply)
+		replies <- &reply
+	})
+
+	// 超过 2^53 的整数转换为 float64 后与签名原文不一致
+	cmd := &Command{Command: "echo", RequestID: "big", Scope: ScopeInstance, Target: "node-1",
+		Parameters: map[string]interface{}{"offset": uint64(1<<53 + 1)}}
+	if err := NewHMACSigner("k1", []byte("secret")).Sign(cmd); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := protocol.Encode(codec.JSON, protocol.NewEnvelope(protocol.TypeCommand, "engine", cmd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.Publish(topics.InstanceControl("node", "node-1"), 1, false, payload)
+	select {
+	case reply := <-replies:
+		if !reply.Success {
+			t.Fatalf("reply = %+v, want success", reply)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("no reply received")
+	}
+}
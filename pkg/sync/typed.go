@@ -0,0 +1,414 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/sync/typed.go
+ * 类型化命令 - 将命令参数解码为结构体并校验
+ *
+ * 参数结构体通过 json 标签映射参数名，通过 validate 标签声明校验规则：
+ *
+ *	type ConfigUpdateParams struct {
+ *	    Key    string `json:"key" validate:"required,max=64" desc:"配置项"`
+ *	    Format string `json:"format" validate:"oneof=json yaml"`
+ *	    Retry  int    `json:"retry" validate:"min=0,max=5"`
+ *	}
+ *
+ * 支持的规则：
+ * - required: 参数必须提供
+ * - min/max: 数值的取值范围，字符串、数组、map 的长度范围
+ * - oneof: 取值必须是空格分隔的候选值之一
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FieldError 参数字段校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError 参数校验错误
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+// Error 实现error接口
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+	return "invalid parameters: " + strings.Join(msgs, "; ")
+}
+
+// JSONSchema 参数的 JSON Schema 描述
+type JSONSchema struct {
+	Type        string                 `json:"type,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Properties  map[string]*JSONSchema `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+	Items       *JSONSchema            `json:"items,omitempty"`
+	Enum        []string               `json:"enum,omitempty"`
+	Minimum     *float64               `json:"minimum,omitempty"`
+	Maximum     *float64               `json:"maximum,omitempty"`
+	MinLength   *int                   `json:"minLength,omitempty"`
+	MaxLength   *int                   `json:"maxLength,omitempty"`
+	MinItems    *int                   `json:"minItems,omitempty"`
+	MaxItems    *int                   `json:"maxItems,omitempty"`
+}
+
+// TypedHandlerFunc 类型化命令处理函数
+type TypedHandlerFunc[T any, R any] func(ctx context.Context, params T) (R, error)
+
+// typedHandler 类型化命令处理器
+type typedHandler[T any, R any] struct {
+	name   string
+	fn     TypedHandlerFunc[T, R]
+	schema *JSONSchema
+}
+
+// RegisterTyped 注册类型化命令处理器
+//
+// 命令参数解码为 T 并按 validate 标签校验，校验失败时返回 invalid_params 结果，
+// Data 为 []FieldError。处理函数的返回值作为结果的 Data。
+func RegisterTyped[T any, R any](r *CommandReceiver, command string, fn TypedHandlerFunc[T, R], opts ...HandlerOption) error {
+	handler := &typedHandler[T, R]{
+		name:   command,
+		fn:     fn,
+		schema: SchemaOf[T](),
+	}
+	return r.RegisterHandler(command, handler, opts...)
+}
+
+// Handle 实现 CommandHandler 接口
+func (h *typedHandler[T, R]) Handle(ctx context.Context, cmd *Command) (*CommandResult, error) {
+	var params T
+	if err := DecodeParameters(cmd.Parameters, &params); err != nil {
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			result := NewErrorResult(cmd.RequestID, ErrCodeInvalidParams, verr.Error())
+			result.Data = verr.Fields
+			return result, nil
+		}
+		return NewErrorResult(cmd.RequestID, ErrCodeInvalidParams, err.Error()), nil
+	}
+
+	data, err := h.fn(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return NewSuccessResult(cmd.RequestID, "ok", data), nil
+}
+
+// GetCommandName 实现 CommandHandler 接口
+func (h *typedHandler[T, R]) GetCommandName() string {
+	return h.name
+}
+
+// ParamSchema 返回参数的 JSON Schema
+func (h *typedHandler[T, R]) ParamSchema() *JSONSchema {
+	return h.schema
+}
+
+// DecodeParameters 将命令参数解码到 out 指向的结构体并校验
+// 校验失败时返回 *ValidationError
+func DecodeParameters(params map[string]interface{}, out interface{}) error {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("marshal parameters: %w", err)
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return &ValidationError{Fields: []FieldError{{
+				Field:   typeErr.Field,
+				Rule:    "type",
+				Message: fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value),
+			}}}
+		}
+		return fmt.Errorf("decode parameters: %w", err)
+	}
+
+	v := reflect.ValueOf(out)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []FieldError
+	validateStruct(v, params, "", &fields)
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// validateStruct 按 validate 标签校验结构体字段
+func validateStruct(v reflect.Value, raw map[string]interface{}, prefix string, errs *[]FieldError) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		// 未导出的嵌入结构体与 encoding/json 一致，展开其导出字段
+		if !sf.IsExported() && !sf.Anonymous {
+			continue
+		}
+		name, ok := jsonFieldName(sf)
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+
+		// 未指定名称的嵌入结构体，字段展开到当前层级
+		if sf.Anonymous && name == "" {
+			if fv.Kind() == reflect.Struct {
+				validateStruct(fv, raw, prefix, errs)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		rawValue, present := raw[name]
+		present = present && rawValue != nil
+
+		for _, rule := range strings.Split(sf.Tag.Get("validate"), ",") {
+			rule = strings.TrimSpace(rule)
+			if rule == "" {
+				continue
+			}
+			if rule == "required" {
+				if !present {
+					*errs = append(*errs, FieldError{Field: path, Rule: "required", Message: "is required"})
+				}
+				continue
+			}
+			if !present {
+				continue
+			}
+			if msg := checkRule(fv, rule); msg != "" {
+				ruleName, _, _ := strings.Cut(rule, "=")
+				*errs = append(*errs, FieldError{Field: path, Rule: ruleName, Message: msg})
+			}
+		}
+
+		// 递归校验嵌套结构体
+		nested, isMap := rawValue.(map[string]interface{})
+		for fv.Kind() == reflect.Pointer && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if present && isMap && fv.Kind() == reflect.Struct {
+			validateStruct(fv, nested, path, errs)
+		}
+	}
+}
+
+// checkRule 检查单条规则，通过时返回空字符串
+func checkRule(v reflect.Value, rule string) string {
+	name, arg, _ := strings.Cut(rule, "=")
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Sprintf("invalid rule %q", rule)
+		}
+		actual, isLength, ok := measure(v)
+		if !ok {
+			return ""
+		}
+		if name == "min" && actual < limit {
+			if isLength {
+				return fmt.Sprintf("length must be at least %s", arg)
+			}
+			return fmt.Sprintf("must be at least %s", arg)
+		}
+		if name == "max" && actual > limit {
+			if isLength {
+				return fmt.Sprintf("length must be at most %s", arg)
+			}
+			return fmt.Sprintf("must be at most %s", arg)
+		}
+	case "oneof":
+		options := strings.Fields(arg)
+		actual := fmt.Sprint(v.Interface())
+		if !containsString(options, actual) {
+			return fmt.Sprintf("must be one of [%s]", strings.Join(options, ", "))
+		}
+	}
+	return ""
+}
+
+// measure 返回数值或长度，isLength 表示返回的是长度
+func measure(v reflect.Value) (value float64, isLength bool, ok bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true, true
+	}
+	return 0, false, false
+}
+
+// jsonFieldName 返回字段的参数名，ok 为 false 表示字段被忽略
+func jsonFieldName(sf reflect.StructField) (string, bool) {
+	tag := sf.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" && !sf.Anonymous {
+		name = sf.Name
+	}
+	return name, true
+}
+
+// SchemaOf 根据参数类型生成 JSON Schema
+func SchemaOf[T any]() *JSONSchema {
+	var zero T
+	return schemaOfType(reflect.TypeOf(&zero).Elem(), make(map[reflect.Type]bool))
+}
+
+// schemaOfType 生成类型的 JSON Schema
+// stack 记录正在展开的结构体类型，自引用的类型（如树节点的子节点）再次出现时只生成 object，不展开字段
+func schemaOfType(t reflect.Type, stack map[reflect.Type]bool) *JSONSchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: schemaOfType(t.Elem(), stack)}
+	case reflect.Map:
+		return &JSONSchema{Type: "object"}
+	case reflect.Struct:
+		if stack[t] {
+			return &JSONSchema{Type: "object"}
+		}
+		stack[t] = true
+		defer delete(stack, t)
+		schema := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+		addStructProperties(schema, t, stack)
+		return schema
+	}
+	return &JSONSchema{}
+}
+
+// addStructProperties 将结构体字段添加到 Schema
+func addStructProperties(schema *JSONSchema, t reflect.Type, stack map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		// 未导出的嵌入结构体与 encoding/json 一致，展开其导出字段
+		if !sf.IsExported() && !sf.Anonymous {
+			continue
+		}
+		name, ok := jsonFieldName(sf)
+		if !ok {
+			continue
+		}
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			// 嵌入自身（如 *Node 嵌入 Node）时不再展开
+			if ft.Kind() == reflect.Struct && !stack[ft] {
+				stack[ft] = true
+				addStructProperties(schema, ft, stack)
+				delete(stack, ft)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		prop := schemaOfType(sf.Type, stack)
+		prop.Description = sf.Tag.Get("desc")
+		for _, rule := range strings.Split(sf.Tag.Get("validate"), ",") {
+			ruleName, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+			switch ruleName {
+			case "required":
+				schema.Required = append(schema.Required, name)
+			case "min", "max":
+				applyBound(prop, ruleName, arg)
+			case "oneof":
+				prop.Enum = strings.Fields(arg)
+			}
+		}
+		schema.Properties[name] = prop
+	}
+}
+
+// applyBound 将 min/max 规则转换为对应的 Schema 约束
+func applyBound(prop *JSONSchema, rule, arg string) {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return
+	}
+	n := int(limit)
+	switch prop.Type {
+	case "integer", "number":
+		if rule == "min" {
+			prop.Minimum = &limit
+		} else {
+			prop.Maximum = &limit
+		}
+	case "string":
+		if rule == "min" {
+			prop.MinLength = &n
+		} else {
+			prop.MaxLength = &n
+		}
+	case "array":
+		if rule == "min" {
+			prop.MinItems = &n
+		} else {
+			prop.MaxItems = &n
+		}
+	}
+}
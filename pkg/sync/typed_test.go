@@ -0,0 +1,165 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type retryPolicy struct {
+	Attempts int `json:"attempts" validate:"required,min=1,max=5"`
+}
+
+type commonParams struct {
+	Verbose bool `json:"verbose"`
+}
+
+type configUpdateParams struct {
+	commonParams
+	Key     string       `json:"key" validate:"required,max=8" desc:"配置项"`
+	Format  string       `json:"format" validate:"oneof=json yaml"`
+	Tags    []string     `json:"tags" validate:"min=1"`
+	Retry   *retryPolicy `json:"retry"`
+	Ignored string       `json:"-" validate:"required"`
+	secret  string
+}
+
+func TestDecodeParametersValidates(t *testing.T) {
+	tests := []struct {
+		name   string
+		params map[string]interface{}
+		want   []FieldError // 为空表示校验通过
+	}{
+		{"valid", map[string]interface{}{"key": "mode", "format": "yaml", "tags": []interface{}{"a"},
+			"retry": map[string]interface{}{"attempts": 3}, "verbose": true}, nil},
+		{"missing required", map[string]interface{}{}, []FieldError{{Field: "key", Rule: "required"}}},
+		{"null is missing", map[string]interface{}{"key": nil}, []FieldError{{Field: "key", Rule: "required"}}},
+		{"string too long", map[string]interface{}{"key": "much-too-long"}, []FieldError{{Field: "key", Rule: "max"}}},
+		{"not one of", map[string]interface{}{"key": "k", "format": "xml"}, []FieldError{{Field: "format", Rule: "oneof"}}},
+		{"too few items", map[string]interface{}{"key": "k", "tags": []interface{}{}}, []FieldError{{Field: "tags", Rule: "min"}}},
+		{"nested", map[string]interface{}{"key": "k", "retry": map[string]interface{}{"attempts": 9}},
+			[]FieldError{{Field: "retry.attempts", Rule: "max"}}},
+		{"nested required", map[string]interface{}{"key": "k", "retry": map[string]interface{}{}},
+			[]FieldError{{Field: "retry.attempts", Rule: "required"}}},
+		{"wrong type", map[string]interface{}{"key": 5}, []FieldError{{Field: "key", Rule: "type"}}},
+		{"several", map[string]interface{}{"format": "xml"},
+			[]FieldError{{Field: "key", Rule: "required"}, {Field: "format", Rule: "oneof"}}},
+	}
+	for _, tt := range tests {
+		var params configUpdateParams
+		err := DecodeParameters(tt.params, &params)
+		if tt.want == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tt.name, err)
+			}
+			continue
+		}
+		var verr *ValidationError
+		if !errors.As(err, &verr) || len(verr.Fields) != len(tt.want) {
+			t.Errorf("%s: error = %v, want fields %+v", tt.name, err, tt.want)
+			continue
+		}
+		for i, want := range tt.want {
+			if got := verr.Fields[i]; got.Field != want.Field || got.Rule != want.Rule || got.Message == "" {
+				t.Errorf("%s: field %d = %+v, want %s/%s", tt.name, i, got, want.Field, want.Rule)
+			}
+		}
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema := SchemaOf[configUpdateParams]()
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"object","properties":{` +
+		`"format":{"type":"string","enum":["json","yaml"]},` +
+		`"key":{"type":"string","description":"配置项","maxLength":8},` +
+		`"retry":{"type":"object","properties":{"attempts":{"type":"integer","minimum":1,"maximum":5}},"required":["attempts"]},` +
+		`"tags":{"type":"array","items":{"type":"string"},"minItems":1},` +
+		`"verbose":{"type":"boolean"}},` +
+		`"required":["key"]}`
+	if string(data) != want {
+		t.Fatalf("schema =\n%s\nwant\n%s", data, want)
+	}
+}
+
+// treeParams 自引用的参数类型
+type treeParams struct {
+	Name     string        `json:"name"`
+	Children []treeParams  `json:"children"`
+	Parent   *treeParams   `json:"parent"`
+	Linked   *linkedParams `json:"linked"`
+}
+
+// linkedParams 通过嵌入指向自身
+type linkedParams struct {
+	*linkedParams
+	Value int `json:"value"`
+}
+
+func TestSchemaOfRecursiveType(t *testing.T) {
+	data, err := json.Marshal(SchemaOf[treeParams]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"object","properties":{` +
+		`"children":{"type":"array","items":{"type":"object"}},` +
+		`"linked":{"type":"object","properties":{"value":{"type":"integer"}}},` +
+		`"name":{"type":"string"},` +
+		`"parent":{"type":"object"}}}`
+	if string(data) != want {
+		t.Fatalf("schema =\n%s\nwant\n%s", data, want)
+	}
+
+	var tree treeParams
+	params := map[string]interface{}{
+		"name":     "root",
+		"children": []interface{}{map[string]interface{}{"name": "leaf"}},
+		"parent":   map[string]interface{}{"name": "up"},
+	}
+	if err := DecodeParameters(params, &tree); err != nil || len(tree.Children) != 1 || tree.Parent.Name != "up" {
+		t.Fatalf("DecodeParameters = %+v, %v", tree, err)
+	}
+}
+
+func TestRegisterTypedRepliesWithFieldErrors(t *testing.T) {
+	h := newReceiverHarness(t, func(r *CommandReceiver) {
+		RegisterTyped(r, "config.update", func(ctx context.Context, p configUpdateParams) (map[string]string, error) {
+			return map[string]string{"key": p.Key, "format": p.Format}, nil
+		})
+	})
+
+	reply := h.expectCode(&Command{Command: "config.update", RequestID: "r1",
+		Parameters: map[string]interface{}{"key": "mode", "format": "json"}}, "")
+	if data, ok := reply.Data.(map[string]string); !ok || data["key"] != "mode" || data["format"] != "json" {
+		t.Fatalf("reply data = %#v", reply.Data)
+	}
+
+	reply = h.expectCode(&Command{Command: "config.update", RequestID: "r2"}, ErrCodeInvalidParams)
+	if fields, ok := reply.Data.([]FieldError); !ok || len(fields) != 1 || fields[0].Field != "key" {
+		t.Fatalf("reply data = %#v, want field errors", reply.Data)
+	}
+
+	// help 命令返回生成的参数 Schema
+	info := h.expectCode(&Command{Command: "help", RequestID: "r3",
+		Parameters: map[string]interface{}{"command": "config.update"}}, "")
+	if handler, ok := info.Data.(CommandInfo); !ok || handler.ParamSchema == nil || handler.ParamSchema.Properties["key"] == nil {
+		t.Fatalf("help data = %#v", info.Data)
+	}
+}
+
+func TestDecodeParametersSkipsNamedUnexportedEmbedding(t *testing.T) {
+	type params struct {
+		retryPolicy `json:"policy"`
+		Name        string `json:"name" validate:"oneof=a b"`
+	}
+	if err := DecodeParameters(map[string]interface{}{"name": "a", "policy": map[string]interface{}{}}, &params{}); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if schema := SchemaOf[params](); len(schema.Properties) != 1 || schema.Properties["name"] == nil {
+		t.Fatalf("schema properties = %v", schema.Properties)
+	}
+}
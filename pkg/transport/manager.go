@@ -0,0 +1,365 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/transport/manager.go
+ * 连接管理器 - 多个组件通过会话共用同一个 broker 连接
+ *
+ * 每个会话实现 Transport 接口，组件无需感知连接是否共享：
+ *   - 同一主题过滤器可被多个会话订阅，底层只订阅一次，收到的消息分发给每个会话
+ *   - 订阅由底层传输层在重连后恢复，OnConnect/OnConnectionLost 分发给所有已连接的会话
+ *   - 第一个会话 Connect 时建立底层连接，最后一个会话 Disconnect 后断开
+ *   - 一个连接只有一个遗嘱，底层连接建立后会话设置的遗嘱被忽略
+ *   - 离线时取消的订阅在重连后补发 UNSUBSCRIBE，持久会话在 broker 上保留的订阅随之移除
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package transport
+
+import (
+	"sync"
+)
+
+// ConnectionManager 共享连接管理器
+type ConnectionManager struct {
+	transport Transport
+
+	// connMu 串行化底层连接的建立和断开
+	connMu  sync.Mutex
+	started bool // 底层连接已建立（可能正在自动重连）
+
+	mu     sync.Mutex
+	up     bool // 已向会话分发 OnConnect，连接丢失后重置
+	active map[*Session]struct{}
+	subs   map[string]map[*Session]subscription
+	// 离线时取消的过滤器，重连后向 broker 补发取消订阅
+	pendingUnsub map[string]struct{}
+}
+
+// NewConnectionManager 创建管理指定传输层的连接管理器
+// 传输层应专用于该管理器，其 OnConnect/OnConnectionLost 回调由管理器设置
+func NewConnectionManager(t Transport) *ConnectionManager {
+	m := &ConnectionManager{
+		transport:    t,
+		active:       make(map[*Session]struct{}),
+		subs:         make(map[string]map[*Session]subscription),
+		pendingUnsub: make(map[string]struct{}),
+	}
+	t.OnConnect(m.handleConnect)
+	t.OnConnectionLost(m.handleConnectionLost)
+	return m
+}
+
+// NewSession 创建共用该连接的会话
+func (m *ConnectionManager) NewSession() *Session {
+	return &Session{manager: m}
+}
+
+// IsConnected 返回底层连接是否已连接
+func (m *ConnectionManager) IsConnected() bool {
+	return m.transport.IsConnected()
+}
+
+// Transport 返回底层传输层
+func (m *ConnectionManager) Transport() Transport {
+	return m.transport
+}
+
+// connect 将会话加入连接，底层尚未连接时建立连接
+func (m *ConnectionManager) connect(s *Session) error {
+	m.connMu.Lock()
+
+	m.mu.Lock()
+	if _, ok := m.active[s]; ok {
+		m.mu.Unlock()
+		m.connMu.Unlock()
+		return nil
+	}
+	m.active[s] = struct{}{}
+	up := m.up
+	m.mu.Unlock()
+
+	// 连接已可用，会话错过了分发，直接通知
+	if up {
+		m.connMu.Unlock()
+		s.notifyConnect()
+		return nil
+	}
+	defer m.connMu.Unlock()
+
+	// 底层连接已建立但 OnConnect 尚未分发（或正在重连），分发时包含该会话
+	if m.started {
+		return nil
+	}
+
+	if err := m.transport.Connect(); err != nil {
+		m.mu.Lock()
+		delete(m.active, s)
+		m.mu.Unlock()
+		return err
+	}
+	m.started = true
+	return nil
+}
+
+// disconnect 将会话移出连接，没有会话时断开底层连接
+func (m *ConnectionManager) disconnect(s *Session) {
+	m.connMu.Lock()
+	defer m.connMu.Unlock()
+
+	m.mu.Lock()
+	if _, ok := m.active[s]; !ok {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.active, s)
+	last := len(m.active) == 0
+	if last {
+		m.up = false
+	}
+	m.mu.Unlock()
+
+	if last && m.started {
+		m.transport.Disconnect()
+		m.started = false
+	}
+}
+
+// isActive 返回会话是否已连接
+func (m *ConnectionManager) isActive(s *Session) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.active[s]
+	return ok
+}
+
+// setWill 底层连接建立前设置遗嘱
+func (m *ConnectionManager) setWill(msg *Message) {
+	m.connMu.Lock()
+	defer m.connMu.Unlock()
+
+	if m.started {
+		return
+	}
+	if ws, ok := m.transport.(WillSetter); ok {
+		ws.SetWill(msg)
+	}
+}
+
+// subscribe 记录会话的订阅，过滤器首次订阅或 QoS 提高时订阅底层连接
+func (m *ConnectionManager) subscribe(s *Session, topic string, qos byte, handler MessageHandler) error {
+	m.mu.Lock()
+	sessions, exists := m.subs[topic]
+	if !exists {
+		sessions = make(map[*Session]subscription)
+		m.subs[topic] = sessions
+	}
+	prevQoS := maxQoS(sessions)
+	sessions[s] = subscription{qos: qos, handler: handler}
+	delete(m.pendingUnsub, topic)
+	m.mu.Unlock()
+
+	if exists && qos <= prevQoS {
+		return nil
+	}
+	return m.transport.Subscribe(topic, qos, func(msg *Message) {
+		m.dispatch(topic, msg)
+	})
+}
+
+// unsubscribe 移除会话的订阅，过滤器没有会话订阅时取消底层订阅
+// 离线时底层传输层只移除重连后恢复的订阅，过滤器记录下来在重连后向 broker 取消
+func (m *ConnectionManager) unsubscribe(s *Session, topics ...string) error {
+	var unused []string
+	m.mu.Lock()
+	for _, topic := range topics {
+		sessions, ok := m.subs[topic]
+		if !ok {
+			continue
+		}
+		delete(sessions, s)
+		if len(sessions) == 0 {
+			delete(m.subs, topic)
+			unused = append(unused, topic)
+			if !m.up {
+				m.pendingUnsub[topic] = struct{}{}
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	if len(unused) == 0 {
+		return nil
+	}
+	return m.transport.Unsubscribe(unused...)
+}
+
+// dispatch 将消息分发给订阅了该过滤器的已连接会话
+func (m *ConnectionManager) dispatch(topic string, msg *Message) {
+	m.mu.Lock()
+	handlers := make([]MessageHandler, 0, len(m.subs[topic]))
+	for s, sub := range m.subs[topic] {
+		if _, ok := m.active[s]; ok {
+			handlers = append(handlers, sub.handler)
+		}
+	}
+	m.mu.Unlock()
+
+	for i, handler := range handlers {
+		// 每个会话拿到独立的消息副本，避免修改影响其他会话
+		if i == len(handlers)-1 {
+			handler(msg)
+			continue
+		}
+		copied := *msg
+		handler(&copied)
+	}
+}
+
+// handleConnect 底层连接成功（含重连）时补发离线期间的取消订阅，并通知所有已连接的会话
+func (m *ConnectionManager) handleConnect() {
+	m.mu.Lock()
+	m.up = true
+	sessions := m.activeSessions()
+	pending := make([]string, 0, len(m.pendingUnsub))
+	for topic := range m.pendingUnsub {
+		pending = append(pending, topic)
+	}
+	m.pendingUnsub = make(map[string]struct{})
+	m.mu.Unlock()
+
+	if len(pending) > 0 {
+		m.transport.Unsubscribe(pending...)
+	}
+
+	for _, s := range sessions {
+		s.notifyConnect()
+	}
+}
+
+// handleConnectionLost 底层连接丢失时通知所有已连接的会话
+func (m *ConnectionManager) handleConnectionLost(err error) {
+	m.mu.Lock()
+	m.up = false
+	sessions := m.activeSessions()
+	m.mu.Unlock()
+
+	for _, s := range sessions {
+		s.notifyConnectionLost(err)
+	}
+}
+
+// activeSessions 返回已连接的会话，调用方需持有 m.mu
+func (m *ConnectionManager) activeSessions() []*Session {
+	sessions := make([]*Session, 0, len(m.active))
+	for s := range m.active {
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+// maxQoS 返回订阅记录中最高的 QoS
+func maxQoS(sessions map[*Session]subscription) byte {
+	var qos byte
+	for _, sub := range sessions {
+		if sub.qos > qos {
+			qos = sub.qos
+		}
+	}
+	return qos
+}
+
+// Session 共享连接上的会话，实现 Transport、WillSetter 和 PropertyPublisher
+//
+// Connect 将会话加入共享连接，连接已可用时立即调用 OnConnect 回调；
+// Disconnect 只移出该会话，订阅记录保留，再次 Connect 后继续接收消息；
+// Unsubscribe 和 Disconnect 在离线时同样生效，组件停止时无需判断连接状态。
+// 订阅已被其他会话订阅的过滤器时，不会补发此前的保留消息。
+type Session struct {
+	manager *ConnectionManager
+
+	mu        sync.Mutex
+	onConnect func()
+	onLost    func(err error)
+}
+
+// Connect 加入共享连接，底层尚未连接时建立连接
+func (s *Session) Connect() error {
+	return s.manager.connect(s)
+}
+
+// Disconnect 离开共享连接，最后一个会话离开时断开底层连接
+func (s *Session) Disconnect() {
+	s.manager.disconnect(s)
+}
+
+// IsConnected 返回会话已加入且底层连接可用
+func (s *Session) IsConnected() bool {
+	return s.manager.isActive(s) && s.manager.transport.IsConnected()
+}
+
+// Publish 通过共享连接发布消息
+func (s *Session) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	if !s.manager.isActive(s) {
+		return ErrNotConnected
+	}
+	return s.manager.transport.Publish(topic, qos, retained, payload)
+}
+
+// PublishMessage 携带消息属性发布，底层传输层不支持属性时忽略属性
+func (s *Session) PublishMessage(msg *Message) error {
+	if !s.manager.isActive(s) {
+		return ErrNotConnected
+	}
+	if publisher, ok := s.manager.transport.(PropertyPublisher); ok {
+		return publisher.PublishMessage(msg)
+	}
+	return s.manager.transport.Publish(msg.Topic, msg.QoS, msg.Retained, msg.Payload)
+}
+
+// Subscribe 订阅主题，同一会话重复订阅时替换回调
+func (s *Session) Subscribe(topic string, qos byte, handler MessageHandler) error {
+	return s.manager.subscribe(s, topic, qos, handler)
+}
+
+// Unsubscribe 取消该会话的订阅
+func (s *Session) Unsubscribe(topics ...string) error {
+	return s.manager.unsubscribe(s, topics...)
+}
+
+// SetWill 设置共享连接的遗嘱，底层连接建立后调用时忽略
+func (s *Session) SetWill(msg *Message) {
+	s.manager.setWill(msg)
+}
+
+// OnConnect 设置连接成功回调
+func (s *Session) OnConnect(fn func()) {
+	s.mu.Lock()
+	s.onConnect = fn
+	s.mu.Unlock()
+}
+
+// OnConnectionLost 设置连接丢失回调
+func (s *Session) OnConnectionLost(fn func(err error)) {
+	s.mu.Lock()
+	s.onLost = fn
+	s.mu.Unlock()
+}
+
+func (s *Session) notifyConnect() {
+	s.mu.Lock()
+	fn := s.onConnect
+	s.mu.Unlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+func (s *Session) notifyConnectionLost(err error) {
+	s.mu.Lock()
+	fn := s.onLost
+	s.mu.Unlock()
+	if fn != nil {
+		fn(err)
+	}
+}
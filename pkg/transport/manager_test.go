@@ -0,0 +1,322 @@
+package transport
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingTransport 记录底层连接上的调用
+type countingTransport struct {
+	*MemoryTransport
+
+	mu           sync.Mutex
+	connects     int
+	disconnects  int
+	subscribes   []subscribeCall
+	unsubscribes [][]string
+}
+
+type subscribeCall struct {
+	topic string
+	qos   byte
+}
+
+func newCountingTransport(broker *MemoryBroker) *countingTransport {
+	return &countingTransport{MemoryTransport: NewMemoryTransport(broker)}
+}
+
+func (c *countingTransport) Connect() error {
+	c.mu.Lock()
+	c.connects++
+	c.mu.Unlock()
+	return c.MemoryTransport.Connect()
+}
+
+func (c *countingTransport) Disconnect() {
+	c.mu.Lock()
+	c.disconnects++
+	c.mu.Unlock()
+	c.MemoryTransport.Disconnect()
+}
+
+func (c *countingTransport) Subscribe(topic string, qos byte, handler MessageHandler) error {
+	c.mu.Lock()
+	c.subscribes = append(c.subscribes, subscribeCall{topic, qos})
+	c.mu.Unlock()
+	return c.MemoryTransport.Subscribe(topic, qos, handler)
+}
+
+func (c *countingTransport) Unsubscribe(topics ...string) error {
+	c.mu.Lock()
+	c.unsubscribes = append(c.unsubscribes, topics)
+	c.mu.Unlock()
+	return c.MemoryTransport.Unsubscribe(topics...)
+}
+
+func (c *countingTransport) counts() (connects, disconnects, subscribes, unsubscribes int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connects, c.disconnects, len(c.subscribes), len(c.unsubscribes)
+}
+
+// newPublisher 连接到同一 broker 的独立客户端
+func newPublisher(t *testing.T, broker *MemoryBroker) *MemoryTransport {
+	t.Helper()
+	p := NewMemoryTransport(broker)
+	if err := p.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Disconnect)
+	return p
+}
+
+func receiveInto(ch chan<- string) MessageHandler {
+	return func(msg *Message) { ch <- string(msg.Payload) }
+}
+
+func expectMessage(t *testing.T, ch <-chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		if got != want {
+			t.Fatalf("received %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("did not receive %q", want)
+	}
+}
+
+func expectNoMessage(t *testing.T, ch <-chan string) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		t.Fatalf("unexpected message %q", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestConnectionManagerSharesConnection(t *testing.T) {
+	ct := newCountingTransport(NewMemoryBroker())
+	m := NewConnectionManager(ct)
+	s1, s2 := m.NewSession(), m.NewSession()
+
+	connected := make(chan string, 4)
+	s1.OnConnect(func() { connected <- "s1" })
+	s2.OnConnect(func() { connected <- "s2" })
+
+	if err := s1.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	expectMessage(t, connected, "s1")
+	if err := s2.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	// 连接已可用，后加入的会话立即收到回调
+	expectMessage(t, connected, "s2")
+	if connects, _, _, _ := ct.counts(); connects != 1 {
+		t.Fatalf("underlying Connect called %d times, want 1", connects)
+	}
+
+	s1.Disconnect()
+	if s1.IsConnected() || !s2.IsConnected() || !ct.IsConnected() {
+		t.Fatalf("after s1 left: s1=%v s2=%v conn=%v", s1.IsConnected(), s2.IsConnected(), ct.IsConnected())
+	}
+	if err := s1.Publish("a", 0, false, nil); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("publish on left session = %v, want %v", err, ErrNotConnected)
+	}
+
+	s2.Disconnect()
+	if _, disconnects, _, _ := ct.counts(); disconnects != 1 || ct.IsConnected() {
+		t.Fatalf("underlying Disconnect called %d times, connected=%v", disconnects, ct.IsConnected())
+	}
+}
+
+func TestConnectionManagerMultiplexesSubscriptions(t *testing.T) {
+	broker := NewMemoryBroker()
+	ct := newCountingTransport(broker)
+	m := NewConnectionManager(ct)
+	s1, s2 := m.NewSession(), m.NewSession()
+	got1, got2 := make(chan string, 4), make(chan string, 4)
+
+	s1.Subscribe("line/+/temp", 1, func(msg *Message) {
+		msg.Payload = []byte("modified") // 不影响其他会话收到的消息
+		got1 <- msg.Topic
+	})
+	s2.Subscribe("line/+/temp", 1, receiveInto(got2))
+	if _, _, subscribes, _ := ct.counts(); subscribes != 1 {
+		t.Fatalf("underlying Subscribe called %d times, want 1", subscribes)
+	}
+	s1.Connect()
+	s2.Connect()
+
+	pub := newPublisher(t, broker)
+	pub.Publish("line/1/temp", 1, false, []byte("21"))
+	expectMessage(t, got1, "line/1/temp")
+	expectMessage(t, got2, "21")
+
+	// 取消一个会话的订阅不影响其他会话
+	s1.Unsubscribe("line/+/temp")
+	if _, _, _, unsubscribes := ct.counts(); unsubscribes != 0 {
+		t.Fatalf("underlying Unsubscribe called while another session subscribed")
+	}
+	pub.Publish("line/2/temp", 1, false, []byte("22"))
+	expectMessage(t, got2, "22")
+	expectNoMessage(t, got1)
+
+	s2.Unsubscribe("line/+/temp")
+	ct.mu.Lock()
+	unsubscribes := ct.unsubscribes
+	ct.mu.Unlock()
+	if len(unsubscribes) != 1 || len(unsubscribes[0]) != 1 || unsubscribes[0][0] != "line/+/temp" {
+		t.Fatalf("underlying unsubscribes = %v", unsubscribes)
+	}
+}
+
+func TestConnectionManagerUpgradesSubscriptionQoS(t *testing.T) {
+	ct := newCountingTransport(NewMemoryBroker())
+	m := NewConnectionManager(ct)
+	s1, s2, s3 := m.NewSession(), m.NewSession(), m.NewSession()
+
+	s1.Subscribe("cmd/#", 0, func(*Message) {})
+	s2.Subscribe("cmd/#", 1, func(*Message) {})
+	s3.Subscribe("cmd/#", 1, func(*Message) {})
+
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	want := []subscribeCall{{"cmd/#", 0}, {"cmd/#", 1}}
+	if len(ct.subscribes) != len(want) || ct.subscribes[0] != want[0] || ct.subscribes[1] != want[1] {
+		t.Fatalf("underlying subscribes = %v, want %v", ct.subscribes, want)
+	}
+}
+
+func TestConnectionManagerSkipsLeftSessions(t *testing.T) {
+	broker := NewMemoryBroker()
+	m := NewConnectionManager(NewMemoryTransport(broker))
+	s1, s2 := m.NewSession(), m.NewSession()
+	got1, got2 := make(chan string, 4), make(chan string, 4)
+	s1.Subscribe("status", 1, receiveInto(got1))
+	s2.Subscribe("status", 1, receiveInto(got2))
+	s1.Connect()
+	s2.Connect()
+	pub := newPublisher(t, broker)
+
+	// 离开的会话保留订阅记录，但不再收到消息
+	s1.Disconnect()
+	pub.Publish("status", 1, false, []byte("a"))
+	expectMessage(t, got2, "a")
+	expectNoMessage(t, got1)
+
+	// 再次加入后继续接收
+	s1.Connect()
+	pub.Publish("status", 1, false, []byte("b"))
+	expectMessage(t, got1, "b")
+	expectMessage(t, got2, "b")
+}
+
+func TestConnectionManagerFansOutConnectionEvents(t *testing.T) {
+	broker := NewMemoryBroker()
+	mt := NewMemoryTransport(broker)
+	m := NewConnectionManager(mt)
+	s1, s2 := m.NewSession(), m.NewSession()
+	events := make(chan string, 8)
+	for name, s := range map[string]*Session{"s1": s1, "s2": s2} {
+		name := name
+		s.OnConnect(func() { events <- name + " up" })
+		s.OnConnectionLost(func(error) { events <- name + " lost" })
+	}
+	s1.Connect()
+	s2.Connect()
+	drain(events, 2)
+
+	mt.SimulateConnectionLost(errors.New("network down"))
+	if got := drain(events, 2); !got["s1 lost"] || !got["s2 lost"] {
+		t.Fatalf("connection lost events = %v", got)
+	}
+
+	// 重连后两个会话都收到 OnConnect
+	mt.Connect()
+	if got := drain(events, 2); !got["s1 up"] || !got["s2 up"] {
+		t.Fatalf("reconnect events = %v", got)
+	}
+}
+
+func TestConnectionManagerUsesFirstWill(t *testing.T) {
+	broker := NewMemoryBroker()
+	mt := NewMemoryTransport(broker)
+	m := NewConnectionManager(mt)
+	s1, s2 := m.NewSession(), m.NewSession()
+
+	wills := make(chan string, 2)
+	observer := NewMemoryTransport(broker)
+	observer.Subscribe("will/#", 1, receiveInto(wills))
+	observer.Connect()
+	defer observer.Disconnect()
+
+	s1.SetWill(&Message{Topic: "will/s1", Payload: []byte("s1")})
+	s1.Connect()
+	s2.SetWill(&Message{Topic: "will/s2", Payload: []byte("s2")}) // 连接已建立，被忽略
+	s2.Connect()
+
+	mt.SimulateConnectionLost(errors.New("network down"))
+	expectMessage(t, wills, "s1")
+	expectNoMessage(t, wills)
+}
+
+func TestConnectionManagerUnsubscribesWhileOffline(t *testing.T) {
+	broker := NewMemoryBroker()
+	ct := newCountingTransport(broker)
+	m := NewConnectionManager(ct)
+	s1, s2 := m.NewSession(), m.NewSession()
+	got := make(chan string, 4)
+	s1.Subscribe("cmd", 1, receiveInto(got))
+	s1.Connect()
+	s2.Connect()
+
+	// 离线时取消订阅并离开连接，重连后补发取消订阅
+	ct.SimulateConnectionLost(errors.New("network down"))
+	if err := s1.Unsubscribe("cmd"); err != nil {
+		t.Fatal(err)
+	}
+	s1.Disconnect()
+	if _, _, _, unsubscribes := ct.counts(); unsubscribes != 1 {
+		t.Fatalf("underlying Unsubscribe called %d times while offline, want 1", unsubscribes)
+	}
+	ct.Connect()
+	ct.mu.Lock()
+	unsubscribes := ct.unsubscribes
+	ct.mu.Unlock()
+	if len(unsubscribes) != 2 || len(unsubscribes[1]) != 1 || unsubscribes[1][0] != "cmd" {
+		t.Fatalf("underlying unsubscribes after reconnect = %v", unsubscribes)
+	}
+	if s1.IsConnected() || !s2.IsConnected() {
+		t.Fatalf("after reconnect: s1=%v s2=%v", s1.IsConnected(), s2.IsConnected())
+	}
+	newPublisher(t, broker).Publish("cmd", 1, false, []byte("stop"))
+	expectNoMessage(t, got)
+
+	// 重新订阅的过滤器不再补发取消订阅
+	ct.SimulateConnectionLost(errors.New("network down"))
+	s2.Subscribe("state", 1, func(*Message) {})
+	s2.Unsubscribe("state")
+	s2.Subscribe("state", 1, func(*Message) {})
+	ct.Connect()
+	if _, _, _, n := ct.counts(); n != 3 {
+		t.Fatalf("underlying Unsubscribe called %d times, want 3", n)
+	}
+}
+
+// drain 读取 n 个事件
+func drain(events <-chan string, n int) map[string]bool {
+	got := make(map[string]bool, n)
+	for i := 0; i < n; i++ {
+		select {
+		case e := <-events:
+			got[e] = true
+		case <-time.After(5 * time.Second):
+			return got
+		}
+	}
+	return got
+}
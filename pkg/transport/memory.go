@@ -0,0 +1,347 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/transport/memory.go
+ * 进程内传输层 - 不依赖外部 broker 的 Transport 实现，用于测试和单进程部署
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package transport
+
+import (
+	"strings"
+	"sync"
+)
+
+// MemoryBroker 进程内消息代理
+// 支持 MQTT 主题通配符、保留消息和遗嘱消息，不支持 QoS 重传和离线消息
+// 投递的 QoS 与 MQTT 一致，取发布和订阅中较小的值
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	clients  map[*MemoryTransport]struct{}
+	retained map[string]*Message
+}
+
+// NewMemoryBroker 创建进程内消息代理
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		clients:  make(map[*MemoryTransport]struct{}),
+		retained: make(map[string]*Message),
+	}
+}
+
+// publish 保存保留消息并投递给所有已连接客户端的匹配订阅
+func (b *MemoryBroker) publish(msg *Message) {
+	b.mu.Lock()
+	if msg.Retained {
+		if len(msg.Payload) == 0 {
+			delete(b.retained, msg.Topic)
+		} else {
+			b.retained[msg.Topic] = msg
+		}
+	}
+	clients := make([]*MemoryTransport, 0, len(b.clients))
+	for c := range b.clients {
+		clients = append(clients, c)
+	}
+	b.mu.Unlock()
+
+	// 保留标志只在订阅时补发的消息上设置
+	live := *msg
+	live.Retained = false
+	for _, c := range clients {
+		c.route(&live)
+	}
+}
+
+// retainedFor 返回匹配订阅过滤器的保留消息
+func (b *MemoryBroker) retainedFor(filter string) []*Message {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var msgs []*Message
+	for topic, msg := range b.retained {
+		if matchTopic(filter, topic) {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+func (b *MemoryBroker) attach(c *MemoryTransport) {
+	b.mu.Lock()
+	b.clients[c] = struct{}{}
+	b.mu.Unlock()
+}
+
+func (b *MemoryBroker) detach(c *MemoryTransport) {
+	b.mu.Lock()
+	delete(b.clients, c)
+	b.mu.Unlock()
+}
+
+// delivery 待投递的消息
+type delivery struct {
+	handler MessageHandler
+	qos     byte // 订阅的 QoS
+	msg     *Message
+}
+
+// MemoryTransport 连接到 MemoryBroker 的传输层
+// 同一客户端收到的消息按发布顺序在单独的 goroutine 中依次投递
+type MemoryTransport struct {
+	broker *MemoryBroker
+
+	mu        sync.Mutex
+	connected bool
+	subs      map[string]subscription
+	will      *Message
+	onConnect func()
+	onLost    func(err error)
+
+	// 投递队列，wake 和 done 随每次连接重新创建
+	pending []delivery
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+// NewMemoryTransport 创建连接到指定代理的传输层
+func NewMemoryTransport(broker *MemoryBroker) *MemoryTransport {
+	return &MemoryTransport{
+		broker: broker,
+		subs:   make(map[string]subscription),
+	}
+}
+
+// Connect 连接到代理，补发已订阅主题的保留消息
+func (t *MemoryTransport) Connect() error {
+	t.mu.Lock()
+	if t.connected {
+		t.mu.Unlock()
+		return nil
+	}
+	t.connected = true
+	t.done = make(chan struct{})
+	t.wake = make(chan struct{}, 1)
+	go t.deliverLoop(t.wake, t.done)
+	filters := make(map[string]subscription, len(t.subs))
+	for filter, sub := range t.subs {
+		filters[filter] = sub
+	}
+	onConnect := t.onConnect
+	t.mu.Unlock()
+
+	t.broker.attach(t)
+	for filter, sub := range filters {
+		t.deliverRetained(filter, sub)
+	}
+
+	if onConnect != nil {
+		onConnect()
+	}
+	return nil
+}
+
+// Disconnect 断开连接，丢弃尚未投递的消息
+func (t *MemoryTransport) Disconnect() {
+	t.disconnect()
+}
+
+// SimulateConnectionLost 模拟连接丢失，代理发布遗嘱消息并触发 OnConnectionLost 回调
+// 之后可再次调用 Connect 模拟重连
+func (t *MemoryTransport) SimulateConnectionLost(err error) {
+	if !t.disconnect() {
+		return
+	}
+
+	t.mu.Lock()
+	will := t.will
+	onLost := t.onLost
+	t.mu.Unlock()
+	if will != nil {
+		copied := *will
+		copied.Payload = append([]byte(nil), will.Payload...)
+		t.broker.publish(&copied)
+	}
+	if onLost != nil {
+		onLost(err)
+	}
+}
+
+// SetWill 设置遗嘱消息，SimulateConnectionLost 时由代理发布
+func (t *MemoryTransport) SetWill(msg *Message) {
+	t.mu.Lock()
+	t.will = msg
+	t.mu.Unlock()
+}
+
+// disconnect 断开连接，返回断开前是否已连接
+func (t *MemoryTransport) disconnect() bool {
+	t.mu.Lock()
+	if !t.connected {
+		t.mu.Unlock()
+		return false
+	}
+	t.connected = false
+	close(t.done)
+	t.pending = nil
+	t.mu.Unlock()
+
+	t.broker.detach(t)
+	return true
+}
+
+// IsConnected 返回当前是否已连接
+func (t *MemoryTransport) IsConnected() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.connected
+}
+
+// Publish 发布消息
+func (t *MemoryTransport) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	return t.PublishMessage(&Message{Topic: topic, QoS: qos, Retained: retained, Payload: payload})
+}
+
+// PublishMessage 携带消息属性发布，属性原样投递给订阅者
+func (t *MemoryTransport) PublishMessage(msg *Message) error {
+	if !t.IsConnected() {
+		return ErrNotConnected
+	}
+	copied := *msg
+	copied.Payload = append([]byte(nil), msg.Payload...)
+	t.broker.publish(&copied)
+	return nil
+}
+
+// Subscribe 订阅主题，已连接时立即补发匹配的保留消息
+func (t *MemoryTransport) Subscribe(topic string, qos byte, handler MessageHandler) error {
+	t.mu.Lock()
+	t.subs[topic] = subscription{qos: qos, handler: handler}
+	connected := t.connected
+	t.mu.Unlock()
+
+	if connected {
+		t.deliverRetained(topic, subscription{qos: qos, handler: handler})
+	}
+	return nil
+}
+
+// Unsubscribe 取消订阅
+func (t *MemoryTransport) Unsubscribe(topics ...string) error {
+	t.mu.Lock()
+	for _, topic := range topics {
+		delete(t.subs, topic)
+	}
+	t.mu.Unlock()
+	return nil
+}
+
+// OnConnect 设置连接成功回调
+func (t *MemoryTransport) OnConnect(fn func()) {
+	t.mu.Lock()
+	t.onConnect = fn
+	t.mu.Unlock()
+}
+
+// OnConnectionLost 设置连接丢失回调
+func (t *MemoryTransport) OnConnectionLost(fn func(err error)) {
+	t.mu.Lock()
+	t.onLost = fn
+	t.mu.Unlock()
+}
+
+// route 将消息加入所有匹配订阅的投递队列
+func (t *MemoryTransport) route(msg *Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.connected {
+		return
+	}
+	for filter, sub := range t.subs {
+		if matchTopic(filter, msg.Topic) {
+			t.pending = append(t.pending, delivery{handler: sub.handler, qos: sub.qos, msg: msg})
+		}
+	}
+	t.notify()
+}
+
+// deliverRetained 补发匹配订阅过滤器的保留消息
+func (t *MemoryTransport) deliverRetained(filter string, sub subscription) {
+	msgs := t.broker.retainedFor(filter)
+	if len(msgs) == 0 {
+		return
+	}
+	t.mu.Lock()
+	for _, msg := range msgs {
+		t.pending = append(t.pending, delivery{handler: sub.handler, qos: sub.qos, msg: msg})
+	}
+	t.notify()
+	t.mu.Unlock()
+}
+
+// notify 唤醒投递循环，调用方需持有 t.mu
+func (t *MemoryTransport) notify() {
+	if !t.connected {
+		return
+	}
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverLoop 依次执行投递队列中的消息回调，直到连接断开
+func (t *MemoryTransport) deliverLoop(wake, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-wake:
+		}
+
+		for {
+			t.mu.Lock()
+			select {
+			case <-done:
+				t.mu.Unlock()
+				return
+			default:
+			}
+			if len(t.pending) == 0 {
+				t.mu.Unlock()
+				break
+			}
+			d := t.pending[0]
+			t.pending = t.pending[1:]
+			t.mu.Unlock()
+
+			// 每个回调拿到独立的消息副本，避免修改影响其他订阅者
+			msg := *d.msg
+			if msg.QoS > d.qos {
+				msg.QoS = d.qos
+			}
+			d.handler(&msg)
+		}
+	}
+}
+
+// matchTopic 按 MQTT 通配符规则判断主题是否匹配订阅过滤器
+func matchTopic(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
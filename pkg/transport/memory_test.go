@@ -0,0 +1,171 @@
+package transport
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// receiveMessages 将收到的消息写入通道
+func receiveMessages(ch chan<- *Message) MessageHandler {
+	return func(msg *Message) { ch <- msg }
+}
+
+func nextMessage(t *testing.T, ch <-chan *Message) *Message {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"a/b/c", "a/b/c", true},
+		{"a/b/c", "a/b", false},
+		{"a/b", "a/b/c", false},
+		{"a/+/c", "a/x/c", true},
+		{"a/+/c", "a/x/y/c", false},
+		{"a/+", "a/", true},
+		{"+/+", "a/b", true},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"#", "a/b", true},
+		{"a/b/#", "a/c", false},
+	}
+	for _, tt := range tests {
+		if got := matchTopic(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("matchTopic(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryBrokerDeliversMinimumQoS(t *testing.T) {
+	broker := NewMemoryBroker()
+	pub := newPublisher(t, broker)
+	sub := newPublisher(t, broker)
+
+	qos0 := make(chan *Message, 4)
+	qos2 := make(chan *Message, 4)
+	sub.Subscribe("q/0", 0, receiveMessages(qos0))
+	sub.Subscribe("q/2", 2, receiveMessages(qos2))
+
+	tests := []struct {
+		topic string
+		ch    chan *Message
+		pub   byte
+		want  byte
+	}{
+		{"q/0", qos0, 2, 0},
+		{"q/0", qos0, 0, 0},
+		{"q/2", qos2, 1, 1},
+		{"q/2", qos2, 2, 2},
+	}
+	for _, tt := range tests {
+		if err := pub.Publish(tt.topic, tt.pub, false, []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+		if msg := nextMessage(t, tt.ch); msg.QoS != tt.want {
+			t.Errorf("%s published at QoS %d delivered at %d, want %d", tt.topic, tt.pub, msg.QoS, tt.want)
+		}
+	}
+}
+
+func TestMemoryBrokerRetainedMessages(t *testing.T) {
+	broker := NewMemoryBroker()
+	pub := newPublisher(t, broker)
+	pub.Publish("state/a", 1, true, []byte("old"))
+	pub.Publish("state/a", 1, true, []byte("online"))
+	pub.Publish("state/b", 1, true, []byte("online"))
+	pub.Publish("state/b", 1, true, nil) // 空负载清除保留消息
+
+	// 订阅时补发最新的保留消息，带保留标志，QoS 按订阅降级
+	sub := newPublisher(t, broker)
+	msgs := make(chan *Message, 4)
+	sub.Subscribe("state/+", 0, receiveMessages(msgs))
+	msg := nextMessage(t, msgs)
+	if msg.Topic != "state/a" || string(msg.Payload) != "online" || !msg.Retained || msg.QoS != 0 {
+		t.Fatalf("retained delivery = %+v", msg)
+	}
+
+	// 实时投递的消息不带保留标志
+	pub.Publish("state/a", 1, true, []byte("offline"))
+	if msg := nextMessage(t, msgs); string(msg.Payload) != "offline" || msg.Retained {
+		t.Fatalf("live delivery = %+v", msg)
+	}
+	select {
+	case msg := <-msgs:
+		t.Fatalf("unexpected message %+v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// 重新连接后再次补发已订阅主题的保留消息
+	sub.Disconnect()
+	if err := sub.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if msg := nextMessage(t, msgs); string(msg.Payload) != "offline" || !msg.Retained {
+		t.Fatalf("retained delivery after reconnect = %+v", msg)
+	}
+}
+
+func TestMemoryBrokerWill(t *testing.T) {
+	broker := NewMemoryBroker()
+	watcher := newPublisher(t, broker)
+	states := make(chan string, 4)
+	watcher.Subscribe("state", 1, receiveInto(states))
+
+	client := NewMemoryTransport(broker)
+	client.SetWill(&Message{Topic: "state", QoS: 1, Retained: true, Payload: []byte("lost")})
+	lost := make(chan error, 1)
+	client.OnConnectionLost(func(err error) { lost <- err })
+
+	// 正常断开不发布遗嘱
+	client.Connect()
+	client.Disconnect()
+	expectNoMessage(t, states)
+
+	client.Connect()
+	client.SimulateConnectionLost(errors.New("network down"))
+	expectMessage(t, states, "lost")
+	if err := <-lost; err == nil || err.Error() != "network down" {
+		t.Fatalf("connection lost error = %v", err)
+	}
+	if client.IsConnected() {
+		t.Fatal("client still connected")
+	}
+	if err := client.Publish("state", 1, false, []byte("x")); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("publish while disconnected = %v", err)
+	}
+
+	// 遗嘱是保留消息，之后订阅的客户端同样收到
+	late := newPublisher(t, broker)
+	late.Subscribe("state", 1, receiveInto(states))
+	expectMessage(t, states, "lost")
+}
+
+func TestMemoryTransportDeliversInOrder(t *testing.T) {
+	broker := NewMemoryBroker()
+	pub := newPublisher(t, broker)
+	sub := newPublisher(t, broker)
+	got := make(chan string, 100)
+	sub.Subscribe("seq/#", 1, receiveInto(got))
+
+	for i := 0; i < 50; i++ {
+		pub.Publish("seq/n", 1, false, []byte{byte(i)})
+	}
+	for i := 0; i < 50; i++ {
+		expectMessage(t, got, string([]byte{byte(i)}))
+	}
+
+	// 取消订阅后不再投递
+	sub.Unsubscribe("seq/#")
+	pub.Publish("seq/n", 1, false, []byte("late"))
+	expectNoMessage(t, got)
+}
@@ -10,16 +10,18 @@
 package transport
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"time"
 
-	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/HY-805/SubNodeSync/pkg/codec"
+	"github.com/HY-805/SubNodeSync/pkg/protocol"
 )
 
 // MQTT主题常量
+//
+// Deprecated: 旧版本的主题格式，MQTTClient 已改用 protocol.TopicScheme 生成主题
 const (
 	ControlTopic   = "v1/node/sync/%s/control"   // 控制命令主题
 	HeartbeatTopic = "v1/node/sync/%s/heartbeat" // 心跳主题
@@ -29,14 +31,24 @@ const (
 )
 
 // MQTTClient MQTT客户端结构体
+// 消息收发通过 Transport 完成，默认使用 PahoTransport
+//
+// 传输层支持遗嘱消息时，Connect 在实例的 state 主题上注册 offline 遗嘱，
+// 连接成功后发布保留的 online 消息，Disconnect 时发布 offline/shutdown。
+// 与命令接收器共用连接时，在线状态应只由其中一方发布，见 SetStatePublishing。
 type MQTTClient struct {
-	NodeName     string
-	client       mqtt.Client
-	connected    bool
-	controlTopic string
-	statusTopic  string
-	logTopic     string
-	onControl    func(action string)
+	NodeName       string
+	transport      Transport
+	controlTopic   string
+	heartbeatTopic string
+	statusTopic    string
+	logTopic       string
+	stateTopic     string
+	onControl      func(action string)
+	codec          codec.Codec
+	source         string // 消息信封中的来源，即客户端ID
+	namespace      string // 心跳和在线状态中的租户命名空间
+	stateEnabled   bool   // 是否注册遗嘱并发布在线状态
 }
 
 // MQTTConfig MQTT配置
@@ -46,6 +58,20 @@ type MQTTConfig struct {
 	Username  string
 	Password  string
 	KeepAlive time.Duration
+	// PersistentSession 为true时使用持久会话（CleanSession=false），重连后broker补发离线期间的QoS 1消息
+	PersistentSession bool
+	// ProtocolVersion MQTT协议版本，ProtocolV5 使用 MQTT5Transport，默认为 v3.1.1
+	ProtocolVersion byte
+	// SessionExpiry MQTT v5 会话过期时间，PersistentSession 为true且为0时使用 DefaultSessionExpiry
+	SessionExpiry time.Duration
+	// MessageExpiry MQTT v5 发布消息的默认过期时间，为0时不过期，不作用于保留消息
+	MessageExpiry time.Duration
+	// Will 遗嘱消息，连接异常断开时由 broker 发布，为nil时不设置
+	Will *Message
+	// TLS 加密连接配置，为nil时不使用TLS
+	TLS *TLSConfig
+	// Topics 主题规划，为nil时使用默认规划
+	Topics *protocol.TopicScheme
 }
 
 // DefaultMQTTConfig 默认MQTT配置
@@ -67,27 +93,29 @@ func NewMQTTClient(nodeName string, config *MQTTConfig) (*MQTTClient, error) {
 		clientID = nodeName + "-client" + fmt.Sprintf("_%d", time.Now().UnixMilli())
 	}
 
+	transportConfig := *config
+	transportConfig.ClientID = clientID
+
+	mqttClient := NewMQTTClientWithTransport(nodeName, clientID, NewTransport(&transportConfig))
+	mqttClient.SetTopicScheme(config.Topics)
+	return mqttClient, nil
+}
+
+// NewMQTTClientWithTransport 使用指定的传输层创建客户端
+// clientID 作为消息信封中的来源和心跳中的实例ID
+func NewMQTTClientWithTransport(nodeName, clientID string, t Transport) *MQTTClient {
 	mqttClient := &MQTTClient{
 		NodeName:     nodeName,
-		connected:    false,
-		controlTopic: fmt.Sprintf(ControlTopic, nodeName),
-		statusTopic:  fmt.Sprintf(HeartbeatTopic, nodeName),
-		logTopic:     fmt.Sprintf(LogTopic, nodeName),
+		transport:    t,
+		codec:        codec.Default(),
+		source:       clientID,
+		stateEnabled: true,
 	}
+	mqttClient.SetTopicScheme(nil)
 
-	// 配置MQTT客户端选项
-	opts := mqtt.NewClientOptions()
-	opts.AddBroker(config.BrokerURL)
-	opts.SetClientID(clientID)
-	opts.SetUsername(config.Username)
-	opts.SetPassword(config.Password)
-	opts.SetKeepAlive(config.KeepAlive)
-	opts.SetAutoReconnect(true)
-	opts.OnConnect = mqttClient.onConnect
-	opts.OnConnectionLost = mqttClient.onConnectionLost
-
-	mqttClient.client = mqtt.NewClient(opts)
-	return mqttClient, nil
+	t.OnConnect(mqttClient.onConnect)
+	t.OnConnectionLost(mqttClient.onConnectionLost)
+	return mqttClient
 }
 
 // NewMQTTClientWithID 创建带自定义客户端ID的MQTT客户端
@@ -104,63 +132,132 @@ func NewMQTTClientWithID(nodeName, clientID, brokerURL, username, password strin
 
 // Connect 连接MQTT broker
 func (m *MQTTClient) Connect() error {
-	if token := m.client.Connect(); token.Wait() && token.Error() != nil {
-		return token.Error()
+	if err := protocol.ValidateSegment(m.NodeName); err != nil {
+		return fmt.Errorf("invalid node name: %w", err)
+	}
+
+	// 注册遗嘱，连接异常断开时由 broker 发布 offline 状态
+	if ws, ok := m.transport.(WillSetter); ok && m.stateEnabled {
+		will, err := m.stateMessage(protocol.StateOffline, protocol.OfflineReasonConnectionLost)
+		if err != nil {
+			return err
+		}
+		ws.SetWill(will)
 	}
 
-	// 订阅控制主题
-	if token := m.client.Subscribe(m.controlTopic, 1, m.onControlMessage); token.Wait() && token.Error() != nil {
-		return token.Error()
+	// 设置了控制消息回调时订阅控制主题，连接成功后由传输层生效并在重连后恢复
+	if m.onControl != nil {
+		if err := m.transport.Subscribe(m.controlTopic, 1, m.onControlMessage); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return m.transport.Connect()
 }
 
-// Disconnect 断开MQTT连接
+// Disconnect 发布 offline/shutdown 状态后断开MQTT连接，离线时只取消订阅并断开
 func (m *MQTTClient) Disconnect() {
-	if m.client != nil && m.connected {
-		m.client.Unsubscribe(m.controlTopic)
-		m.client.Disconnect(250)
+	// 正常断开时 broker 不发布遗嘱，需要主动发布离线状态
+	if m.transport.IsConnected() && m.stateEnabled {
+		if err := m.publishState(protocol.StateOffline, protocol.OfflineReasonShutdown); err != nil {
+			log.Printf("[SubNodeSync] 发布离线状态失败: %v", err)
+		}
 	}
+	// 离线时同样取消订阅并离开共享连接
+	m.transport.Unsubscribe(m.controlTopic)
+	m.transport.Disconnect()
 }
 
 // onConnect 连接成功回调
-func (m *MQTTClient) onConnect(client mqtt.Client) {
-	m.connected = true
+func (m *MQTTClient) onConnect() {
 	log.Printf("[SubNodeSync] MQTT客户端 %s 已连接到broker", m.NodeName)
+	if !m.stateEnabled {
+		return
+	}
+	if err := m.publishState(protocol.StateOnline, ""); err != nil {
+		log.Printf("[SubNodeSync] 发布在线状态失败: %v", err)
+	}
 }
 
 // onConnectionLost 连接丢失回调
-func (m *MQTTClient) onConnectionLost(client mqtt.Client, err error) {
-	m.connected = false
+func (m *MQTTClient) onConnectionLost(err error) {
 	log.Printf("[SubNodeSync] MQTT客户端 %s 连接丢失: %v", m.NodeName, err)
 }
 
-// SetControlHandler 设置控制消息处理回调
+// SetControlHandler 设置控制消息处理回调，应在 Connect 之前调用
+//
+// Deprecated: 旧版本的 action 消息不经过签名、命名空间和授权检查，
+// 任何能向控制主题发布消息的客户端都能触发回调。sync.CommandReceiver
+// 会将这些消息转换为同名命令执行，应改为在接收器上注册 stop、restart 等命令处理器。
 func (m *MQTTClient) SetControlHandler(fn func(action string)) {
 	m.onControl = fn
 }
 
 // onControlMessage 控制消息处理
-func (m *MQTTClient) onControlMessage(client mqtt.Client, msg mqtt.Message) {
+func (m *MQTTClient) onControlMessage(msg *Message) {
 	var controlData struct {
 		Action string                 `json:"action"`
 		Params map[string]interface{} `json:"params,omitempty"`
 	}
 
-	if err := json.Unmarshal(msg.Payload(), &controlData); err != nil {
+	env, _, err := protocol.Decode(msg.Payload)
+	if err == nil {
+		err = env.DecodeBody(&controlData)
+	}
+	if err != nil {
 		log.Printf("[SubNodeSync] 解析控制消息失败: %v", err)
 		return
 	}
 
+	// 控制主题同时承载 sync.Command 命令消息，由 sync.CommandReceiver 处理
+	if controlData.Action == "" {
+		return
+	}
+
 	if m.onControl != nil {
 		m.onControl(controlData.Action)
 	}
 }
 
+// SetTopicScheme 设置主题规划，应在 Connect 之前调用
+func (m *MQTTClient) SetTopicScheme(scheme *protocol.TopicScheme) {
+	if scheme == nil {
+		scheme = protocol.DefaultTopicScheme()
+	}
+	m.controlTopic = scheme.Control(m.NodeName)
+	m.heartbeatTopic = scheme.Heartbeat(m.NodeName)
+	m.statusTopic = scheme.Status(m.NodeName)
+	m.logTopic = scheme.Log(m.NodeName)
+	m.stateTopic = scheme.State(m.NodeName, m.source)
+}
+
+// SetCodec 设置发布消息使用的编解码器，默认为 JSON
+func (m *MQTTClient) SetCodec(c codec.Codec) {
+	if c == nil {
+		c = codec.Default()
+	}
+	m.codec = c
+}
+
+// SetNamespace 设置心跳和在线状态中的租户命名空间，应与主题规划的租户段一致
+func (m *MQTTClient) SetNamespace(namespace string) {
+	m.namespace = namespace
+}
+
+// SetStatePublishing 设置是否注册遗嘱并发布在线状态，默认开启，应在 Connect 之前调用
+// 一个连接只有一个遗嘱，与命令接收器共用连接时应关闭，由接收器统一发布实例的在线状态
+func (m *MQTTClient) SetStatePublishing(enabled bool) {
+	m.stateEnabled = enabled
+}
+
 // IsConnected 检查MQTT连接状态
 func (m *MQTTClient) IsConnected() bool {
-	return m.connected && m.client.IsConnected()
+	return m.transport.IsConnected()
+}
+
+// Transport 返回底层传输层
+func (m *MQTTClient) Transport() Transport {
+	return m.transport
 }
 
 // Publish 发布消息
@@ -178,43 +275,23 @@ func (m *MQTTClient) Publish(topic string, qos byte, retained bool, payload inte
 	case string:
 		data = []byte(v)
 	default:
-		data, err = json.Marshal(payload)
+		data, err = codec.Encode(m.codec, payload)
 		if err != nil {
 			return fmt.Errorf("failed to marshal payload: %w", err)
 		}
 	}
 
-	token := m.client.Publish(topic, qos, retained, data)
-	if token.Wait() && token.Error() != nil {
-		return token.Error()
-	}
-	return nil
+	return m.transport.Publish(topic, qos, retained, data)
 }
 
-// Subscribe 订阅主题
-func (m *MQTTClient) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) error {
-	if !m.IsConnected() {
-		return fmt.Errorf("MQTT client not connected")
-	}
-
-	token := m.client.Subscribe(topic, qos, callback)
-	if token.Wait() && token.Error() != nil {
-		return token.Error()
-	}
-	return nil
+// Subscribe 订阅主题，订阅在重连后自动恢复
+func (m *MQTTClient) Subscribe(topic string, qos byte, handler MessageHandler) error {
+	return m.transport.Subscribe(topic, qos, handler)
 }
 
 // Unsubscribe 取消订阅
 func (m *MQTTClient) Unsubscribe(topics ...string) error {
-	if !m.IsConnected() {
-		return fmt.Errorf("MQTT client not connected")
-	}
-
-	token := m.client.Unsubscribe(topics...)
-	if token.Wait() && token.Error() != nil {
-		return token.Error()
-	}
-	return nil
+	return m.transport.Unsubscribe(topics...)
 }
 
 // SendHeartbeat 发送心跳消息
@@ -223,18 +300,18 @@ func (m *MQTTClient) SendHeartbeat() error {
 		return fmt.Errorf("MQTT client not connected")
 	}
 
-	heartbeatData := struct {
-		Status   string            `json:"status"`
-		PID      int               `json:"pid"`
-		Memory   int64             `json:"memory"`
-		CPU      float64           `json:"cpu"`
-		Metadata map[string]string `json:"metadata,omitempty"`
-	}{
-		Status: "running",
-		PID:    os.Getpid(),
+	hostname, _ := os.Hostname()
+	heartbeat := &protocol.HeartbeatBody{
+		Namespace:  m.namespace,
+		AppName:    m.NodeName,
+		InstanceID: m.source,
+		Status:     "running",
+		PID:        os.Getpid(),
+		Hostname:   hostname,
+		Metrics:    protocol.CollectProcessMetrics(),
 	}
 
-	return m.Publish(m.statusTopic, 1, false, heartbeatData)
+	return m.PublishEnvelope(m.heartbeatTopic, protocol.TypeHeartbeat, heartbeat)
 }
 
 // SendStatus 发送状态消息
@@ -243,18 +320,13 @@ func (m *MQTTClient) SendStatus(status string, details map[string]string) error
 		return fmt.Errorf("MQTT client not connected")
 	}
 
-	pid := os.Getpid()
-	statusData := struct {
-		Status  string            `json:"status"`
-		PID     *int              `json:"pid,omitempty"`
-		Details map[string]string `json:"details,omitempty"`
-	}{
+	statusData := &protocol.StatusBody{
 		Status:  status,
-		PID:     &pid,
+		PID:     os.Getpid(),
 		Details: details,
 	}
 
-	return m.Publish(m.statusTopic, 1, false, statusData)
+	return m.PublishEnvelope(m.statusTopic, protocol.TypeStatus, statusData)
 }
 
 // SendLog 发送日志消息
@@ -263,17 +335,47 @@ func (m *MQTTClient) SendLog(level, message string) error {
 		return fmt.Errorf("MQTT client not connected")
 	}
 
-	logData := struct {
-		Level   string `json:"level"`
-		Message string `json:"message"`
-		Source  string `json:"source,omitempty"`
-	}{
+	logData := &protocol.LogBody{
 		Level:   level,
 		Message: message,
 		Source:  m.NodeName,
 	}
 
-	return m.Publish(m.logTopic, 1, false, logData)
+	return m.PublishEnvelope(m.logTopic, protocol.TypeLog, logData)
+}
+
+// PublishEnvelope 将消息体包装为版本化信封后发布
+func (m *MQTTClient) PublishEnvelope(topic string, msgType protocol.MessageType, body interface{}) error {
+	data, err := protocol.Encode(m.codec, protocol.NewEnvelope(msgType, m.source, body))
+	if err != nil {
+		return fmt.Errorf("failed to encode %s message: %w", msgType, err)
+	}
+	return m.Publish(topic, 1, false, data)
+}
+
+// stateMessage 生成发布到 state 主题的在线状态保留消息
+func (m *MQTTClient) stateMessage(state, reason string) (*Message, error) {
+	body := &protocol.StateBody{
+		Namespace:  m.namespace,
+		AppName:    m.NodeName,
+		InstanceID: m.source,
+		State:      state,
+		Reason:     reason,
+	}
+	data, err := protocol.Encode(m.codec, protocol.NewEnvelope(protocol.TypeState, m.source, body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode state message: %w", err)
+	}
+	return &Message{Topic: m.stateTopic, Payload: data, QoS: 1, Retained: true}, nil
+}
+
+// publishState 发布在线状态
+func (m *MQTTClient) publishState(state, reason string) error {
+	msg, err := m.stateMessage(state, reason)
+	if err != nil {
+		return err
+	}
+	return m.transport.Publish(msg.Topic, msg.QoS, msg.Retained, msg.Payload)
 }
 
 // GetControlTopic 获取控制主题
@@ -281,6 +383,11 @@ func (m *MQTTClient) GetControlTopic() string {
 	return m.controlTopic
 }
 
+// GetHeartbeatTopic 获取心跳主题
+func (m *MQTTClient) GetHeartbeatTopic() string {
+	return m.heartbeatTopic
+}
+
 // GetStatusTopic 获取状态主题
 func (m *MQTTClient) GetStatusTopic() string {
 	return m.statusTopic
@@ -290,3 +397,8 @@ func (m *MQTTClient) GetStatusTopic() string {
 func (m *MQTTClient) GetLogTopic() string {
 	return m.logTopic
 }
+
+// GetStateTopic 获取在线状态主题
+func (m *MQTTClient) GetStateTopic() string {
+	return m.stateTopic
+}
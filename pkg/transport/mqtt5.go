@@ -0,0 +1,734 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/transport/mqtt5.go
+ * MQTT v5 传输层 - 基于 paho.golang/autopaho，原生支持应答主题、关联数据、用户属性、消息过期和会话过期
+ *
+ * 报文编解码、QoS 1/2 握手、会话状态（未确认消息的重发）和自动重连由 autopaho 完成，
+ * 本文件只负责在 Transport 接口与 paho 的报文属性之间转换，并在 broker 不支持 v5 时改用 v3.1.1。
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/gorilla/websocket"
+)
+
+// MQTT 协议版本
+const (
+	ProtocolV311 byte = 4
+	ProtocolV5   byte = 5
+)
+
+// DefaultSessionExpiry 使用持久会话且未设置 SessionExpiry 时的 MQTT v5 会话过期时间
+const DefaultSessionExpiry = time.Hour
+
+const (
+	mqtt5ConnectTimeout   = 30 * time.Second
+	mqtt5ReconnectDelay   = 5 * time.Second
+	mqtt5DisconnectWait   = 5 * time.Second
+	mqtt5UnsupportedV5    = 0x84 // v5 CONNACK 原因码：不支持的协议版本
+	mqtt5UnsupportedV311  = 0x01 // v3.1.1 CONNACK 返回码：不接受的协议版本
+	mqtt5ReasonCodeFailed = 0x80
+)
+
+const (
+	// mqtt5AckTimeout 等待 broker 确认的时间，超时的 QoS 1/2 消息仍保留在会话中，重连后重发
+	mqtt5AckTimeout = 30 * time.Second
+	// mqtt5ReceiveMaximum CONNECT 中声明的 Receive Maximum，限制 broker 未确认的 QoS 1/2 入站消息数
+	mqtt5ReceiveMaximum = 64
+)
+
+// ErrUnsupportedProtocol broker 不支持 MQTT v5 时返回的错误
+var ErrUnsupportedProtocol = errors.New("mqtt5: broker does not support MQTT v5")
+
+// NewTransport 按 config.ProtocolVersion 创建 MQTT 传输层
+// ProtocolV5 使用 MQTT5Transport，其余使用基于 paho 的 v3.1.1 传输层
+func NewTransport(config *MQTTConfig) Transport {
+	if config != nil && config.ProtocolVersion == ProtocolV5 {
+		return NewMQTT5Transport(config)
+	}
+	return NewPahoTransport(config)
+}
+
+// MQTT5Transport MQTT v5 传输层
+//
+// 收到的消息通过 Message.Properties 提供应答主题、关联数据等属性，
+// PublishMessage 可携带这些属性发布。支持 QoS 0、1、2，超过 broker Maximum QoS 时降级。
+// 使用持久会话时，未确认的 QoS 1/2 消息在重连后重发；收到的消息按顺序分发，
+// 处理器返回后才确认，broker 未确认的入站消息数受 Receive Maximum 限制。
+// broker 拒绝 v5 协议时自动改用 v3.1.1（PahoTransport），此后消息属性被忽略。
+type MQTT5Transport struct {
+	config *MQTTConfig
+	tls    *tlsLoader
+
+	mu        sync.Mutex
+	will      *Message
+	subs      map[string]subscription
+	onConnect func()
+	onLost    func(err error)
+	fallback  Transport
+
+	cm      *autopaho.ConnectionManager
+	dialing *mqtt5Conn // 正在建立的连接
+	conn    *mqtt5Conn // 当前可用的连接，断开后为nil
+	maxQoS  byte       // 当前连接上 broker 支持的最大 QoS
+	lost    bool       // 连接已断开，尚未调用 OnConnectionLost 回调
+	// connectResult 接收首次连接的结果，Connect 返回后为nil
+	connectResult chan error
+}
+
+// NewMQTT5Transport 创建 MQTT v5 传输层，config 为nil时使用默认配置
+func NewMQTT5Transport(config *MQTTConfig) *MQTT5Transport {
+	if config == nil {
+		config = DefaultMQTTConfig()
+	}
+	t := &MQTT5Transport{
+		config: config,
+		will:   config.Will,
+		subs:   make(map[string]subscription),
+	}
+	if config.TLS != nil {
+		t.tls = newTLSLoader(config.TLS)
+	}
+	return t
+}
+
+// SetWill 设置遗嘱消息，在下次（重新）连接时生效
+func (t *MQTT5Transport) SetWill(msg *Message) {
+	t.mu.Lock()
+	t.will = msg
+	fb := t.fallback
+	t.mu.Unlock()
+	if ws, ok := fb.(WillSetter); ok {
+		ws.SetWill(msg)
+	}
+}
+
+// Connect 连接 broker，之后由 autopaho 自动重连；broker 不支持 v5 时改用 v3.1.1
+func (t *MQTT5Transport) Connect() error {
+	if fb := t.fallbackTransport(); fb != nil {
+		return fb.Connect()
+	}
+
+	u, err := url.Parse(t.config.BrokerURL)
+	if err != nil {
+		return fmt.Errorf("mqtt5: invalid broker url: %w", err)
+	}
+
+	t.mu.Lock()
+	if t.cm != nil {
+		t.mu.Unlock()
+		return nil
+	}
+	result := make(chan error, 1)
+	t.connectResult = result
+	cm, err := autopaho.NewConnection(context.Background(), t.clientConfig(u))
+	if err != nil {
+		t.connectResult = nil
+		t.mu.Unlock()
+		return err
+	}
+	t.cm = cm
+	t.mu.Unlock()
+
+	if err = <-result; err == nil {
+		return nil
+	}
+
+	t.shutdown()
+	if errors.Is(err, ErrUnsupportedProtocol) {
+		log.Printf("[SubNodeSync] broker %s 不支持 MQTT v5，改用 v3.1.1", t.config.BrokerURL)
+		return t.useFallback().Connect()
+	}
+	return err
+}
+
+// Disconnect 断开连接并停止自动重连
+func (t *MQTT5Transport) Disconnect() {
+	if fb := t.fallbackTransport(); fb != nil {
+		fb.Disconnect()
+		return
+	}
+	t.shutdown()
+}
+
+// shutdown 停止连接管理器，不触发 OnConnectionLost 回调
+func (t *MQTT5Transport) shutdown() {
+	t.mu.Lock()
+	cm := t.cm
+	t.cm = nil
+	t.conn = nil
+	t.lost = false
+	t.mu.Unlock()
+
+	if cm != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), mqtt5DisconnectWait)
+		defer cancel()
+		cm.Disconnect(ctx)
+	}
+}
+
+// IsConnected 返回当前是否已连接
+func (t *MQTT5Transport) IsConnected() bool {
+	if fb := t.fallbackTransport(); fb != nil {
+		return fb.IsConnected()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.conn != nil
+}
+
+// Publish 发布消息，使用 MQTTConfig.MessageExpiry 作为过期时间
+func (t *MQTT5Transport) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	return t.PublishMessage(&Message{Topic: topic, QoS: qos, Retained: retained, Payload: payload})
+}
+
+// PublishMessage 携带消息属性发布，QoS 1/2 时等待 broker 确认
+// 使用持久会话时，等待超时或连接断开的消息保留在会话中，重连后重发
+func (t *MQTT5Transport) PublishMessage(msg *Message) error {
+	if fb := t.fallbackTransport(); fb != nil {
+		return fb.Publish(msg.Topic, msg.QoS, msg.Retained, msg.Payload)
+	}
+
+	t.mu.Lock()
+	cm, connected, maxQoS := t.cm, t.conn != nil, t.maxQoS
+	t.mu.Unlock()
+	if cm == nil || !connected {
+		return ErrNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mqtt5AckTimeout)
+	defer cancel()
+	_, err := cm.Publish(ctx, &paho.Publish{
+		Topic:      msg.Topic,
+		QoS:        min(msg.QoS, maxQoS),
+		Retain:     msg.Retained,
+		Payload:    msg.Payload,
+		Properties: t.publishProperties(msg.Properties, msg.Retained),
+	})
+	if errors.Is(err, autopaho.ConnectionDownError) {
+		return ErrNotConnected
+	}
+	if err != nil {
+		return fmt.Errorf("mqtt5: publish to %s: %w", msg.Topic, err)
+	}
+	return nil
+}
+
+// Subscribe 订阅主题，未连接时仅记录订阅，连接成功后自动生效
+func (t *MQTT5Transport) Subscribe(topic string, qos byte, handler MessageHandler) error {
+	if fb := t.fallbackTransport(); fb != nil {
+		return fb.Subscribe(topic, qos, handler)
+	}
+
+	t.mu.Lock()
+	t.subs[topic] = subscription{qos: qos, handler: handler}
+	cm, connected := t.cm, t.conn != nil
+	t.mu.Unlock()
+
+	if cm == nil || !connected {
+		return nil
+	}
+	return t.subscribe(cm, map[string]byte{topic: qos})
+}
+
+// Unsubscribe 取消订阅
+func (t *MQTT5Transport) Unsubscribe(topics ...string) error {
+	if fb := t.fallbackTransport(); fb != nil {
+		return fb.Unsubscribe(topics...)
+	}
+
+	t.mu.Lock()
+	for _, topic := range topics {
+		delete(t.subs, topic)
+	}
+	cm, connected := t.cm, t.conn != nil
+	t.mu.Unlock()
+
+	if cm == nil || !connected || len(topics) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mqtt5AckTimeout)
+	defer cancel()
+	_, err := cm.Unsubscribe(ctx, &paho.Unsubscribe{Topics: topics})
+	if errors.Is(err, autopaho.ConnectionDownError) {
+		return nil
+	}
+	return err
+}
+
+// OnConnect 设置连接成功回调
+func (t *MQTT5Transport) OnConnect(fn func()) {
+	t.mu.Lock()
+	t.onConnect = fn
+	fb := t.fallback
+	t.mu.Unlock()
+	if fb != nil {
+		fb.OnConnect(fn)
+	}
+}
+
+// OnConnectionLost 设置连接丢失回调
+func (t *MQTT5Transport) OnConnectionLost(fn func(err error)) {
+	t.mu.Lock()
+	t.onLost = fn
+	fb := t.fallback
+	t.mu.Unlock()
+	if fb != nil {
+		fb.OnConnectionLost(fn)
+	}
+}
+
+func (t *MQTT5Transport) fallbackTransport() Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.fallback
+}
+
+// useFallback 创建 v3.1.1 传输层并迁移订阅和回调
+func (t *MQTT5Transport) useFallback() Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	config := *t.config
+	config.ProtocolVersion = ProtocolV311
+	config.Will = t.will
+	fb := NewPahoTransport(&config)
+	for topic, sub := range t.subs {
+		fb.Subscribe(topic, sub.qos, sub.handler)
+	}
+	fb.OnConnect(t.onConnect)
+	fb.OnConnectionLost(t.onLost)
+	t.fallback = fb
+	return fb
+}
+
+// clientConfig 生成 autopaho 配置
+func (t *MQTT5Transport) clientConfig(u *url.URL) autopaho.ClientConfig {
+	cfg := autopaho.ClientConfig{
+		ServerUrls:                    []*url.URL{u},
+		KeepAlive:                     uint16(t.config.KeepAlive / time.Second),
+		CleanStartOnInitialConnection: !t.config.PersistentSession,
+		SessionExpiryInterval:         uint32(t.sessionExpiry() / time.Second),
+		ConnectRetryDelay:             mqtt5ReconnectDelay,
+		ConnectTimeout:                mqtt5ConnectTimeout,
+		ConnectUsername:               t.config.Username,
+		ConnectPassword:               []byte(t.config.Password),
+		ConnectPacketBuilder:          t.buildConnect,
+		OnConnectionUp:                t.connectionUp,
+		OnConnectError:                t.connectError,
+		ClientConfig: paho.ClientConfig{
+			ClientID:           t.config.ClientID,
+			OnPublishReceived:  []func(paho.PublishReceived) (bool, error){t.handlePublish},
+			OnClientError:      t.connectionLost,
+			OnServerDisconnect: t.serverDisconnect,
+		},
+	}
+	// 所有连接由 dial 建立，TLS 连接（含 wss）每次连接前检查证书文件
+	cfg.AttemptConnection = t.dial
+	return cfg
+}
+
+// buildConnect 在每次连接前写入当前的遗嘱和 Receive Maximum
+func (t *MQTT5Transport) buildConnect(cp *paho.Connect, _ *url.URL) *paho.Connect {
+	if cp.Properties == nil {
+		cp.Properties = &paho.ConnectProperties{}
+	}
+	receiveMaximum := uint16(mqtt5ReceiveMaximum)
+	cp.Properties.ReceiveMaximum = &receiveMaximum
+
+	t.mu.Lock()
+	will := t.will
+	t.mu.Unlock()
+	cp.WillMessage, cp.WillProperties = nil, nil
+	if will != nil {
+		cp.WillMessage = &paho.WillMessage{
+			Topic:   will.Topic,
+			Payload: will.Payload,
+			QoS:     min(will.QoS, 2),
+			Retain:  will.Retained,
+		}
+		// paho 在 CONNECT 中只编码遗嘱的 Will Delay Interval 和 User Properties
+		if will.Properties != nil && len(will.Properties.UserProperties) > 0 {
+			cp.WillProperties = &paho.WillProperties{User: t.publishProperties(will.Properties, will.Retained).User}
+		}
+	}
+	return cp
+}
+
+// dial 按 broker 地址的协议建立 TCP、TLS 或 WebSocket 连接
+func (t *MQTT5Transport) dial(ctx context.Context, _ autopaho.ClientConfig, u *url.URL) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: mqtt5ConnectTimeout}
+	var nc net.Conn
+	var err error
+	switch {
+	case u.Scheme == "ws" || u.Scheme == "wss":
+		nc, err = t.dialWebSocket(ctx, dialer, u)
+	case isTLSScheme(u.String()):
+		nc, err = (&tls.Dialer{NetDialer: dialer, Config: t.tlsConfig(u)}).DialContext(ctx, "tcp", hostPort(u, "8883"))
+	case u.Scheme == "tcp" || u.Scheme == "mqtt":
+		nc, err = dialer.DialContext(ctx, "tcp", hostPort(u, "1883"))
+	default:
+		return nil, fmt.Errorf("mqtt5: unsupported broker scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &mqtt5Conn{Conn: nc, onClose: t.connClosed}
+	t.mu.Lock()
+	t.dialing = c
+	t.mu.Unlock()
+	return c, nil
+}
+
+// dialWebSocket 建立 MQTT over WebSocket 连接，子协议为 mqtt
+func (t *MQTT5Transport) dialWebSocket(ctx context.Context, dialer *net.Dialer, u *url.URL) (net.Conn, error) {
+	d := &websocket.Dialer{
+		NetDialContext:   dialer.DialContext,
+		HandshakeTimeout: mqtt5ConnectTimeout,
+		Subprotocols:     []string{"mqtt"},
+	}
+	if u.Scheme == "wss" {
+		d.TLSClientConfig = t.tlsConfig(u)
+	}
+	ws, _, err := d.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("mqtt5: websocket dial: %w", err)
+	}
+	return &wsConn{Conn: ws}, nil
+}
+
+// tlsConfig 返回本次连接使用的 TLS 配置，ServerName 默认为 broker 主机名
+func (t *MQTT5Transport) tlsConfig(u *url.URL) *tls.Config {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.tls != nil {
+		config = t.tls.get()
+	}
+	if config.ServerName == "" {
+		config = config.Clone()
+		config.ServerName = u.Hostname()
+	}
+	return config
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+// connectionUp 连接（含重连）成功，恢复订阅后通知调用方
+func (t *MQTT5Transport) connectionUp(cm *autopaho.ConnectionManager, connack *paho.Connack) {
+	t.mu.Lock()
+	if t.cm != cm {
+		t.mu.Unlock()
+		return
+	}
+	t.conn = t.dialing
+	t.maxQoS = 2
+	if connack.Properties != nil && connack.Properties.MaximumQoS != nil {
+		t.maxQoS = min(*connack.Properties.MaximumQoS, 2)
+	}
+	filters := make(map[string]byte, len(t.subs))
+	for topic, sub := range t.subs {
+		filters[topic] = sub.qos
+	}
+	onConnect := t.onConnect
+	result := t.connectResult
+	t.connectResult = nil
+	t.mu.Unlock()
+
+	// 上一次断开的回调尚未执行时先通知断开，保证回调顺序
+	t.notifyLost(errors.New("mqtt5: connection lost"))
+	if result != nil {
+		result <- nil
+	}
+
+	go func() {
+		if len(filters) > 0 {
+			if err := t.subscribe(cm, filters); err != nil {
+				log.Printf("[SubNodeSync] 恢复订阅失败: %v", err)
+			}
+		}
+		if onConnect != nil {
+			onConnect()
+		}
+	}()
+}
+
+// connectError 首次连接失败时返回给 Connect，之后的重连失败只记录日志
+func (t *MQTT5Transport) connectError(err error) {
+	t.mu.Lock()
+	result := t.connectResult
+	t.connectResult = nil
+	c := t.dialing
+	t.mu.Unlock()
+
+	var connackErr *autopaho.ConnackError
+	if (errors.As(err, &connackErr) && connackErr.ReasonCode == mqtt5UnsupportedV5) || (c != nil && c.legacyConnack()) {
+		err = fmt.Errorf("%w: %v", ErrUnsupportedProtocol, err)
+	}
+	if result != nil {
+		result <- err
+		return
+	}
+	log.Printf("[SubNodeSync] MQTT v5 重连失败: %v，将在 %v 后重试", err, mqtt5ReconnectDelay)
+}
+
+// connClosed 当前连接关闭时标记为断开，由 connectionLost 或下一次 connectionUp 通知调用方
+func (t *MQTT5Transport) connClosed(c *mqtt5Conn) {
+	t.mu.Lock()
+	if t.conn == c {
+		t.conn = nil
+		t.lost = true
+	}
+	t.mu.Unlock()
+}
+
+// connectionLost 连接因错误断开，autopaho 随后自动重连
+func (t *MQTT5Transport) connectionLost(err error) {
+	t.notifyLost(err)
+}
+
+// serverDisconnect broker 发送 DISCONNECT 断开连接
+func (t *MQTT5Transport) serverDisconnect(d *paho.Disconnect) {
+	t.notifyLost(fmt.Errorf("mqtt5: disconnected by broker, reason 0x%02x", d.ReasonCode))
+}
+
+// notifyLost 连接已断开且尚未通知时调用 OnConnectionLost 回调
+func (t *MQTT5Transport) notifyLost(err error) {
+	t.mu.Lock()
+	lost := t.lost
+	t.lost = false
+	onLost := t.onLost
+	t.mu.Unlock()
+	if lost && onLost != nil {
+		onLost(err)
+	}
+}
+
+// handlePublish 按接收顺序调用匹配的处理器，返回后由 paho 确认消息
+func (t *MQTT5Transport) handlePublish(pr paho.PublishReceived) (bool, error) {
+	p := pr.Packet
+	msg := &Message{
+		Topic:      p.Topic,
+		Payload:    p.Payload,
+		QoS:        p.QoS,
+		Retained:   p.Retain,
+		Properties: messageProperties(p.Properties),
+	}
+
+	t.mu.Lock()
+	var handlers []MessageHandler
+	for filter, sub := range t.subs {
+		if matchTopic(filter, msg.Topic) {
+			handlers = append(handlers, sub.handler)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, handler := range handlers {
+		copied := *msg
+		handler(&copied)
+	}
+	return len(handlers) > 0, nil
+}
+
+// subscribe 发送 SUBSCRIBE 并检查每个主题的原因码，连接已断开时在重连后恢复
+func (t *MQTT5Transport) subscribe(cm *autopaho.ConnectionManager, filters map[string]byte) error {
+	topics := make([]string, 0, len(filters))
+	for topic := range filters {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	sub := &paho.Subscribe{}
+	for _, topic := range topics {
+		sub.Subscriptions = append(sub.Subscriptions, paho.SubscribeOptions{Topic: topic, QoS: min(filters[topic], 2)})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mqtt5AckTimeout)
+	defer cancel()
+	suback, err := cm.Subscribe(ctx, sub)
+	if errors.Is(err, autopaho.ConnectionDownError) {
+		return nil
+	}
+	if suback != nil {
+		for i, code := range suback.Reasons {
+			if code >= mqtt5ReasonCodeFailed && i < len(topics) {
+				return fmt.Errorf("mqtt5: subscribe %s rejected, reason 0x%02x", topics[i], code)
+			}
+		}
+	}
+	return err
+}
+
+// publishProperties 生成 PUBLISH 报文和遗嘱的属性
+// 未指定过期时间时使用默认值，保留消息（如在线状态）默认不过期
+func (t *MQTT5Transport) publishProperties(mp *MessageProperties, retained bool) *paho.PublishProperties {
+	props := &paho.PublishProperties{}
+	expiry := t.config.MessageExpiry
+	if retained {
+		expiry = 0
+	}
+	if mp != nil {
+		props.ContentType = mp.ContentType
+		props.ResponseTopic = mp.ResponseTopic
+		props.CorrelationData = mp.CorrelationData
+		if mp.MessageExpiry > 0 {
+			expiry = mp.MessageExpiry
+		}
+		keys := make([]string, 0, len(mp.UserProperties))
+		for k := range mp.UserProperties {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			props.User.Add(k, mp.UserProperties[k])
+		}
+	}
+	if expiry > 0 {
+		v := uint32(expiry.Round(time.Second) / time.Second)
+		if v == 0 {
+			v = 1
+		}
+		props.MessageExpiry = &v
+	}
+	return props
+}
+
+// sessionExpiry 返回 CONNECT 报文中的会话过期时间
+func (t *MQTT5Transport) sessionExpiry() time.Duration {
+	if t.config.SessionExpiry > 0 {
+		return t.config.SessionExpiry
+	}
+	if t.config.PersistentSession {
+		return DefaultSessionExpiry
+	}
+	return 0
+}
+
+// messageProperties 将报文属性转换为 MessageProperties，没有相关属性时返回nil
+func messageProperties(props *paho.PublishProperties) *MessageProperties {
+	if props == nil || (props.ContentType == "" && props.ResponseTopic == "" && props.CorrelationData == nil &&
+		props.MessageExpiry == nil && len(props.User) == 0) {
+		return nil
+	}
+
+	mp := &MessageProperties{
+		ContentType:     props.ContentType,
+		ResponseTopic:   props.ResponseTopic,
+		CorrelationData: props.CorrelationData,
+	}
+	if props.MessageExpiry != nil {
+		mp.MessageExpiry = time.Duration(*props.MessageExpiry) * time.Second
+	}
+	if len(props.User) > 0 {
+		mp.UserProperties = make(map[string]string, len(props.User))
+		for _, kv := range props.User {
+			mp.UserProperties[kv.Key] = kv.Value
+		}
+	}
+	return mp
+}
+
+// mqtt5Conn 包装网络连接
+// 实现 sync.Locker 使 paho 的并发写入串行化（tls.Conn 不支持并发写），
+// 记录收到的前4个字节用于识别 v3.1.1 broker 的 CONNACK，关闭时通知传输层
+type mqtt5Conn struct {
+	net.Conn
+	writeMu sync.Mutex
+
+	headMu sync.Mutex
+	head   []byte
+
+	closeOnce sync.Once
+	onClose   func(*mqtt5Conn)
+}
+
+func (c *mqtt5Conn) Lock()   { c.writeMu.Lock() }
+func (c *mqtt5Conn) Unlock() { c.writeMu.Unlock() }
+
+func (c *mqtt5Conn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.headMu.Lock()
+	if need := 4 - len(c.head); need > 0 {
+		c.head = append(c.head, b[:min(n, need)]...)
+	}
+	c.headMu.Unlock()
+	return n, err
+}
+
+func (c *mqtt5Conn) Close() error {
+	c.closeOnce.Do(func() { c.onClose(c) })
+	return c.Conn.Close()
+}
+
+// legacyConnack 判断收到的是否为 v3.1.1 格式、返回码为不接受协议版本的 CONNACK
+func (c *mqtt5Conn) legacyConnack() bool {
+	c.headMu.Lock()
+	defer c.headMu.Unlock()
+	return len(c.head) == 4 && c.head[0] == 0x20 && c.head[1] == 0x02 && c.head[3] == mqtt5UnsupportedV311
+}
+
+// wsConn 将 WebSocket 连接适配为 net.Conn，MQTT 报文以二进制消息传输
+// 并发写入由 mqtt5Conn 串行化，读取只在 paho 的接收协程中进行
+type wsConn struct {
+	*websocket.Conn
+	r io.Reader // 当前消息的读取器
+}
+
+func (c *wsConn) Read(b []byte) (int, error) {
+	for {
+		if c.r == nil {
+			_, r, err := c.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			c.r = r
+		}
+		n, err := c.r.Read(b)
+		if err == io.EOF {
+			// 当前消息读完，继续读取下一条
+			c.r = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (c *wsConn) Write(b []byte) (int, error) {
+	if err := c.WriteMessage(websocket.BinaryMessage, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *wsConn) SetDeadline(d time.Time) error {
+	if err := c.SetReadDeadline(d); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(d)
+}
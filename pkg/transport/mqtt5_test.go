@@ -0,0 +1,585 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/gorilla/websocket"
+)
+
+// fakeBroker 使用 paho packets 编解码报文的测试 broker，由测试逐个报文驱动
+type fakeBroker struct {
+	t     *testing.T
+	addr  string
+	conns chan net.Conn
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{t: t, addr: "tcp://" + ln.Addr().String(), conns: make(chan net.Conn, 4)}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			b.conns <- c
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return b
+}
+
+// newFakeWSBroker 创建 MQTT over WebSocket 的测试 broker，tlsConfig 不为nil时使用 wss
+func newFakeWSBroker(t *testing.T, tlsConfig *tls.Config) *fakeBroker {
+	t.Helper()
+	b := &fakeBroker{t: t, conns: make(chan net.Conn, 4)}
+	upgrader := websocket.Upgrader{Subprotocols: []string{"mqtt"}}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("websocket upgrade: %v", err)
+			return
+		}
+		if ws.Subprotocol() != "mqtt" {
+			t.Errorf("subprotocol = %q, want mqtt", ws.Subprotocol())
+		}
+		b.conns <- &wsConn{Conn: ws}
+	}))
+	if tlsConfig != nil {
+		srv.TLS = tlsConfig
+		srv.StartTLS()
+		// 证书签发给 localhost，ServerName 取 broker 主机名
+		_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+		b.addr = "wss://" + net.JoinHostPort("localhost", port) + "/mqtt"
+	} else {
+		srv.Start()
+		b.addr = "ws://" + srv.Listener.Addr().String() + "/mqtt"
+	}
+	t.Cleanup(srv.Close)
+	return b
+}
+
+func (b *fakeBroker) url() string { return b.addr }
+
+// next 等待下一个客户端连接
+func (b *fakeBroker) next() net.Conn {
+	b.t.Helper()
+	select {
+	case c := <-b.conns:
+		b.t.Cleanup(func() { c.Close() })
+		return c
+	case <-time.After(5 * time.Second):
+		b.t.Fatal("client did not connect")
+		return nil
+	}
+}
+
+// accept 接受 v5 连接，返回客户端的 CONNECT 报文
+func (b *fakeBroker) accept(connack *packets.Connack) (*brokerConn, *packets.Connect) {
+	b.t.Helper()
+	bc := &brokerConn{t: b.t, conn: b.next()}
+	connect := bc.expect(packets.CONNECT).Content.(*packets.Connect)
+	if connack == nil {
+		connack = &packets.Connack{}
+	}
+	bc.send(packets.CONNACK, connack)
+	return bc, connect
+}
+
+type brokerConn struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func (bc *brokerConn) read() *packets.ControlPacket {
+	bc.t.Helper()
+	bc.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	cp, err := packets.ReadPacket(bc.conn)
+	if err != nil {
+		bc.t.Fatalf("broker read: %v", err)
+	}
+	return cp
+}
+
+// expect 读取下一个非 PINGREQ 报文并检查类型
+func (bc *brokerConn) expect(kind byte) *packets.ControlPacket {
+	bc.t.Helper()
+	for {
+		cp := bc.read()
+		if cp.Type == packets.PINGREQ {
+			bc.send(packets.PINGRESP, &packets.Pingresp{})
+			continue
+		}
+		if cp.Type != kind {
+			bc.t.Fatalf("broker got %s, want type %d", cp.PacketType(), kind)
+		}
+		return cp
+	}
+}
+
+// expectSilence 确认客户端在一段时间内没有发送报文
+func (bc *brokerConn) expectSilence() {
+	bc.t.Helper()
+	bc.conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if cp, err := packets.ReadPacket(bc.conn); err == nil {
+		bc.t.Fatalf("unexpected %s", cp.PacketType())
+	}
+}
+
+func (bc *brokerConn) send(kind byte, content packets.Packet) {
+	bc.t.Helper()
+	cp := packets.NewControlPacket(kind)
+	cp.Content = content
+	if _, err := cp.WriteTo(bc.conn); err != nil {
+		bc.t.Fatalf("broker write: %v", err)
+	}
+}
+
+// subscribed 读取 SUBSCRIBE 并全部授予，返回订阅的主题
+func (bc *brokerConn) subscribed() []string {
+	bc.t.Helper()
+	sub := bc.expect(packets.SUBSCRIBE).Content.(*packets.Subscribe)
+	var topics []string
+	suback := &packets.Suback{PacketID: sub.PacketID}
+	for _, opt := range sub.Subscriptions {
+		topics = append(topics, opt.Topic)
+		suback.Reasons = append(suback.Reasons, opt.QoS)
+	}
+	bc.send(packets.SUBACK, suback)
+	return topics
+}
+
+func newTestMQTT5Transport(b *fakeBroker) *MQTT5Transport {
+	config := DefaultMQTTConfig()
+	config.BrokerURL = b.url()
+	config.ClientID = "mqtt5-test"
+	config.ProtocolVersion = ProtocolV5
+	return NewMQTT5Transport(config)
+}
+
+// connectTransport 在后台调用 Connect，broker 接受连接后返回
+func connectTransport(t *testing.T, tr *MQTT5Transport, b *fakeBroker, connack *packets.Connack) (*brokerConn, *packets.Connect) {
+	t.Helper()
+	errs := make(chan error, 1)
+	go func() { errs <- tr.Connect() }()
+	bc, connect := b.accept(connack)
+	if err := <-errs; err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(tr.Disconnect)
+	return bc, connect
+}
+
+func TestMQTT5ConnectProperties(t *testing.T) {
+	b := newFakeBroker(t)
+	tr := newTestMQTT5Transport(b)
+	tr.config.Username, tr.config.Password = "user", "secret"
+	tr.config.PersistentSession = true
+	tr.config.SessionExpiry = 10 * time.Minute
+	tr.config.MessageExpiry = time.Minute
+	tr.SetWill(&Message{
+		Topic:    "state/n",
+		QoS:      1,
+		Retained: true,
+		Payload:  []byte("offline"),
+		Properties: &MessageProperties{
+			UserProperties: map[string]string{"reason": "connection_lost"},
+		},
+	})
+
+	_, connect := connectTransport(t, tr, b, nil)
+	if connect.ProtocolVersion != ProtocolV5 || connect.CleanStart || connect.ClientID != "mqtt5-test" {
+		t.Fatalf("CONNECT = %s", connect)
+	}
+	if connect.Username != "user" || string(connect.Password) != "secret" {
+		t.Errorf("credentials = %q/%q", connect.Username, connect.Password)
+	}
+	props := connect.Properties
+	if props.SessionExpiryInterval == nil || *props.SessionExpiryInterval != 600 {
+		t.Errorf("Session Expiry = %v, want 600", props.SessionExpiryInterval)
+	}
+	if props.ReceiveMaximum == nil || *props.ReceiveMaximum != mqtt5ReceiveMaximum {
+		t.Errorf("Receive Maximum = %v, want %d", props.ReceiveMaximum, mqtt5ReceiveMaximum)
+	}
+
+	if !connect.WillFlag || connect.WillTopic != "state/n" || string(connect.WillMessage) != "offline" ||
+		connect.WillQOS != 1 || !connect.WillRetain {
+		t.Fatalf("will = %s", connect)
+	}
+	will := connect.WillProperties
+	if len(will.User) != 1 || will.User[0] != (packets.User{Key: "reason", Value: "connection_lost"}) {
+		t.Errorf("will properties = %+v", will)
+	}
+	if !tr.IsConnected() {
+		t.Error("not connected after Connect")
+	}
+}
+
+func TestMQTT5CleanSessionWithoutExpiry(t *testing.T) {
+	b := newFakeBroker(t)
+	_, connect := connectTransport(t, newTestMQTT5Transport(b), b, nil)
+	if !connect.CleanStart || connect.WillFlag {
+		t.Fatalf("CONNECT = %s", connect)
+	}
+	if e := connect.Properties.SessionExpiryInterval; e != nil && *e != 0 {
+		t.Errorf("Session Expiry = %d, want 0", *e)
+	}
+}
+
+func TestMQTT5MessageProperties(t *testing.T) {
+	b := newFakeBroker(t)
+	tr := newTestMQTT5Transport(b)
+	tr.config.MessageExpiry = 90 * time.Second
+	msgs := make(chan *Message, 1)
+	tr.Subscribe("req/#", 1, receiveMessages(msgs))
+
+	bc, _ := connectTransport(t, tr, b, nil)
+	if topics := bc.subscribed(); len(topics) != 1 || topics[0] != "req/#" {
+		t.Fatalf("subscribed %v", topics)
+	}
+
+	// 发布：属性写入 PUBLISH 报文
+	errs := make(chan error, 1)
+	go func() {
+		errs <- tr.PublishMessage(&Message{
+			Topic:   "rpc/call",
+			QoS:     1,
+			Payload: []byte("ping"),
+			Properties: &MessageProperties{
+				ResponseTopic:   "rpc/reply",
+				CorrelationData: []byte{1, 2, 3},
+				UserProperties:  map[string]string{"b": "2", "a": "1"},
+			},
+		})
+	}()
+	pub := bc.expect(packets.PUBLISH).Content.(*packets.Publish)
+	p := pub.Properties
+	if pub.Topic != "rpc/call" || pub.QoS != 1 || string(pub.Payload) != "ping" {
+		t.Fatalf("PUBLISH = %s", pub)
+	}
+	if p.ResponseTopic != "rpc/reply" || !bytes.Equal(p.CorrelationData, []byte{1, 2, 3}) ||
+		p.MessageExpiry == nil || *p.MessageExpiry != 90 {
+		t.Errorf("publish properties = %+v", p)
+	}
+	wantUser := []packets.User{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}}
+	if len(p.User) != 2 || p.User[0] != wantUser[0] || p.User[1] != wantUser[1] {
+		t.Errorf("user properties = %v, want %v", p.User, wantUser)
+	}
+	bc.send(packets.PUBACK, &packets.Puback{PacketID: pub.PacketID})
+	if err := <-errs; err != nil {
+		t.Fatalf("PublishMessage: %v", err)
+	}
+
+	// 接收：报文属性转换为 MessageProperties
+	expiry := uint32(30)
+	bc.send(packets.PUBLISH, &packets.Publish{
+		Topic:    "req/1",
+		QoS:      1,
+		PacketID: 7,
+		Payload:  []byte("pong"),
+		Properties: &packets.Properties{
+			ResponseTopic:   "resp/1",
+			CorrelationData: []byte("id-1"),
+			MessageExpiry:   &expiry,
+			User:            []packets.User{{Key: "k", Value: "v"}},
+		},
+	})
+	msg := nextMessage(t, msgs)
+	mp := msg.Properties
+	if msg.Topic != "req/1" || string(msg.Payload) != "pong" || msg.QoS != 1 || mp == nil {
+		t.Fatalf("received %+v", msg)
+	}
+	if mp.ResponseTopic != "resp/1" || string(mp.CorrelationData) != "id-1" ||
+		mp.MessageExpiry != 30*time.Second || mp.UserProperties["k"] != "v" {
+		t.Errorf("received properties = %+v", mp)
+	}
+	if ack := bc.expect(packets.PUBACK).Content.(*packets.Puback); ack.PacketID != 7 {
+		t.Errorf("PUBACK id %d, want 7", ack.PacketID)
+	}
+}
+
+func TestMQTT5PublishQoS(t *testing.T) {
+	b := newFakeBroker(t)
+	tr := newTestMQTT5Transport(b)
+	maxQoS := byte(1)
+	bc, _ := connectTransport(t, tr, b, &packets.Connack{Properties: &packets.Properties{MaximumQOS: &maxQoS}})
+
+	// 超过 broker Maximum QoS 时降级
+	errs := make(chan error, 1)
+	go func() { errs <- tr.Publish("q", 2, false, []byte("x")) }()
+	pub := bc.expect(packets.PUBLISH).Content.(*packets.Publish)
+	if pub.QoS != 1 {
+		t.Fatalf("published at QoS %d, want 1", pub.QoS)
+	}
+	bc.send(packets.PUBACK, &packets.Puback{PacketID: pub.PacketID})
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	// broker 拒绝的消息返回错误
+	go func() { errs <- tr.Publish("denied", 1, false, []byte("x")) }()
+	pub = bc.expect(packets.PUBLISH).Content.(*packets.Publish)
+	bc.send(packets.PUBACK, &packets.Puback{PacketID: pub.PacketID, ReasonCode: packets.PubackNotAuthorized})
+	if err := <-errs; err == nil {
+		t.Fatal("rejected publish succeeded")
+	}
+}
+
+func TestMQTT5PublishQoS2(t *testing.T) {
+	b := newFakeBroker(t)
+	tr := newTestMQTT5Transport(b)
+	bc, _ := connectTransport(t, tr, b, nil)
+
+	errs := make(chan error, 1)
+	go func() { errs <- tr.Publish("q", 2, false, []byte("x")) }()
+	pub := bc.expect(packets.PUBLISH).Content.(*packets.Publish)
+	if pub.QoS != 2 {
+		t.Fatalf("published at QoS %d, want 2", pub.QoS)
+	}
+	bc.send(packets.PUBREC, &packets.Pubrec{PacketID: pub.PacketID})
+	if rel := bc.expect(packets.PUBREL).Content.(*packets.Pubrel); rel.PacketID != pub.PacketID {
+		t.Fatalf("PUBREL id %d, want %d", rel.PacketID, pub.PacketID)
+	}
+	select {
+	case err := <-errs:
+		t.Fatalf("publish returned before PUBCOMP: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	bc.send(packets.PUBCOMP, &packets.Pubcomp{PacketID: pub.PacketID})
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMQTT5DeliversInOrderAndAcksAfterHandler(t *testing.T) {
+	b := newFakeBroker(t)
+	tr := newTestMQTT5Transport(b)
+	got := make(chan string, 2)
+	release := make(chan struct{})
+	tr.Subscribe("seq", 1, func(msg *Message) {
+		got <- string(msg.Payload)
+		<-release
+	})
+	bc, _ := connectTransport(t, tr, b, nil)
+	bc.subscribed()
+
+	bc.send(packets.PUBLISH, &packets.Publish{Topic: "seq", QoS: 1, PacketID: 1, Payload: []byte("1")})
+	bc.send(packets.PUBLISH, &packets.Publish{Topic: "seq", QoS: 1, PacketID: 2, Payload: []byte("2")})
+	expectMessage(t, got, "1")
+	bc.expectSilence() // 处理器返回前不确认
+
+	release <- struct{}{}
+	if ack := bc.expect(packets.PUBACK).Content.(*packets.Puback); ack.PacketID != 1 {
+		t.Fatalf("PUBACK id %d, want 1", ack.PacketID)
+	}
+	expectMessage(t, got, "2")
+	release <- struct{}{}
+	if ack := bc.expect(packets.PUBACK).Content.(*packets.Puback); ack.PacketID != 2 {
+		t.Fatalf("PUBACK id %d, want 2", ack.PacketID)
+	}
+}
+
+func TestMQTT5ReconnectResubscribes(t *testing.T) {
+	b := newFakeBroker(t)
+	tr := newTestMQTT5Transport(b)
+	connects := make(chan struct{}, 4)
+	lost := make(chan error, 4)
+	tr.OnConnect(func() { connects <- struct{}{} })
+	tr.OnConnectionLost(func(err error) { lost <- err })
+	tr.Subscribe("a/#", 1, func(*Message) {})
+
+	bc, _ := connectTransport(t, tr, b, nil)
+	bc.subscribed()
+	waitSignal(t, connects, "OnConnect")
+
+	bc.conn.Close()
+	select {
+	case err := <-lost:
+		if err == nil {
+			t.Error("OnConnectionLost called with nil error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnConnectionLost not called")
+	}
+	if tr.IsConnected() {
+		t.Fatal("still connected after connection closed")
+	}
+	if err := tr.Publish("x", 0, false, nil); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("publish while disconnected = %v", err)
+	}
+
+	// 重连后恢复订阅，再次调用 OnConnect
+	bc, connect := b.accept(nil)
+	if connect.CleanStart {
+		t.Error("reconnect used clean start")
+	}
+	if topics := bc.subscribed(); len(topics) != 1 || topics[0] != "a/#" {
+		t.Fatalf("resubscribed %v", topics)
+	}
+	waitSignal(t, connects, "OnConnect after reconnect")
+
+	// Disconnect 不触发 OnConnectionLost
+	tr.Disconnect()
+	select {
+	case err := <-lost:
+		t.Fatalf("OnConnectionLost after Disconnect: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func waitSignal(t *testing.T, ch <-chan struct{}, name string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s not called", name)
+	}
+}
+
+// readRawPacket 读取一个完整的报文并返回首字节，用于处理 v3.1.1 报文
+func readRawPacket(t *testing.T, conn net.Conn) byte {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	header := make([]byte, 1)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		t.Fatalf("broker read: %v", err)
+	}
+	length, multiplier := 0, 1
+	for {
+		b := make([]byte, 1)
+		if _, err := io.ReadFull(conn, b); err != nil {
+			t.Fatalf("broker read: %v", err)
+		}
+		length += int(b[0]&0x7f) * multiplier
+		multiplier *= 128
+		if b[0]&0x80 == 0 {
+			break
+		}
+	}
+	if _, err := io.CopyN(io.Discard, conn, int64(length)); err != nil {
+		t.Fatalf("broker read: %v", err)
+	}
+	return header[0]
+}
+
+func TestMQTT5FallsBackToV311(t *testing.T) {
+	rejections := map[string]func(*testing.T, net.Conn){
+		// v3.1.1 broker 以 v3.1.1 格式返回“不接受的协议版本”
+		"v3.1.1 connack": func(t *testing.T, conn net.Conn) {
+			conn.Write([]byte{0x20, 0x02, 0x00, mqtt5UnsupportedV311})
+		},
+		// 支持 v5 报文格式但拒绝 v5 的 broker
+		"v5 reason code": func(t *testing.T, conn net.Conn) {
+			cp := packets.NewControlPacket(packets.CONNACK)
+			cp.Content = &packets.Connack{ReasonCode: packets.ConnackUnsupportedProtocolVersion}
+			cp.WriteTo(conn)
+		},
+	}
+	for name, reject := range rejections {
+		t.Run(name, func(t *testing.T) {
+			b := newFakeBroker(t)
+			tr := newTestMQTT5Transport(b)
+			if !SupportsProperties(tr) {
+				t.Fatal("MQTT5Transport does not report property support before fallback")
+			}
+			errs := make(chan error, 1)
+			go func() { errs <- tr.Connect() }()
+
+			v5 := b.next()
+			if kind := readRawPacket(t, v5); kind>>4 != packets.CONNECT {
+				t.Fatalf("first packet type %d", kind>>4)
+			}
+			reject(t, v5)
+			v5.Close()
+
+			// 改用 v3.1.1 重新连接
+			v3 := b.next()
+			if kind := readRawPacket(t, v3); kind>>4 != packets.CONNECT {
+				t.Fatalf("fallback packet type %d", kind>>4)
+			}
+			v3.Write([]byte{0x20, 0x02, 0x00, 0x00})
+			if err := <-errs; err != nil {
+				t.Fatalf("Connect: %v", err)
+			}
+			defer tr.Disconnect()
+
+			if _, ok := tr.fallbackTransport().(*PahoTransport); !ok {
+				t.Fatalf("fallback = %T, want *PahoTransport", tr.fallbackTransport())
+			}
+			if !tr.IsConnected() {
+				t.Fatal("fallback transport not connected")
+			}
+			// 降级后应答不再携带消息属性，共享连接的会话同样如此
+			if SupportsProperties(tr) || SupportsProperties(NewConnectionManager(tr).NewSession()) {
+				t.Fatal("property support reported after falling back to v3.1.1")
+			}
+		})
+	}
+}
+
+func TestMQTT5ConnectFailure(t *testing.T) {
+	b := newFakeBroker(t)
+	tr := newTestMQTT5Transport(b)
+	errs := make(chan error, 1)
+	go func() { errs <- tr.Connect() }()
+	b.accept(&packets.Connack{ReasonCode: packets.ConnackNotAuthorized})
+
+	err := <-errs
+	if err == nil || errors.Is(err, ErrUnsupportedProtocol) {
+		t.Fatalf("Connect = %v, want authorization error", err)
+	}
+	if tr.fallbackTransport() != nil || tr.IsConnected() {
+		t.Fatal("unexpected fallback or connection after rejected CONNECT")
+	}
+}
+
+func TestMQTT5WebSocket(t *testing.T) {
+	pki := newTestPKI(t)
+	_, _, brokerCert := pki.issue("localhost", false)
+	brokers := map[string]*fakeBroker{
+		"ws":  newFakeWSBroker(t, nil),
+		"wss": newFakeWSBroker(t, &tls.Config{Certificates: []tls.Certificate{brokerCert}}),
+	}
+	for name, b := range brokers {
+		t.Run(name, func(t *testing.T) {
+			tr := newTestMQTT5Transport(b)
+			if name == "wss" {
+				tr.tls = newTLSLoader(&TLSConfig{CAFile: pki.path("ca.pem")})
+			}
+			msgs := make(chan *Message, 1)
+			tr.Subscribe("in", 1, receiveMessages(msgs))
+
+			bc, _ := connectTransport(t, tr, b, nil)
+			bc.subscribed()
+			if !tr.IsConnected() {
+				t.Fatal("not connected over websocket")
+			}
+
+			errs := make(chan error, 1)
+			go func() { errs <- tr.Publish("out", 1, false, []byte("x")) }()
+			pub := bc.expect(packets.PUBLISH).Content.(*packets.Publish)
+			bc.send(packets.PUBACK, &packets.Puback{PacketID: pub.PacketID})
+			if err := <-errs; err != nil {
+				t.Fatalf("Publish: %v", err)
+			}
+
+			bc.send(packets.PUBLISH, &packets.Publish{Topic: "in", Payload: []byte("y")})
+			if msg := nextMessage(t, msgs); string(msg.Payload) != "y" {
+				t.Fatalf("received %+v", msg)
+			}
+		})
+	}
+}
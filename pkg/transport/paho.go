@@ -0,0 +1,213 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/transport/paho.go
+ * Paho MQTT传输层 - 基于 paho.mqtt.golang 的 Transport 实现
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package transport
+
+import (
+	"crypto/tls"
+	"log"
+	"net/url"
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// PahoTransport 基于 paho.mqtt.golang 的传输层实现
+type PahoTransport struct {
+	client mqtt.Client
+	opts   *mqtt.ClientOptions
+
+	mu        sync.RWMutex
+	subs      map[string]subscription
+	onConnect func()
+	onLost    func(err error)
+}
+
+// NewPahoTransport 创建 paho MQTT 传输层，config 为nil时使用默认配置
+func NewPahoTransport(config *MQTTConfig) *PahoTransport {
+	if config == nil {
+		config = DefaultMQTTConfig()
+	}
+
+	t := &PahoTransport{
+		subs: make(map[string]subscription),
+	}
+
+	opts := mqtt.NewClientOptions()
+	opts.AddBroker(config.BrokerURL)
+	opts.SetClientID(config.ClientID)
+	opts.SetUsername(config.Username)
+	opts.SetPassword(config.Password)
+	if config.KeepAlive > 0 {
+		opts.SetKeepAlive(config.KeepAlive)
+	}
+	opts.SetAutoReconnect(true)
+	opts.SetCleanSession(!config.PersistentSession)
+	// 消息回调在独立的 goroutine 中执行，回调内可以同步等待发布确认
+	opts.SetOrderMatters(false)
+	if config.TLS != nil {
+		if !isTLSScheme(config.BrokerURL) {
+			log.Printf("[SubNodeSync] 已配置TLS，但broker地址 %s 不是加密协议，TLS配置不会生效", config.BrokerURL)
+		}
+		// 每次连接（含自动重连）前检查证书文件，更新后的证书无需重启即可生效
+		loader := newTLSLoader(config.TLS)
+		opts.SetConnectionAttemptHandler(func(broker *url.URL, tlsCfg *tls.Config) *tls.Config {
+			return loader.get()
+		})
+	}
+	if config.Will != nil {
+		opts.SetBinaryWill(config.Will.Topic, config.Will.Payload, config.Will.QoS, config.Will.Retained)
+	}
+	opts.OnConnect = t.handleConnect
+	opts.OnConnectionLost = t.handleConnectionLost
+
+	t.opts = opts
+	t.client = mqtt.NewClient(opts)
+	return t
+}
+
+// SetWill 设置遗嘱消息，应在 Connect 之前调用
+// paho 在创建客户端时复制配置，因此会用新的配置重新创建客户端
+func (t *PahoTransport) SetWill(msg *Message) {
+	// 自动重连期间 IsConnected 也返回true，此时不能替换客户端
+	if t.client.IsConnected() {
+		log.Printf("[SubNodeSync] MQTT已连接，遗嘱消息未更新")
+		return
+	}
+	if msg == nil {
+		t.opts.UnsetWill()
+	} else {
+		t.opts.SetBinaryWill(msg.Topic, msg.Payload, msg.QoS, msg.Retained)
+	}
+	t.client = mqtt.NewClient(t.opts)
+}
+
+// Connect 连接MQTT broker
+func (t *PahoTransport) Connect() error {
+	if token := t.client.Connect(); token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
+
+// Disconnect 断开MQTT连接
+func (t *PahoTransport) Disconnect() {
+	if t.client.IsConnected() {
+		t.client.Disconnect(250)
+	}
+}
+
+// IsConnected 检查MQTT连接状态
+func (t *PahoTransport) IsConnected() bool {
+	return t.client.IsConnectionOpen()
+}
+
+// Publish 发布消息并等待确认
+func (t *PahoTransport) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	if !t.IsConnected() {
+		return ErrNotConnected
+	}
+	token := t.client.Publish(topic, qos, retained, payload)
+	if token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
+
+// Subscribe 订阅主题，未连接时仅记录订阅，连接成功后自动生效
+func (t *PahoTransport) Subscribe(topic string, qos byte, handler MessageHandler) error {
+	t.mu.Lock()
+	t.subs[topic] = subscription{qos: qos, handler: handler}
+	t.mu.Unlock()
+
+	if !t.IsConnected() {
+		return nil
+	}
+	token := t.client.Subscribe(topic, qos, wrapHandler(handler))
+	if token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
+
+// Unsubscribe 取消订阅
+func (t *PahoTransport) Unsubscribe(topics ...string) error {
+	t.mu.Lock()
+	for _, topic := range topics {
+		delete(t.subs, topic)
+	}
+	t.mu.Unlock()
+
+	if !t.IsConnected() {
+		return nil
+	}
+	token := t.client.Unsubscribe(topics...)
+	if token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
+
+// OnConnect 设置连接成功回调
+func (t *PahoTransport) OnConnect(fn func()) {
+	t.mu.Lock()
+	t.onConnect = fn
+	t.mu.Unlock()
+}
+
+// OnConnectionLost 设置连接丢失回调
+func (t *PahoTransport) OnConnectionLost(fn func(err error)) {
+	t.mu.Lock()
+	t.onLost = fn
+	t.mu.Unlock()
+}
+
+// handleConnect 连接（含重连）成功后恢复订阅，再通知调用方
+func (t *PahoTransport) handleConnect(client mqtt.Client) {
+	t.mu.RLock()
+	subs := make(map[string]subscription, len(t.subs))
+	for topic, sub := range t.subs {
+		subs[topic] = sub
+	}
+	onConnect := t.onConnect
+	t.mu.RUnlock()
+
+	for topic, sub := range subs {
+		if token := client.Subscribe(topic, sub.qos, wrapHandler(sub.handler)); token.Wait() && token.Error() != nil {
+			log.Printf("[SubNodeSync] 恢复订阅失败: %s, %v", topic, token.Error())
+		}
+	}
+
+	if onConnect != nil {
+		onConnect()
+	}
+}
+
+// handleConnectionLost 连接丢失回调
+func (t *PahoTransport) handleConnectionLost(client mqtt.Client, err error) {
+	t.mu.RLock()
+	onLost := t.onLost
+	t.mu.RUnlock()
+
+	if onLost != nil {
+		onLost(err)
+	}
+}
+
+// wrapHandler 将 MessageHandler 转换为 paho 的消息回调
+func wrapHandler(handler MessageHandler) mqtt.MessageHandler {
+	return func(client mqtt.Client, msg mqtt.Message) {
+		handler(&Message{
+			Topic:    msg.Topic(),
+			Payload:  msg.Payload(),
+			QoS:      msg.Qos(),
+			Retained: msg.Retained(),
+		})
+	}
+}
@@ -0,0 +1,225 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/transport/tls.go
+ * TLS配置 - 加密连接和双向认证，证书文件变化后在下次连接时自动重新加载
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TLSConfig 连接 broker 使用的 TLS 配置
+// broker 地址需使用 ssl://、tls://、mqtts:// 或 wss:// 等加密协议
+type TLSConfig struct {
+	// CAFile 校验 broker 证书的 CA 证书文件（PEM，可包含多个证书），为空时使用系统根证书
+	CAFile string
+	// CertFile、KeyFile 客户端证书和私钥（PEM），用于双向认证，需同时设置
+	CertFile string
+	KeyFile  string
+	// ServerName 校验证书时使用的服务器名称（SNI），为空时使用 broker 地址中的主机名
+	ServerName string
+	// MinVersion 最低 TLS 版本，如 tls.VersionTLS12，为0时为 TLS 1.2
+	MinVersion uint16
+	// CipherSuites 允许的加密套件（仅对 TLS 1.2 生效），为空时使用 Go 的默认套件
+	CipherSuites []uint16
+	// InsecureSkipVerify 跳过 broker 证书校验，仅用于开发环境
+	InsecureSkipVerify bool
+}
+
+// Validate 检查配置是否完整
+func (c *TLSConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("tls: cert file and key file must be set together")
+	}
+	switch c.MinVersion {
+	case 0, tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13:
+	default:
+		return fmt.Errorf("tls: unknown min version 0x%04x", c.MinVersion)
+	}
+	return nil
+}
+
+// Load 读取证书文件并生成 tls.Config
+func (c *TLSConfig) Load() (*tls.Config, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		ServerName:         c.ServerName,
+		MinVersion:         c.MinVersion,
+		CipherSuites:       c.CipherSuites,
+		InsecureSkipVerify: c.InsecureSkipVerify, // #nosec G402 -- 由配置显式开启，仅用于开发环境
+	}
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+
+	if c.CAFile != "" {
+		data, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("tls: no certificates found in %s", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// files 返回配置引用的证书文件
+func (c *TLSConfig) files() []string {
+	var files []string
+	for _, f := range []string{c.CAFile, c.CertFile, c.KeyFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// ParseTLSVersion 解析 "1.0"、"1.1"、"1.2"、"1.3" 形式的 TLS 版本，空字符串返回0
+func ParseTLSVersion(s string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "tls") {
+	case "":
+		return 0, nil
+	case "1.0", "10":
+		return tls.VersionTLS10, nil
+	case "1.1", "11":
+		return tls.VersionTLS11, nil
+	case "1.2", "12":
+		return tls.VersionTLS12, nil
+	case "1.3", "13":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unknown TLS version %q", s)
+	}
+}
+
+// ParseCipherSuites 按名称（如 TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256）解析加密套件
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// isTLSScheme 判断 broker 地址是否使用加密连接
+func isTLSScheme(brokerURL string) bool {
+	u, err := url.Parse(brokerURL)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "ssl", "tls", "mqtts", "mqtt+ssl", "tcps", "wss":
+		return true
+	}
+	return false
+}
+
+// tlsLoader 缓存 tls.Config，证书文件修改时间变化后重新加载
+type tlsLoader struct {
+	config *TLSConfig
+
+	mu       sync.Mutex
+	modTimes []time.Time
+	cached   *tls.Config
+}
+
+func newTLSLoader(config *TLSConfig) *tlsLoader {
+	return &tlsLoader{config: config}
+}
+
+// get 返回最新的 tls.Config
+// 重新加载失败时继续使用上一次成功加载的配置，从未成功时返回让握手失败的配置
+func (l *tlsLoader) get() *tls.Config {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files := l.config.files()
+	modTimes := make([]time.Time, len(files))
+	for i, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			modTimes[i] = info.ModTime()
+		}
+	}
+	if l.cached != nil && equalTimes(modTimes, l.modTimes) {
+		return l.cached
+	}
+
+	config, err := l.config.Load()
+	if err != nil {
+		log.Printf("[SubNodeSync] 加载TLS证书失败: %v", err)
+		if l.cached != nil {
+			return l.cached
+		}
+		// 跳过证书链校验，使握手失败时报告的是证书加载错误
+		return &tls.Config{
+			MinVersion:         tls.VersionTLS12,
+			InsecureSkipVerify: true, // #nosec G402 -- VerifyConnection 始终返回错误，连接不会建立
+			VerifyConnection: func(tls.ConnectionState) error {
+				return err
+			},
+		}
+	}
+	if l.cached != nil {
+		log.Printf("[SubNodeSync] TLS证书已重新加载")
+	}
+	l.cached = config
+	l.modTimes = modTimes
+	return config
+}
+
+func equalTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,247 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testPKI 测试用的 CA，签发 broker 和客户端证书
+type testPKI struct {
+	t      *testing.T
+	dir    string
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	serial int64
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	p := &testPKI{t: t, dir: t.TempDir()}
+	p.ca, p.caKey, _ = p.issue("test-ca", true)
+	p.write("ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: p.ca.Raw}))
+	return p
+}
+
+// issue 签发证书，返回证书、私钥和 tls.Certificate
+func (p *testPKI) issue(name string, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey, tls.Certificate) {
+	p.t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	p.serial++
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(p.serial),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	parent, parentKey := tmpl, key
+	if p.ca != nil {
+		parent, parentKey = p.ca, p.caKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return cert, key, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}
+}
+
+// writeClientCert 签发客户端证书并写入 client.pem、client-key.pem，返回证书序列号
+func (p *testPKI) writeClientCert(modTime time.Time) int64 {
+	p.t.Helper()
+	cert, key, _ := p.issue("client", false)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	p.write("client.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	p.write("client-key.pem", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	p.touch(modTime, "client.pem", "client-key.pem")
+	return cert.SerialNumber.Int64()
+}
+
+func (p *testPKI) write(name string, data []byte) {
+	p.t.Helper()
+	if err := os.WriteFile(p.path(name), data, 0o600); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+// touch 设置文件修改时间，避免文件系统时间精度不足时修改不可见
+func (p *testPKI) touch(modTime time.Time, names ...string) {
+	p.t.Helper()
+	for _, name := range names {
+		if err := os.Chtimes(p.path(name), modTime, modTime); err != nil {
+			p.t.Fatal(err)
+		}
+	}
+}
+
+func (p *testPKI) path(name string) string { return filepath.Join(p.dir, name) }
+
+// handshake 与要求客户端证书的 broker 握手，返回 broker 看到的客户端证书序列号
+func (p *testPKI) handshake(client *tls.Config) (int64, error) {
+	p.t.Helper()
+	_, _, brokerCert := p.issue("broker.local", false)
+	roots := x509.NewCertPool()
+	roots.AddCert(p.ca)
+
+	clientConn, brokerConn := net.Pipe()
+	defer clientConn.Close()
+	defer brokerConn.Close()
+
+	serials := make(chan int64, 1)
+	go func() {
+		broker := tls.Server(brokerConn, &tls.Config{
+			Certificates: []tls.Certificate{brokerCert},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    roots,
+			MinVersion:   tls.VersionTLS12,
+		})
+		if err := broker.Handshake(); err != nil {
+			brokerConn.Close()
+			serials <- 0
+			return
+		}
+		serials <- broker.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}()
+
+	config := client.Clone()
+	config.ServerName = "broker.local"
+	conn := tls.Client(clientConn, config)
+	if err := conn.Handshake(); err != nil {
+		return 0, err
+	}
+	return <-serials, nil
+}
+
+func TestTLSLoaderReloadsChangedCertificates(t *testing.T) {
+	pki := newTestPKI(t)
+	start := time.Now().Add(-time.Minute)
+	first := pki.writeClientCert(start)
+	pki.touch(start, "ca.pem")
+
+	loader := newTLSLoader(&TLSConfig{
+		CAFile:   pki.path("ca.pem"),
+		CertFile: pki.path("client.pem"),
+		KeyFile:  pki.path("client-key.pem"),
+	})
+	config := loader.get()
+	if serial, err := pki.handshake(config); err != nil || serial != first {
+		t.Fatalf("first handshake: serial %d, err %v; want %d", serial, err, first)
+	}
+
+	// 文件未变化时复用缓存
+	if loader.get() != config {
+		t.Fatal("unchanged certificates were reloaded")
+	}
+
+	// 证书轮换后下次连接使用新证书
+	second := pki.writeClientCert(start.Add(time.Second))
+	reloaded := loader.get()
+	if reloaded == config {
+		t.Fatal("rotated certificate was not reloaded")
+	}
+	if serial, err := pki.handshake(reloaded); err != nil || serial != second {
+		t.Fatalf("handshake after rotation: serial %d, err %v; want %d", serial, err, second)
+	}
+
+	// 写入一半的证书加载失败时继续使用上一次成功加载的配置
+	pki.write("client.pem", []byte("-----BEGIN CERTIFICATE-----\ntruncated"))
+	pki.touch(start.Add(2*time.Second), "client.pem")
+	if loader.get() != reloaded {
+		t.Fatal("broken certificate replaced the cached config")
+	}
+
+	// 修复后重新加载
+	third := pki.writeClientCert(start.Add(3 * time.Second))
+	if serial, err := pki.handshake(loader.get()); err != nil || serial != third {
+		t.Fatalf("handshake after repair: serial %d, err %v; want %d", serial, err, third)
+	}
+}
+
+func TestTLSLoaderFailsHandshakeWithoutCertificates(t *testing.T) {
+	pki := newTestPKI(t)
+	loader := newTLSLoader(&TLSConfig{
+		CAFile:   pki.path("ca.pem"),
+		CertFile: pki.path("missing.pem"),
+		KeyFile:  pki.path("missing-key.pem"),
+	})
+	if _, err := pki.handshake(loader.get()); err == nil {
+		t.Fatal("handshake succeeded without a client certificate")
+	}
+
+	// 证书出现后下次连接成功
+	serial := pki.writeClientCert(time.Now())
+	loader.config.CertFile = pki.path("client.pem")
+	loader.config.KeyFile = pki.path("client-key.pem")
+	if got, err := pki.handshake(loader.get()); err != nil || got != serial {
+		t.Fatalf("handshake: serial %d, err %v; want %d", got, err, serial)
+	}
+}
+
+func TestTLSConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  TLSConfig
+		wantErr bool
+	}{
+		{"empty", TLSConfig{}, false},
+		{"mutual", TLSConfig{CertFile: "c.pem", KeyFile: "k.pem", MinVersion: tls.VersionTLS13}, false},
+		{"cert without key", TLSConfig{CertFile: "c.pem"}, true},
+		{"key without cert", TLSConfig{KeyFile: "k.pem"}, true},
+		{"unknown version", TLSConfig{MinVersion: 0x0999}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseTLSSettings(t *testing.T) {
+	versions := map[string]uint16{"": 0, "1.2": tls.VersionTLS12, "TLS1.3": tls.VersionTLS13, " tls12 ": tls.VersionTLS12}
+	for s, want := range versions {
+		if got, err := ParseTLSVersion(s); err != nil || got != want {
+			t.Errorf("ParseTLSVersion(%q) = %#x, %v; want %#x", s, got, err, want)
+		}
+	}
+	if _, err := ParseTLSVersion("1.4"); err == nil {
+		t.Error("ParseTLSVersion(1.4) succeeded")
+	}
+
+	ids, err := ParseCipherSuites([]string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", " ", "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"})
+	if err != nil || len(ids) != 2 || ids[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("ParseCipherSuites = %v, %v", ids, err)
+	}
+	if _, err := ParseCipherSuites([]string{"TLS_UNKNOWN"}); err == nil {
+		t.Error("ParseCipherSuites accepted an unknown suite")
+	}
+
+	for url, want := range map[string]bool{"ssl://h:8883": true, "mqtts://h": true, "wss://h/mqtt": true, "tcp://h:1883": false, "ws://h": false} {
+		if got := isTLSScheme(url); got != want {
+			t.Errorf("isTLSScheme(%s) = %v", url, got)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * SubNodeSync - 分布式节点同步框架
+ * pkg/transport/transport.go
+ * 传输层接口 - 定义与具体协议实现无关的连接、发布和订阅接口
+ *
+ * Copyright (c) 2024. All Rights Reserved.
+ * Licensed under the MIT License.
+ */
+
+package transport
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotConnected 传输层未连接时发布消息返回的错误
+var ErrNotConnected = errors.New("transport not connected")
+
+// Message 传输层收到的消息
+type Message struct {
+	Topic    string
+	Payload  []byte
+	QoS      byte
+	Retained bool
+	// Properties MQTT v5 消息属性，传输层不支持或消息未携带时为nil
+	Properties *MessageProperties
+}
+
+// MessageProperties MQTT v5 消息属性
+type MessageProperties struct {
+	// ContentType 消息体的内容类型，如 application/json
+	ContentType string
+	// ResponseTopic 请求方期望的应答主题
+	ResponseTopic string
+	// CorrelationData 请求方用于关联应答的数据，应答时原样带回
+	CorrelationData []byte
+	// MessageExpiry 消息过期时间，broker 丢弃超时未投递的消息，为0时不过期
+	MessageExpiry time.Duration
+	// UserProperties 用户属性
+	UserProperties map[string]string
+}
+
+// MessageHandler 消息处理回调
+type MessageHandler func(msg *Message)
+
+// PropertyPublisher 支持携带 MQTT v5 消息属性发布的传输层
+type PropertyPublisher interface {
+	PublishMessage(msg *Message) error
+}
+
+// SupportsProperties 判断传输层当前的连接能否携带 MQTT v5 消息属性
+// 共享连接的会话取决于底层传输层，已降级到 v3.1.1 的 MQTT5Transport 返回false
+func SupportsProperties(t Transport) bool {
+	switch v := t.(type) {
+	case *Session:
+		return SupportsProperties(v.manager.transport)
+	case *MQTT5Transport:
+		return v.fallbackTransport() == nil
+	}
+	_, ok := t.(PropertyPublisher)
+	return ok
+}
+
+// WillSetter 支持遗嘱消息的传输层
+// 连接异常断开（未调用 Disconnect）时由 broker 向订阅者发布遗嘱消息
+type WillSetter interface {
+	// SetWill 设置遗嘱消息，msg 为nil时取消，应在 Connect 之前调用
+	SetWill(msg *Message)
+}
+
+// Transport 传输层接口
+//
+// 主题和通配符遵循 MQTT 语义（"+" 匹配单层，"#" 匹配剩余所有层级）。
+// Subscribe 可以在 Connect 之前调用，订阅会在每次（重新）连接成功后自动恢复，
+// 恢复完成后再调用 OnConnect 注册的回调，因此回调中可以直接发布注册等消息。
+// 消息回调可能在独立的 goroutine 中并发执行，回调内允许调用 Publish。
+type Transport interface {
+	// Connect 建立连接，首次连接失败时返回错误
+	Connect() error
+	// Disconnect 断开连接
+	Disconnect()
+	// IsConnected 返回当前是否已连接
+	IsConnected() bool
+	// Publish 发布消息，未连接时返回 ErrNotConnected
+	Publish(topic string, qos byte, retained bool, payload []byte) error
+	// Subscribe 订阅主题，同一主题重复订阅时替换回调
+	Subscribe(topic string, qos byte, handler MessageHandler) error
+	// Unsubscribe 取消订阅
+	Unsubscribe(topics ...string) error
+	// OnConnect 设置连接（含重连）成功回调
+	OnConnect(fn func())
+	// OnConnectionLost 设置连接丢失回调
+	OnConnectionLost(fn func(err error))
+}
+
+// subscription 订阅记录，用于重连后恢复订阅
+type subscription struct {
+	qos     byte
+	handler MessageHandler
+}